@@ -0,0 +1,221 @@
+package anki
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	// sqlite driver for the collection embedded in the package
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var ErrNoCollection = errors.New("package does not contain a collection")
+var ErrUnsupportedCollection = errors.New("collection format is not supported, export with \"support older Anki versions\"")
+
+// Deck is an Anki deck with its notes converted to plain text.
+type Deck struct {
+	Name        string
+	Description string
+	Notes       []Note
+}
+
+// Note is an Anki note. Question is the first field of the note, Answer is the rest of the non-empty fields.
+type Note struct {
+	Question string
+	Answer   string
+}
+
+// ReadPackage reads the decks inside an .apkg file. Decks without notes are left out.
+// A note belongs to the deck of its first card.
+func ReadPackage(r io.ReaderAt, size int64) ([]Deck, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	var col *zip.File
+	if f, ok := files["collection.anki21"]; ok {
+		col = f
+	} else if f, ok := files["collection.anki2"]; ok {
+		col = f
+	} else if _, ok := files["collection.anki21b"]; ok {
+		return nil, ErrUnsupportedCollection
+	} else {
+		return nil, ErrNoCollection
+	}
+
+	path, err := extract(col)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+
+	sdb, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer sdb.Close()
+	return readCollection(sdb)
+}
+
+// extract copies the collection to a temporary file since sqlite cannot read from memory.
+func extract(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	tmp, err := ioutil.TempFile("", "collection-*.anki2")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+	if _, err := io.Copy(tmp, rc); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+func readCollection(sdb *sql.DB) ([]Deck, error) {
+	decks, err := readDecks(sdb)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := sdb.Query("select cards.nid, cards.did, notes.flds from cards join notes on notes.id = cards.nid order by cards.nid, cards.ord")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var order []int64
+	notes := make(map[int64][]Note)
+	seen := make(map[int64]bool)
+	for rows.Next() {
+		var noteID, deckID int64
+		var fields string
+		if err := rows.Scan(&noteID, &deckID, &fields); err != nil {
+			return nil, err
+		}
+		if seen[noteID] {
+			continue
+		}
+		seen[noteID] = true
+		n, ok := newNote(fields)
+		if !ok {
+			continue
+		}
+		if _, ok := notes[deckID]; !ok {
+			order = append(order, deckID)
+		}
+		notes[deckID] = append(notes[deckID], n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	res := make([]Deck, 0, len(order))
+	for _, deckID := range order {
+		d, ok := decks[deckID]
+		if !ok {
+			d = Deck{Name: "Deck " + strconv.FormatInt(deckID, 10)}
+		}
+		d.Notes = notes[deckID]
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+// readDecks reads the decks from the col table, or from the decks table for collections of newer Anki versions.
+func readDecks(sdb *sql.DB) (map[int64]Deck, error) {
+	var raw string
+	if err := sdb.QueryRow("select decks from col").Scan(&raw); err != nil {
+		return nil, err
+	}
+	var colDecks map[string]struct {
+		Name string `json:"name"`
+		Desc string `json:"desc"`
+	}
+	if err := json.Unmarshal([]byte(raw), &colDecks); err != nil {
+		return nil, err
+	}
+	decks := make(map[int64]Deck)
+	for id, d := range colDecks {
+		deckID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		decks[deckID] = Deck{Name: d.Name, Description: htmlToText(d.Desc)}
+	}
+	if len(decks) != 0 {
+		return decks, nil
+	}
+
+	rows, err := sdb.Query("select id, name from decks")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var deckID int64
+		var name string
+		if err := rows.Scan(&deckID, &name); err != nil {
+			return nil, err
+		}
+		// newer versions separate the deck hierarchy with the unit separator
+		decks[deckID] = Deck{Name: strings.ReplaceAll(name, "\x1f", "::")}
+	}
+	return decks, rows.Err()
+}
+
+// newNote converts the fields of a note, separated by the unit separator, to a note. Notes without a question are skipped.
+func newNote(fields string) (Note, bool) {
+	flds := strings.Split(fields, "\x1f")
+	q := htmlToText(flds[0])
+	if len(q) == 0 {
+		return Note{}, false
+	}
+	var answers []string
+	for _, f := range flds[1:] {
+		if a := htmlToText(f); len(a) != 0 {
+			answers = append(answers, a)
+		}
+	}
+	return Note{Question: q, Answer: strings.Join(answers, "\n")}, true
+}
+
+var (
+	lineBreakRe  = regexp.MustCompile(`(?i)<br\s*/?>|</(div|p|li|tr|h[1-6])>`)
+	tagRe        = regexp.MustCompile(`<[^>]*>`)
+	soundRe      = regexp.MustCompile(`\[sound:[^\]]*\]`)
+	blankLinesRe = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText converts the HTML of an Anki field to plain text. Block elements and line breaks become new lines,
+// other tags and sound references are removed.
+func htmlToText(s string) string {
+	s = lineBreakRe.ReplaceAllString(s, "\n")
+	s = tagRe.ReplaceAllString(s, "")
+	s = soundRe.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = strings.ReplaceAll(s, "\u00a0", " ")
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	s = strings.Join(lines, "\n")
+	s = blankLinesRe.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,196 @@
+package anki
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readPackage reads the package of the zip with the files.
+func readPackage(t *testing.T, files map[string][]byte) ([]Deck, error) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return ReadPackage(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+}
+
+// newerCollection returns a collection of newer Anki versions, with the decks in their own table.
+func newerCollection(t *testing.T) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "collection.anki21")
+	sdb, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"create table col (decks text)",
+		"create table decks (id integer primary key, name text)",
+		"create table notes (id integer primary key, flds text)",
+		"create table cards (id integer primary key, nid integer, did integer, ord integer)",
+		"insert into col values ('{}')",
+		"insert into decks values (1, 'Languages' || char(31) || 'Turkish')",
+		"insert into notes values (1, 'Water' || char(31) || 'Su')",
+		"insert into cards values (1, 1, 1, 0)",
+	} {
+		if _, err := sdb.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sdb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	col, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return col
+}
+
+func TestReadPackage(t *testing.T) {
+	f, err := os.Open("testdata/deck.apkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decks, err := ReadPackage(f, fi.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the note with an image only has no question, the note of two decks belongs to the deck of its first card,
+	// and the unknown deck of that card gets a name from its id
+	want := []Deck{
+		{Name: "Default", Description: "Greetings & phrases", Notes: []Note{{Question: "Hello", Answer: "Merhaba\nSelam"}}},
+		{Name: "Vocabulary::Verbs", Notes: []Note{{Question: "<go>", Answer: "gitmek"}}},
+		{Name: "Deck 3", Notes: []Note{{Question: "Good night", Answer: "İyi geceler"}}},
+	}
+	if !reflect.DeepEqual(decks, want) {
+		t.Errorf("decks %+v, want %+v", decks, want)
+	}
+}
+
+func TestReadPackageFormats(t *testing.T) {
+	fixture, err := ioutil.ReadFile("testdata/deck.apkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(fixture), int64(len(fixture)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := zr.Open("collection.anki2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	older, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newer := newerCollection(t)
+	turkish := []Deck{{Name: "Languages::Turkish", Notes: []Note{{Question: "Water", Answer: "Su"}}}}
+
+	tests := []struct {
+		name  string
+		files map[string][]byte
+		decks []Deck // the names of the decks are compared if nil
+		names []string
+		err   error
+	}{
+		{name: "anki2", files: map[string][]byte{"collection.anki2": older}, names: []string{"Default", "Vocabulary::Verbs", "Deck 3"}},
+		{name: "anki21", files: map[string][]byte{"collection.anki21": newer}, decks: turkish},
+		{name: "anki21 before anki2", files: map[string][]byte{"collection.anki2": older, "collection.anki21": newer}, decks: turkish},
+		{name: "anki21 before anki21b", files: map[string][]byte{"collection.anki21": newer, "collection.anki21b": {1}}, decks: turkish},
+		{name: "anki21b", files: map[string][]byte{"collection.anki21b": {1}, "media": []byte("{}")}, err: ErrUnsupportedCollection},
+		{name: "no collection", files: map[string][]byte{"media": []byte("{}")}, err: ErrNoCollection},
+	}
+	for _, tt := range tests {
+		decks, err := readPackage(t, tt.files)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if tt.decks != nil && !reflect.DeepEqual(decks, tt.decks) {
+			t.Errorf("%s: decks %+v, want %+v", tt.name, decks, tt.decks)
+		}
+		if tt.names != nil {
+			var names []string
+			for _, d := range decks {
+				names = append(names, d.Name)
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("%s: decks %q, want %q", tt.name, names, tt.names)
+			}
+		}
+	}
+
+	if _, err := ReadPackage(bytes.NewReader([]byte("not a zip")), 9); err == nil {
+		t.Error("reading a file which is not a zip succeeded")
+	}
+}
+
+func TestNewNote(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		want   Note
+		ok     bool
+	}{
+		{name: "two fields", fields: "Question\x1fAnswer", want: Note{Question: "Question", Answer: "Answer"}, ok: true},
+		{name: "question only", fields: "Question", want: Note{Question: "Question"}, ok: true},
+		{name: "answer fields joined", fields: "Question\x1fFirst\x1f \x1f<br>\x1fSecond", want: Note{Question: "Question", Answer: "First\nSecond"}, ok: true},
+		{name: "empty question", fields: "\x1fAnswer"},
+		{name: "markup question", fields: "<img src=\"a.png\"> [sound:a.mp3]\x1fAnswer"},
+	}
+	for _, tt := range tests {
+		n, ok := newNote(tt.fields)
+		if ok != tt.ok || n != tt.want {
+			t.Errorf("%s: note %+v %v, want %+v %v", tt.name, n, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{name: "plain", html: "text", want: "text"},
+		{name: "inline tags", html: "<b>bold</b> and <span style=\"color: red\">red</span>", want: "bold and red"},
+		{name: "line breaks", html: "one<br>two<BR />three", want: "one\ntwo\nthree"},
+		{name: "blocks", html: "<div>one</div><div>two</div><p>three</p>", want: "one\ntwo\nthree"},
+		{name: "list", html: "<ul><li>one</li><li>two</li></ul>", want: "one\ntwo"},
+		{name: "entities", html: "a &lt; b &amp;&amp; c&nbsp;&gt; d", want: "a < b && c > d"},
+		{name: "sound", html: "word [sound:word.mp3]", want: "word"},
+		{name: "blank lines", html: "one<br><br><br><br>two", want: "one\n\ntwo"},
+		{name: "surrounding space", html: "  <div> text </div>  ", want: "text"},
+		{name: "markup only", html: "<img src=\"a.png\">", want: ""},
+	}
+	for _, tt := range tests {
+		if got := htmlToText(tt.html); got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
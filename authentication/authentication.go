@@ -2,116 +2,107 @@ package authentication
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
-	"firebase.google.com/go/auth"
 	"github.com/ironstone95/FlashQudoV2/database"
 	"github.com/ironstone95/FlashQudoV2/model"
 )
 
 type Authenticator struct {
-	db         *database.Database
-	l          *log.Logger
-	authClient *auth.Client
-	debugLog   *log.Logger
+	db       database.Store
+	l        *log.Logger
+	verifier TokenVerifier
+	debugLog *log.Logger
 }
 
-// NewAuthenticator creates a new Authenticator instance
-func NewAuthenticator(l *log.Logger, db *database.Database, authClient *auth.Client, fullLog bool) *Authenticator {
+// NewAuthenticator creates a new Authenticator instance, the tokens which are not in the database are checked with verifier
+func NewAuthenticator(l *log.Logger, db database.Store, verifier TokenVerifier, fullLog bool) *Authenticator {
 	a := new(Authenticator)
 	a.l = l
 	a.db = db
-	a.authClient = authClient
+	a.verifier = verifier
 	if fullLog {
 		a.debugLog = log.New(os.Stdout, "[Authenticator] ", 0)
 	}
 	return a
 }
 
-// AuthToken checks if given token is saved in the database, if not checks in Firebase
-func (a *Authenticator) AuthToken(token string) error {
+// revocationCheckInterval is how often a saved token is checked again with a verifier which can revoke tokens.
+const revocationCheckInterval = 5 * time.Minute
+
+// AuthToken checks if given token is saved in the database, if not checks it with the verifier, and returns
+// the id of the user of the token. Saved tokens are checked again for revocation if the verifier supports it.
+func (a *Authenticator) AuthToken(ctx context.Context, token string) (string, error) {
 	log.Println() // to separate each request
 	if len(token) == 0 {
 		a.log("AuthToken", "Empty Token")
-		return fmt.Errorf("token cannot be empty")
+		return "", fmt.Errorf("token cannot be empty")
 	}
-	err := a.db.AuthToken(token)
+	t, err := a.db.AuthToken(token)
 	if err == nil {
+		if err := a.checkRevoked(ctx, token, t); err != nil {
+			a.log("AuthToken", err.Error())
+			return "", err
+		}
 		a.log("AuthToken", "SUCCESS")
-		return nil
+		return t.UserID, nil
 	}
-	ctx := context.Background()
-	authToken, err := a.authClient.VerifyIDToken(ctx, token)
+	if errors.Is(err, database.ErrTokenRevoked) {
+		a.log("AuthToken", err.Error())
+		return "", err
+	}
+	vt, err := a.verifier.VerifyToken(ctx, token)
 	if err != nil {
 		a.log("AuthToken", err.Error())
-		return err
+		return "", err
 	}
-	t := model.Token{Token: token, UserID: authToken.UID, Expires: authToken.Expires}
-	_, err = a.db.InsertToken(t)
+	_, err = a.db.InsertToken(token, vt.UserID, vt.IssuedAt, vt.Expires)
 	if err != nil {
 		a.log("AuthToken", err.Error())
-		return err
+		return "", err
 	}
 	a.log("AuthToken", "SUCCESS")
-	return nil
+	return vt.UserID, nil
 }
 
-// IsGroupMember checks if the passed token is the member of the group with id groupID
-func (a *Authenticator) IsGroupMember(groupID, token string) bool {
-	userID, err := a.db.GetIDFromToken(token)
-	if err != nil {
-		a.log("IsGroupMember", err.Error())
-		return false
-	}
-	isMember, err := a.db.IsGroupMember(groupID, userID)
-	if err != nil {
-		a.log("IsGroupMember", err.Error())
-		return false
+// checkRevoked checks the saved token with the verifier if it was not checked for revocationCheckInterval.
+// A revoked, invalid or expired token is marked as revoked in the database. If the verifier fails otherwise,
+// e.g. the provider cannot be reached, the error is returned and the token is checked again with the next request.
+func (a *Authenticator) checkRevoked(ctx context.Context, token string, t *model.Token) error {
+	revoker, ok := a.verifier.(Revoker)
+	if !ok || time.Since(time.Unix(t.CheckedAt, 0)) < revocationCheckInterval {
+		return nil
 	}
-	if isMember {
-		a.log("IsGroupMember", "SUCCESS")
-	} else {
-		a.log("IsGroupMember", "FAIL")
+	_, err := revoker.VerifyTokenAndCheckRevoked(ctx, token)
+	if err == nil {
+		return a.db.MarkTokenChecked(token)
 	}
-	return isMember
-}
-
-// IsTheUser checks given token belongs to user with id userID
-func (a *Authenticator) IsTheUser(userID, token string) bool {
-	id, err := a.db.GetIDFromToken(token)
-	if err != nil {
-		a.log("IsTheUser", err.Error())
-		return false
+	if !errors.Is(err, ErrInvalidToken) && time.Now().Unix() <= t.Expires {
+		return err
 	}
-	res := id == userID
-	if res {
-		a.log("IsTheUser", "SUCCESS")
-	} else {
-		a.log("IsTheUser", "FAIL")
+	if rErr := a.db.RevokeToken(token); rErr != nil {
+		a.log("checkRevoked", rErr.Error())
 	}
-	return id == userID
+	return err
 }
 
-// CanSeeBundle checks owner of the given token can see the bundle with id bundleID
-func (a *Authenticator) CanSeeBundle(bundleID, token string) bool {
-	userID, err := a.db.GetIDFromToken(token)
-	if err != nil {
-		a.log("CanSeeBundle", err.Error())
-		return false
-	}
-	canSee, err := a.db.CanSeeBundle(bundleID, userID)
+// AuthAccessToken checks the personal access token is valid and its scopes allow the HTTP method.
+func (a *Authenticator) AuthAccessToken(token, method string) (*model.AccessToken, error) {
+	t, err := a.db.AuthAccessToken(token)
 	if err != nil {
-		a.log("CanSeeBundle", err.Error())
-		return false
+		a.log("AuthAccessToken", err.Error())
+		return nil, err
 	}
-	if canSee {
-		a.log("CanSeeBundle", "SUCCESS")
-	} else {
-		a.log("CanSeeBundle", "FAIL")
+	if !t.Allows(method) {
+		a.log("AuthAccessToken", "scope does not allow "+method)
+		return nil, fmt.Errorf("access token scope does not allow %s", method)
 	}
-	return canSee
+	a.log("AuthAccessToken", "SUCCESS")
+	return t, nil
 }
 
 // TODO change it
@@ -0,0 +1,79 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/config"
+	"github.com/ironstone95/FlashQudoV2/database"
+)
+
+// fakeRevoker is a verifier which fails the revocation check with err.
+type fakeRevoker struct {
+	err error
+}
+
+func (fr fakeRevoker) VerifyToken(ctx context.Context, token string) (*VerifiedToken, error) {
+	return fr.VerifyTokenAndCheckRevoked(ctx, token)
+}
+
+func (fr fakeRevoker) VerifyTokenAndCheckRevoked(ctx context.Context, token string) (*VerifiedToken, error) {
+	if fr.err != nil {
+		return nil, fr.err
+	}
+	return &VerifiedToken{UserID: "user-1"}, nil
+}
+
+func (fr fakeRevoker) RevokeUserTokens(ctx context.Context, userID string) error {
+	return nil
+}
+
+func TestCheckRevoked(t *testing.T) {
+	unavailable := errors.New("provider unavailable")
+	tests := []struct {
+		name    string
+		err     error
+		expired bool
+		revoked bool
+	}{
+		{name: "valid", err: nil},
+		{name: "revoked", err: fmt.Errorf("%w: revoked", ErrInvalidToken), revoked: true},
+		{name: "invalid", err: ErrInvalidToken, revoked: true},
+		{name: "provider unavailable", err: unavailable},
+		{name: "provider unavailable and expired", err: unavailable, expired: true, revoked: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			l := log.New(ioutil.Discard, "", 0)
+			cfg := config.Default()
+			cfg.Database.Driver = config.DriverSQLite
+			cfg.Database.File = ":memory:"
+			db := database.ConnectDB(l, cfg.Database, false)
+			a := NewAuthenticator(l, db, fakeRevoker{err: tt.err}, false)
+
+			now := time.Now()
+			saved, err := db.InsertToken("token", "user-1", now.Unix(), now.Add(time.Hour).Unix())
+			if err != nil {
+				t.Fatal(err)
+			}
+			saved.CheckedAt = 0
+			if tt.expired {
+				saved.Expires = now.Add(-time.Hour).Unix()
+			}
+			if err := a.checkRevoked(context.Background(), "token", saved); !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+
+			_, err = db.AuthToken("token")
+			if revoked := errors.Is(err, database.ErrTokenRevoked); revoked != tt.revoked {
+				t.Fatalf("revoked %t, want %t: %v", revoked, tt.revoked, err)
+			}
+		})
+	}
+}
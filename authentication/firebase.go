@@ -0,0 +1,50 @@
+package authentication
+
+import (
+	"context"
+	"fmt"
+
+	firebase "firebase.google.com/go"
+	"firebase.google.com/go/auth"
+)
+
+// FirebaseVerifier verifies Firebase ID tokens.
+type FirebaseVerifier struct {
+	client *auth.Client
+}
+
+// NewFirebaseVerifier connects to Firebase with the default Google credentials.
+func NewFirebaseVerifier(ctx context.Context) (*FirebaseVerifier, error) {
+	app, err := firebase.NewApp(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	client, err := app.Auth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &FirebaseVerifier{client: client}, nil
+}
+
+func (fv *FirebaseVerifier) VerifyToken(ctx context.Context, token string) (*VerifiedToken, error) {
+	t, err := fv.client.VerifyIDToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return &VerifiedToken{UserID: t.UID, IssuedAt: t.IssuedAt, Expires: t.Expires}, nil
+}
+
+func (fv *FirebaseVerifier) VerifyTokenAndCheckRevoked(ctx context.Context, token string) (*VerifiedToken, error) {
+	t, err := fv.client.VerifyIDTokenAndCheckRevoked(ctx, token)
+	if auth.IsIDTokenRevoked(err) {
+		return nil, fmt.Errorf("%w: revoked", ErrInvalidToken)
+	} else if err != nil {
+		return nil, err
+	}
+	return &VerifiedToken{UserID: t.UID, IssuedAt: t.IssuedAt, Expires: t.Expires}, nil
+}
+
+// RevokeUserTokens revokes the refresh tokens of the user, the ID tokens issued before are revoked too.
+func (fv *FirebaseVerifier) RevokeUserTokens(ctx context.Context, userID string) error {
+	return fv.client.RevokeRefreshTokens(ctx, userID)
+}
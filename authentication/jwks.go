@@ -0,0 +1,363 @@
+package authentication
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // hashes of the supported algorithms
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockSkew is the tolerance of the time claims of a token.
+const clockSkew = time.Minute
+
+// minKeyRefresh is the minimum time between two downloads of the keys, when a token has an unknown key id.
+const minKeyRefresh = time.Minute
+
+// JWKSConfig is the configuration of a JWKSVerifier. Source is a file path or a http(s) URL of a JSON Web Key Set.
+// If Source is empty, the key set is discovered from the OpenID configuration of Issuer.
+// Issuer and Audience are checked if they are not empty. UserClaim is the claim of the user id, sub by default.
+type JWKSConfig struct {
+	Source    string
+	Issuer    string
+	Audience  string
+	UserClaim string
+}
+
+// JWKSVerifier verifies the RS256/384/512 and ES256/384/512 signed JWTs of an OpenID Connect provider with its JSON Web Key Set.
+type JWKSVerifier struct {
+	config JWKSConfig
+	client *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// NewJWKSVerifier creates the verifier and loads the keys.
+func NewJWKSVerifier(ctx context.Context, config JWKSConfig) (*JWKSVerifier, error) {
+	if len(config.UserClaim) == 0 {
+		config.UserClaim = "sub"
+	}
+	jv := &JWKSVerifier{config: config, client: &http.Client{Timeout: 10 * time.Second}}
+	if len(jv.config.Source) == 0 {
+		if len(jv.config.Issuer) == 0 {
+			return nil, errors.New("oidc verifier requires a key set or an issuer")
+		}
+		source, err := jv.discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		jv.config.Source = source
+	}
+	if err := jv.loadKeys(ctx); err != nil {
+		return nil, err
+	}
+	return jv, nil
+}
+
+func (jv *JWKSVerifier) VerifyToken(ctx context.Context, token string) (*VerifiedToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	key, err := jv.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return jv.checkClaims(claims)
+}
+
+// checkClaims checks the time, issuer and audience claims and returns the user of the token.
+func (jv *JWKSVerifier) checkClaims(claims map[string]interface{}) (*VerifiedToken, error) {
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing exp", ErrInvalidToken)
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	}
+	if len(jv.config.Issuer) != 0 && claims["iss"] != jv.config.Issuer {
+		return nil, fmt.Errorf("%w: wrong issuer", ErrInvalidToken)
+	}
+	if len(jv.config.Audience) != 0 && !hasAudience(claims["aud"], jv.config.Audience) {
+		return nil, fmt.Errorf("%w: wrong audience", ErrInvalidToken)
+	}
+	userID, _ := claims[jv.config.UserClaim].(string)
+	if len(userID) == 0 {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidToken, jv.config.UserClaim)
+	}
+	iat, _ := claims["iat"].(float64)
+	return &VerifiedToken{UserID: userID, IssuedAt: int64(iat), Expires: int64(exp)}, nil
+}
+
+// key returns the key with the id. Keys of a URL are downloaded again if the id is unknown.
+// A token without a key id can only be verified if the set has a single key.
+func (jv *JWKSVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	jv.mu.RLock()
+	key, ok := jv.findKey(kid)
+	canRefresh := isURL(jv.config.Source) && time.Since(jv.fetchedAt) > minKeyRefresh
+	jv.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if !canRefresh {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+	if err := jv.loadKeys(ctx); err != nil {
+		return nil, err
+	}
+	jv.mu.RLock()
+	defer jv.mu.RUnlock()
+	if key, ok := jv.findKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+}
+
+// findKey must be called with the lock held.
+func (jv *JWKSVerifier) findKey(kid string) (crypto.PublicKey, bool) {
+	if len(kid) == 0 && len(jv.keys) == 1 {
+		for _, key := range jv.keys {
+			return key, true
+		}
+	}
+	key, ok := jv.keys[kid]
+	return key, ok
+}
+
+// loadKeys reads the key set from the source. Keys which are not for signatures or have an unsupported type are skipped.
+func (jv *JWKSVerifier) loadKeys(ctx context.Context) error {
+	data, err := jv.read(ctx, jv.config.Source)
+	if err != nil {
+		return err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("invalid key set: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("key set has no usable keys")
+	}
+	jv.mu.Lock()
+	jv.keys = keys
+	jv.fetchedAt = time.Now()
+	jv.mu.Unlock()
+	return nil
+}
+
+// discover returns the key set URL of the OpenID configuration of the issuer.
+func (jv *JWKSVerifier) discover(ctx context.Context) (string, error) {
+	data, err := jv.read(ctx, strings.TrimSuffix(jv.config.Issuer, "/")+"/.well-known/openid-configuration")
+	if err != nil {
+		return "", err
+	}
+	var oc struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.Unmarshal(data, &oc); err != nil || len(oc.JWKSURI) == 0 {
+		return "", errors.New("openid configuration has no jwks_uri")
+	}
+	return oc.JWKSURI, nil
+}
+
+// read returns the content of the URL or the file.
+func (jv *JWKSVerifier) read(ctx context.Context, source string) ([]byte, error) {
+	if !isURL(source) {
+		return ioutil.ReadFile(source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := jv.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", source, res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// jwk is a JSON Web Key with the fields of the RSA and EC public keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// algorithm is a supported signature algorithm. The ES algorithms are only valid with the keys of their curve.
+type algorithm struct {
+	hash  crypto.Hash
+	curve elliptic.Curve
+}
+
+// algorithms are the supported values of the alg header.
+var algorithms = map[string]algorithm{
+	"RS256": {hash: crypto.SHA256},
+	"RS384": {hash: crypto.SHA384},
+	"RS512": {hash: crypto.SHA512},
+	"ES256": {hash: crypto.SHA256, curve: elliptic.P256()},
+	"ES384": {hash: crypto.SHA384, curve: elliptic.P384()},
+	"ES512": {hash: crypto.SHA512, curve: elliptic.P521()},
+}
+
+// verifySignature checks the signature of the signed part of the token with the key and the algorithm.
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	a, ok := algorithms[alg]
+	if !ok {
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+	}
+	h := a.hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if a.curve == nil && rsa.VerifyPKCS1v15(key, a.hash, digest, signature) == nil {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if a.curve == nil || key.Curve != a.curve {
+			break
+		}
+		size := (a.curve.Params().BitSize + 7) / 8
+		if len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			if ecdsa.Verify(key, digest, r, s) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+}
+
+func hasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://")
+}
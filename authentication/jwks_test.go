@@ -0,0 +1,200 @@
+package authentication
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://issuer.example.com"
+	testAudience = "flashqudo"
+)
+
+// testKeys are the private keys of the key set of the test verifier by their key ids.
+type testKeys struct {
+	rsa   *rsa.PrivateKey
+	p256  *ecdsa.PrivateKey
+	p384  *ecdsa.PrivateKey
+	p521  *ecdsa.PrivateKey
+	other *rsa.PrivateKey // not in the key set
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	var tk testKeys
+	var err error
+	if tk.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if tk.other, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []struct {
+		key   **ecdsa.PrivateKey
+		curve elliptic.Curve
+	}{{&tk.p256, elliptic.P256()}, {&tk.p384, elliptic.P384()}, {&tk.p521, elliptic.P521()}} {
+		if *k.key, err = ecdsa.GenerateKey(k.curve, rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &tk
+}
+
+// verifier writes the public keys to a key set file and returns its verifier.
+func (tk *testKeys) verifier(t *testing.T) *JWKSVerifier {
+	t.Helper()
+	enc := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	ecKey := func(kid, crv string, k *ecdsa.PrivateKey) jwk {
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return jwk{Kty: "EC", Kid: kid, Use: "sig", Crv: crv, X: enc(k.X.FillBytes(make([]byte, size))), Y: enc(k.Y.FillBytes(make([]byte, size)))}
+	}
+	set := map[string][]jwk{"keys": {
+		{Kty: "RSA", Kid: "rsa", Use: "sig", N: enc(tk.rsa.N.Bytes()), E: enc(big.NewInt(int64(tk.rsa.E)).Bytes())},
+		ecKey("p256", "P-256", tk.p256),
+		ecKey("p384", "P-384", tk.p384),
+		ecKey("p521", "P-521", tk.p521),
+	}}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	jv, err := NewJWKSVerifier(context.Background(), JWKSConfig{Source: path, Issuer: testIssuer, Audience: testAudience})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jv
+}
+
+// sign returns the token of the claims signed with the key by alg. The signature is empty if key is nil.
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	t.Helper()
+	segment := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := segment(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + segment(claims)
+	if key == nil {
+		return signed + "."
+	}
+
+	hash := crypto.SHA256
+	switch alg[2:] {
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, hash, digest); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// claims returns valid claims of the user with the changes.
+func claims(changes map[string]interface{}) map[string]interface{} {
+	now := time.Now()
+	c := map[string]interface{}{
+		"sub": "user-1",
+		"iss": testIssuer,
+		"aud": testAudience,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for k, v := range changes {
+		c[k] = v
+	}
+	return c
+}
+
+func TestJWKSVerifier(t *testing.T) {
+	tk := newTestKeys(t)
+	jv := tk.verifier(t)
+	valid := claims(nil)
+	parts := strings.Split(sign(t, "RS256", "rsa", tk.rsa, valid), ".")
+	forged := strings.Split(sign(t, "RS256", "rsa", tk.other, claims(map[string]interface{}{"sub": "user-2"})), ".")
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"RS256", sign(t, "RS256", "rsa", tk.rsa, valid), true},
+		{"RS384", sign(t, "RS384", "rsa", tk.rsa, valid), true},
+		{"RS512", sign(t, "RS512", "rsa", tk.rsa, valid), true},
+		{"ES256", sign(t, "ES256", "p256", tk.p256, valid), true},
+		{"ES384", sign(t, "ES384", "p384", tk.p384, valid), true},
+		{"ES512", sign(t, "ES512", "p521", tk.p521, valid), true},
+		{"audience in a list", sign(t, "RS256", "rsa", tk.rsa, claims(map[string]interface{}{"aud": []string{"other", testAudience}})), true},
+		{"expired", sign(t, "RS256", "rsa", tk.rsa, claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})), false},
+		{"not valid yet", sign(t, "RS256", "rsa", tk.rsa, claims(map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()})), false},
+		{"without exp", sign(t, "RS256", "rsa", tk.rsa, claims(map[string]interface{}{"exp": nil})), false},
+		{"wrong audience", sign(t, "RS256", "rsa", tk.rsa, claims(map[string]interface{}{"aud": "other"})), false},
+		{"wrong issuer", sign(t, "RS256", "rsa", tk.rsa, claims(map[string]interface{}{"iss": "https://other.example.com"})), false},
+		{"without subject", sign(t, "RS256", "rsa", tk.rsa, claims(map[string]interface{}{"sub": ""})), false},
+		{"ES256 with a P-384 key", sign(t, "ES256", "p384", tk.p384, valid), false},
+		{"ES384 with a P-256 key", sign(t, "ES384", "p256", tk.p256, valid), false},
+		{"ES512 with a P-256 key", sign(t, "ES512", "p256", tk.p256, valid), false},
+		{"RS256 with an EC key", sign(t, "RS256", "p256", tk.p256, valid), false},
+		{"ES256 with an RSA key", sign(t, "ES256", "rsa", tk.rsa, valid), false},
+		{"HS256", sign(t, "HS256", "rsa", tk.rsa, valid), false},
+		{"alg none", sign(t, "none", "rsa", nil, valid), false},
+		{"unknown kid", sign(t, "RS256", "unknown", tk.rsa, valid), false},
+		{"key not in the set", sign(t, "RS256", "rsa", tk.other, valid), false},
+		{"tampered claims", parts[0] + "." + forged[1] + "." + parts[2], false},
+		{"not a JWT", "token", false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			vt, err := jv.VerifyToken(context.Background(), tt.token)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("error %v, want %v", err, ErrInvalidToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if vt.UserID != "user-1" {
+				t.Fatalf("user %q, want %q", vt.UserID, "user-1")
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package authentication
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ironstone95/FlashQudoV2/handler"
+	"github.com/ironstone95/FlashQudoV2/principal"
+)
+
+// Logout revokes the ID token of the request. Personal access tokens are revoked with their own endpoint.
+func (a *Authenticator) Logout(rw http.ResponseWriter, r *http.Request) {
+	p, ok := principal.FromContext(r.Context())
+	if !ok {
+		a.log("Logout", principal.ErrNoPrincipal.Error())
+		handler.SendError(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+	if p.IsAccessToken() {
+		a.log("Logout", "requested with an access token")
+		handler.SendError(rw, "access tokens cannot log out, revoke the access token", http.StatusBadRequest)
+		return
+	}
+	if err := a.db.RevokeToken(r.Header.Get("X-Auth-Token")); err != nil {
+		a.log("Logout revoke", err.Error())
+		handler.SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	_, err := fmt.Fprint(rw, "logged out")
+	if err != nil {
+		a.log("Logout response", err.Error())
+	}
+	a.log("Logout", "SUCCESS")
+}
+
+// LogoutEverywhere revokes all ID tokens of the user of the request. If the identity provider supports it,
+// the tokens are revoked in the provider too, so they cannot be refreshed.
+func (a *Authenticator) LogoutEverywhere(rw http.ResponseWriter, r *http.Request) {
+	p, ok := principal.FromContext(r.Context())
+	if !ok {
+		a.log("LogoutEverywhere", principal.ErrNoPrincipal.Error())
+		handler.SendError(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+	if p.IsAccessToken() {
+		a.log("LogoutEverywhere", "requested with an access token")
+		handler.SendError(rw, "access tokens cannot log out, revoke the access token", http.StatusBadRequest)
+		return
+	}
+	userID := p.UserID
+	if revoker, ok := a.verifier.(Revoker); ok {
+		if err := revoker.RevokeUserTokens(r.Context(), userID); err != nil {
+			a.log("LogoutEverywhere provider", err.Error())
+			handler.SendError(rw, "server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := a.db.RevokeUserTokens(userID); err != nil {
+		a.log("LogoutEverywhere revoke", err.Error())
+		handler.SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	_, err := fmt.Fprint(rw, "logged out everywhere")
+	if err != nil {
+		a.log("LogoutEverywhere response", err.Error())
+	}
+	a.log("LogoutEverywhere", "SUCCESS")
+}
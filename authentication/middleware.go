@@ -1,171 +1,178 @@
 package authentication
 
 import (
-	"context"
 	"net/http"
+	"strings"
 
+	"github.com/ironstone95/FlashQudoV2/database"
 	"github.com/ironstone95/FlashQudoV2/handler"
+	"github.com/ironstone95/FlashQudoV2/model"
+	"github.com/ironstone95/FlashQudoV2/principal"
 
 	"github.com/gorilla/mux"
 )
 
-// AuthMW authenticates users with their tokens, uses database and firebase
+// AuthMW authenticates users with their ID tokens or personal access tokens and puts the principal
+// of the user into the request context.
 func (a *Authenticator) AuthMW(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		rw.Header().Add("Content-Type", "application/json")
-		if err := a.AuthToken(r.Header.Get("X-Auth-Token")); err != nil {
+		p, err := a.authenticate(r)
+		if err != nil {
 			a.log("AuthMW", err.Error())
 			handler.SendError(rw, "forbidden", http.StatusForbidden)
 			return
 		} else {
 			a.log("AuthMW", "SUCCESS")
-			next.ServeHTTP(rw, r)
+			next.ServeHTTP(rw, r.WithContext(principal.NewContext(r.Context(), p)))
 		}
 	})
 }
 
-// AuthGroupMemberMW authorizes the user if he/she is member of the group
-func (a *Authenticator) AuthGroupMemberMW(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		userID, err := a.db.GetIDFromToken(r.Header.Get("X-Auth-Token"))
-		if err != nil {
-			a.log("AuthGroupMemberMW", err.Error())
-			handler.SendError(rw, "forbidden", http.StatusForbidden)
-			return
-		}
-		groupID := mux.Vars(r)["groupID"]
-		if len(groupID) == 0 {
-			a.log("AuthGroupMemberMW", "groupID not in URL")
-			handler.SendError(rw, "bad request", http.StatusBadRequest)
-			return
-		}
-		isMember, err := a.db.IsGroupMember(groupID, userID)
+// authenticate returns the principal of the token of the request.
+func (a *Authenticator) authenticate(r *http.Request) (*principal.Principal, error) {
+	token := r.Header.Get("X-Auth-Token")
+	if database.IsAccessToken(token) {
+		t, err := a.AuthAccessToken(token, r.Method)
 		if err != nil {
-			a.log("AuthGroupMemberMW", err.Error())
-			handler.SendError(rw, "forbidden", http.StatusForbidden)
-			return
+			return nil, err
 		}
-		if !isMember {
-			a.log("AuthGroupMemberMW", err.Error())
-			handler.SendError(rw, "forbidden", http.StatusForbidden)
-			return
-		}
-		a.log("AuthGroupMemberMW", "SUCCESS")
-		next.ServeHTTP(rw, r)
-	})
+		return &principal.Principal{UserID: t.UserID, Kind: principal.KindAccessToken, Scopes: strings.Fields(t.Scopes)}, nil
+	}
+	userID, err := a.AuthToken(r.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+	return &principal.Principal{UserID: userID, Kind: principal.KindIDToken}, nil
 }
 
-// AuthBundleGroupMemberMW authorizes the user if he/she member of the group
-func (a *Authenticator) AuthBundleGroupMemberMW(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		userID, err := a.db.GetIDFromToken(r.Header.Get("X-Auth-Token"))
-		if err != nil {
-			a.log("AuthBundleGroupMemberMW", err.Error())
-			handler.SendError(rw, "forbidden", http.StatusForbidden)
-			return
-		}
-		bundleID := mux.Vars(r)["bundleID"]
-		canSeeBundle, err := a.db.CanSeeBundle(bundleID, userID)
-		if err != nil {
-			a.log("AuthBundleGroupMemberMW", err.Error())
-			handler.SendError(rw, "bad request", http.StatusBadRequest)
-			return
-		} else if !canSeeBundle {
-			a.log("AuthBundleGroupMemberMW", "user not authorized for this action")
-			handler.SendError(rw, "forbidden", http.StatusForbidden)
-			return
-		}
-		a.log("AuthBundleGroupMemberMW", "SUCCESS")
-		next.ServeHTTP(rw, r)
-	})
+// AuthGroupMW authorizes the user if his/her role in the param group has the permission perm
+// The user has no role in an unknown group, so it is forbidden like the groups of others and the response does not
+// tell whether the group exists. The other resource middlewares answer unknown resources the same way.
+func (a *Authenticator) AuthGroupMW(perm model.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			p, ok := principal.FromContext(r.Context())
+			if !ok {
+				a.log("AuthGroupMW", principal.ErrNoPrincipal.Error())
+				handler.SendError(rw, "forbidden", http.StatusForbidden)
+				return
+			}
+			groupID := mux.Vars(r)["groupID"]
+			if len(groupID) == 0 {
+				a.log("AuthGroupMW", "groupID not in URL")
+				handler.SendError(rw, "bad request", http.StatusBadRequest)
+				return
+			}
+			role, err := a.db.GetMemberRole(groupID, p.UserID)
+			if err != nil {
+				a.log("AuthGroupMW", err.Error())
+				handler.SendError(rw, "bad request", http.StatusBadRequest)
+				return
+			} else if !model.HasPermission(role, perm) {
+				a.log("AuthGroupMW", "FAIL")
+				handler.SendError(rw, "forbidden", http.StatusForbidden)
+				return
+			}
+			a.log("AuthGroupMW", "SUCCESS")
+			next.ServeHTTP(rw, r.WithContext(principal.NewContext(r.Context(), p.WithRole(role))))
+		})
+	}
 }
 
-// AuthGroupAdminMW authorizes the user if he/she the admin of the group param group
-func (a *Authenticator) AuthGroupAdminMW(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		userID, err := a.db.GetIDFromToken(r.Header.Get("X-Auth-Token"))
-		if err != nil {
-			a.log("AuthGroupAdminMW", err.Error())
-			handler.SendError(rw, "forbidden", http.StatusForbidden)
-			return
-		}
-		groupID := mux.Vars(r)["groupID"]
-		if isAdmin, err := a.db.IsAdmin(groupID, userID); err != nil {
-			a.log("AuthGroupAdminMW", err.Error())
-			handler.SendError(rw, "bad request", http.StatusBadRequest)
-			return
-		} else if !isAdmin {
-			a.log("AuthGroupAdminMW", "FAIL")
-			handler.SendError(rw, "forbidden", http.StatusForbidden)
-			return
-		}
-		a.log("AuthGroupAdminMW", "SUCCESS")
-		next.ServeHTTP(rw, r)
-	})
+// AuthBundleMW authorizes the user if his/her role in the group of the param bundle has the permission perm
+func (a *Authenticator) AuthBundleMW(perm model.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			p, ok := principal.FromContext(r.Context())
+			if !ok {
+				a.log("AuthBundleMW", principal.ErrNoPrincipal.Error())
+				handler.SendError(rw, "forbidden", http.StatusForbidden)
+				return
+			}
+			bundleID := mux.Vars(r)["bundleID"]
+			role, err := a.db.GetBundleRole(bundleID, p.UserID)
+			if err != nil {
+				a.log("AuthBundleMW", err.Error())
+				handler.SendError(rw, "bad request", http.StatusBadRequest)
+				return
+			} else if !model.HasPermission(role, perm) {
+				a.log("AuthBundleMW", "FAIL")
+				handler.SendError(rw, "forbidden", http.StatusForbidden)
+				return
+			}
+			a.log("AuthBundleMW", "SUCCESS")
+			next.ServeHTTP(rw, r.WithContext(principal.NewContext(r.Context(), p.WithRole(role))))
+		})
+	}
 }
 
-// AuthBundleGroupAdminMW authorizes the user if he/she the admin of the group of the param bundle
-func (a *Authenticator) AuthBundleGroupAdminMW(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		userID, err := a.db.GetIDFromToken(r.Header.Get("X-Auth-Token"))
-		if err != nil {
-			a.log("AuthBundleGroupAdminMW", err.Error())
-			handler.SendError(rw, "forbidden", http.StatusForbidden)
-			return
-		}
-		bundleID := mux.Vars(r)["bundleID"]
-		if canEditBundle, err := a.db.CanEditBundle(bundleID, userID); err != nil {
-			a.log("AuthBundleGroupAdminMW", err.Error())
-			handler.SendError(rw, "bad request", http.StatusBadRequest)
-			return
-		} else if !canEditBundle {
-			a.log("AuthBundleGroupAdminMW", "FAIL")
-			handler.SendError(rw, "forbidden", http.StatusForbidden)
-			return
-		}
-		a.log("AuthBundleGroupAdminMW", "SUCCESS")
-		next.ServeHTTP(rw, r)
-	})
+// AuthCardMW authorizes the user if his/her role in the group of the param card has the permission perm
+func (a *Authenticator) AuthCardMW(perm model.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			p, ok := principal.FromContext(r.Context())
+			if !ok {
+				a.log("AuthCardMW", principal.ErrNoPrincipal.Error())
+				handler.SendError(rw, "forbidden", http.StatusForbidden)
+				return
+			}
+			cardID := mux.Vars(r)["cardID"]
+			role, err := a.db.GetCardRole(cardID, p.UserID)
+			if err != nil {
+				a.log("AuthCardMW", err.Error())
+				handler.SendError(rw, "bad request", http.StatusBadRequest)
+				return
+			} else if !model.HasPermission(role, perm) {
+				a.log("AuthCardMW", "FAIL")
+				handler.SendError(rw, "forbidden", http.StatusForbidden)
+				return
+			}
+			a.log("AuthCardMW", "SUCCESS")
+			next.ServeHTTP(rw, r.WithContext(principal.NewContext(r.Context(), p.WithRole(role))))
+		})
+	}
 }
 
-// AuthCardGroupAdminMW authorizes the user if he/she is a member of the group of the param card
-func (a *Authenticator) AuthCardGroupAdminMW(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		userID, err := a.db.GetIDFromToken(r.Header.Get("X-Auth-Token"))
-		if err != nil {
-			a.log("AuthCardGroupAdminMW", err.Error())
-			handler.SendError(rw, "forbidden", http.StatusForbidden)
-			return
-		}
-
-		cardID := mux.Vars(r)["cardID"]
-		if canEditCard, err := a.db.CanEditCard(cardID, userID); err != nil {
-			a.log("AuthCardGroupAdminMW", err.Error())
-			handler.SendError(rw, "bad request", http.StatusBadRequest)
-			return
-		} else if !canEditCard {
-			a.log("AuthCardGroupAdminMW", "FAIL")
-			handler.SendError(rw, "forbidden", http.StatusForbidden)
-			return
-		}
-
-		a.log("AuthCardGroupAdminMW", "SUCCESS")
-		next.ServeHTTP(rw, r)
-	})
+// AuthTrashMW authorizes the user if his/her role in the group of the param item of the trash has the permission perm
+func (a *Authenticator) AuthTrashMW(perm model.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			p, ok := principal.FromContext(r.Context())
+			if !ok {
+				a.log("AuthTrashMW", principal.ErrNoPrincipal.Error())
+				handler.SendError(rw, "forbidden", http.StatusForbidden)
+				return
+			}
+			itemID := mux.Vars(r)["itemID"]
+			role, err := a.db.GetTrashItemRole(itemID, p.UserID)
+			if err != nil {
+				a.log("AuthTrashMW", err.Error())
+				handler.SendError(rw, "bad request", http.StatusBadRequest)
+				return
+			} else if !model.HasPermission(role, perm) {
+				a.log("AuthTrashMW", "FAIL")
+				handler.SendError(rw, "forbidden", http.StatusForbidden)
+				return
+			}
+			a.log("AuthTrashMW", "SUCCESS")
+			next.ServeHTTP(rw, r.WithContext(principal.NewContext(r.Context(), p.WithRole(role))))
+		})
+	}
 }
 
-// AuthUser authrozies if the user id of param username and header token belong each other.
+// AuthUser authorizes if the user id of param username and the principal of the request belong each other.
 func (a *Authenticator) AuthUser(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		username := mux.Vars(r)["username"]
 		userID, err := a.db.GetIDFromUsername(username)
 		if err != nil {
 			a.log("AuthUser getUserID", err.Error())
-			handler.SendError(rw, "bad request", http.StatusBadRequest)
+			handler.SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 			return
 		}
 
-		tokenID, err := a.db.GetIDFromToken(r.Header.Get("X-Auth-Token"))
+		tokenID, err := principal.UserID(r.Context())
 		if err != nil || userID != tokenID {
 			if err != nil {
 				a.log("AuthUser getUserTokenID", err.Error())
@@ -176,9 +183,6 @@ func (a *Authenticator) AuthUser(next http.Handler) http.Handler {
 			return
 		}
 
-		ctxRaw := context.Background()
-		ctx := context.WithValue(ctxRaw, "id", userID)
-		r2 := r.Clone(ctx)
-		next.ServeHTTP(rw, r2)
+		next.ServeHTTP(rw, r)
 	})
 }
@@ -0,0 +1,103 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/config"
+)
+
+// identity providers which can be chosen in the configuration
+const (
+	ProviderFirebase = "firebase"
+	ProviderOIDC     = "oidc"
+	ProviderStatic   = "static"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// VerifiedToken is the user of a verified ID token and the issue and expiration times of the token in unix seconds.
+type VerifiedToken struct {
+	UserID   string
+	IssuedAt int64
+	Expires  int64
+}
+
+// TokenVerifier verifies the ID tokens given by an identity provider.
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (*VerifiedToken, error)
+}
+
+// Revoker is implemented by the verifiers of the providers which can revoke the tokens of a user.
+// VerifyTokenAndCheckRevoked also fails if the token was revoked after it was verified. Its error wraps
+// ErrInvalidToken if the token is revoked or invalid, other errors are failures of the provider.
+type Revoker interface {
+	VerifyTokenAndCheckRevoked(ctx context.Context, token string) (*VerifiedToken, error)
+	RevokeUserTokens(ctx context.Context, userID string) error
+}
+
+// NewVerifier creates the verifier of the provider of the configuration, firebase by default.
+// firebase uses the default Google credentials (GOOGLE_APPLICATION_CREDENTIALS).
+// oidc verifies JWTs with the keys of JWKS, a file path or a URL, or discovers the keys from Issuer.
+// Issuer and Audience are checked if set, the user id is the claim UserClaim (sub by default).
+// static accepts the tokens of StaticTokens, a comma separated list of token:userID pairs. Only for testing.
+func NewVerifier(ctx context.Context, cfg config.Auth) (TokenVerifier, error) {
+	switch provider := cfg.Provider; provider {
+	case "", ProviderFirebase:
+		return NewFirebaseVerifier(ctx)
+	case ProviderOIDC:
+		return NewJWKSVerifier(ctx, JWKSConfig{
+			Source:    cfg.JWKS,
+			Issuer:    cfg.Issuer,
+			Audience:  cfg.Audience,
+			UserClaim: cfg.UserClaim,
+		})
+	case ProviderStatic:
+		return ParseStaticVerifier(cfg.StaticTokens)
+	default:
+		return nil, fmt.Errorf("unknown auth provider %q, must be firebase, oidc or static", provider)
+	}
+}
+
+// StaticVerifier accepts a fixed set of tokens. It must only be used for testing and local development.
+type StaticVerifier struct {
+	tokens   map[string]string
+	validFor time.Duration
+}
+
+// NewStaticVerifier creates a verifier which accepts the tokens of the map, token to user id.
+func NewStaticVerifier(tokens map[string]string) *StaticVerifier {
+	return &StaticVerifier{tokens: tokens, validFor: time.Hour}
+}
+
+// ParseStaticVerifier creates a static verifier from a comma separated list of token:userID pairs.
+func ParseStaticVerifier(pairs string) (*StaticVerifier, error) {
+	tokens := make(map[string]string)
+	for _, pair := range strings.Split(pairs, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		i := strings.LastIndex(pair, ":")
+		if i <= 0 || i == len(pair)-1 {
+			return nil, fmt.Errorf("static token %q must be token:userID", pair)
+		}
+		tokens[pair[:i]] = pair[i+1:]
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("static verifier requires at least one token")
+	}
+	return NewStaticVerifier(tokens), nil
+}
+
+func (sv *StaticVerifier) VerifyToken(ctx context.Context, token string) (*VerifiedToken, error) {
+	userID, ok := sv.tokens[token]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	now := time.Now()
+	return &VerifiedToken{UserID: userID, IssuedAt: now.Unix(), Expires: now.Add(sv.validFor).Unix()}, nil
+}
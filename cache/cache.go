@@ -0,0 +1,129 @@
+// Package cache is an in-process key value cache whose entries expire after a fixed time.
+// The hits and misses of every cache are published with expvar under "cache", see /debug/vars.
+package cache
+
+import (
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stats holds the counters of all caches as <name>.hits and <name>.misses.
+var stats = expvar.NewMap("cache")
+
+type entry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// Cache is safe for concurrent use. Expired entries are removed when they are read or when the cache is swept.
+type Cache struct {
+	name string
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]entry
+	sets    int
+	gen     uint64 // counts the removals, see Generation
+}
+
+// New creates a cache whose entries live for ttl. If ttl is not positive, nothing is cached.
+func New(name string, ttl time.Duration) *Cache {
+	return &Cache{name: name, ttl: ttl, entries: make(map[string]entry)}
+}
+
+// Get returns the value of the key if it is cached and not expired.
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok && time.Now().After(e.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.mu.Unlock()
+	if ok {
+		stats.Add(c.name+".hits", 1)
+		return e.value, true
+	}
+	stats.Add(c.name+".misses", 1)
+	return nil, false
+}
+
+// Set caches the value of the key for the ttl of the cache.
+func (c *Cache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, value)
+}
+
+// Generation returns the current generation of the cache, it changes whenever an entry is removed.
+func (c *Cache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// SetIfGeneration caches the value like Set if nothing was removed since gen was returned by Generation.
+// A value loaded after reading the generation is not cached if the source changed while it was being loaded,
+// as the writer removes the stale entries after the change.
+func (c *Cache) SetIfGeneration(key string, value interface{}, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen == gen {
+		c.set(key, value)
+	}
+}
+
+// set must be called with the lock held.
+func (c *Cache) set(key string, value interface{}) {
+	if c.ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	c.entries[key] = entry{value: value, expires: now.Add(c.ttl)}
+	// sweep now and then so that keys which are not read again do not pile up
+	c.sets++
+	if c.sets%1024 == 0 {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+}
+
+// Delete removes the key.
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.gen++
+	c.mu.Unlock()
+}
+
+// DeletePrefix removes the keys which start with prefix.
+func (c *Cache) DeletePrefix(prefix string) {
+	c.DeleteFunc(func(key string, _ interface{}) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// DeleteFunc removes the entries for which remove returns true.
+func (c *Cache) DeleteFunc(remove func(key string, value interface{}) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for k, e := range c.entries {
+		if remove(k, e.value) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// Clear removes all entries.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	c.entries = make(map[string]entry)
+	c.gen++
+	c.mu.Unlock()
+}
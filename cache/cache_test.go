@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+)
+
+// counter returns the expvar counter of the cache, hits or misses.
+func counter(t *testing.T, name, kind string) int64 {
+	t.Helper()
+	v, ok := stats.Get(name + "." + kind).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+	return v.Value()
+}
+
+func TestGetSet(t *testing.T) {
+	c := New(t.Name(), time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("empty cache has a")
+	}
+	c.Set("a", 1)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("a is %v, %t, want 1", v, ok)
+	}
+	c.Set("a", 2)
+	if v, _ := c.Get("a"); v != 2 {
+		t.Fatalf("a is %v after the second Set, want 2", v)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c := New(t.Name(), 20*time.Millisecond)
+	c.Set("a", 1)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a expired before the ttl")
+	}
+	time.Sleep(40 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("a did not expire after the ttl")
+	}
+	if len(c.entries) != 0 {
+		t.Fatalf("%d entries after reading the expired one, want 0", len(c.entries))
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		c := New(t.Name(), ttl)
+		c.Set("a", 1)
+		c.SetIfGeneration("b", 2, c.Generation())
+		if _, ok := c.Get("a"); ok {
+			t.Fatalf("cache with ttl %v has a", ttl)
+		}
+		if _, ok := c.Get("b"); ok {
+			t.Fatalf("cache with ttl %v has b", ttl)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name   string
+		delete func(c *Cache)
+		want   []string // the remaining keys
+	}{
+		{"Delete", func(c *Cache) { c.Delete("user1:group:g1") }, []string{"user1:card:c1", "user2:group:g1", "user10:group:g1"}},
+		{"Delete unknown key", func(c *Cache) { c.Delete("user3:group:g1") }, []string{"user1:group:g1", "user1:card:c1", "user2:group:g1", "user10:group:g1"}},
+		{"DeletePrefix", func(c *Cache) { c.DeletePrefix("user1:") }, []string{"user2:group:g1", "user10:group:g1"}},
+		{"DeleteFunc", func(c *Cache) {
+			c.DeleteFunc(func(key string, value interface{}) bool { return strings.HasSuffix(key, ":g1") && value == "viewer" })
+		}, []string{"user1:group:g1", "user1:card:c1"}},
+		{"Clear", func(c *Cache) { c.Clear() }, nil},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(t.Name(), time.Minute)
+			c.Set("user1:group:g1", "owner")
+			c.Set("user1:card:c1", "owner")
+			c.Set("user2:group:g1", "viewer")
+			c.Set("user10:group:g1", "viewer")
+			tt.delete(c)
+			if len(c.entries) != len(tt.want) {
+				t.Fatalf("%d entries, want %d", len(c.entries), len(tt.want))
+			}
+			for _, key := range tt.want {
+				if _, ok := c.Get(key); !ok {
+					t.Errorf("%s was removed", key)
+				}
+			}
+		})
+	}
+}
+
+func TestSetIfGeneration(t *testing.T) {
+	c := New(t.Name(), time.Minute)
+	gen := c.Generation()
+	c.SetIfGeneration("a", 1, gen)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a is not cached without a removal")
+	}
+
+	// a value loaded before a removal is not cached after it
+	gen = c.Generation()
+	c.Delete("b")
+	c.SetIfGeneration("b", 2, gen)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("b is cached after a removal since its load")
+	}
+	for _, remove := range []func(){
+		func() { c.DeletePrefix("x") },
+		func() { c.DeleteFunc(func(string, interface{}) bool { return false }) },
+		c.Clear,
+	} {
+		gen = c.Generation()
+		remove()
+		if c.Generation() == gen {
+			t.Fatal("removal did not change the generation")
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	c := New(t.Name(), time.Minute)
+	hits, misses := counter(t, t.Name(), "hits"), counter(t, t.Name(), "misses")
+	c.Set("a", 1)
+	c.Get("a")
+	c.Get("a")
+	c.Get("b")
+	if got := counter(t, t.Name(), "hits") - hits; got != 2 {
+		t.Errorf("%d hits, want 2", got)
+	}
+	if got := counter(t, t.Name(), "misses") - misses; got != 1 {
+		t.Errorf("%d misses, want 1", got)
+	}
+	if expvar.Get("cache") == nil {
+		t.Error("cache stats are not published")
+	}
+}
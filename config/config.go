@@ -0,0 +1,291 @@
+// Package config loads the configuration of the server. The defaults are overridden by a JSON file, then by the
+// environment variables and last by the command line flags. The defaults are safe for production: the data is
+// never deleted and no test data is added unless it is asked for.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// database drivers
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// log levels
+const (
+	LogDebug = "debug" // logs every request and database error
+	LogInfo  = "info"
+)
+
+type Config struct {
+	Listen             string   `json:"listen"`
+	TLSCertFile        string   `json:"tlsCertFile"` // TLS is served if both files are set
+	TLSKeyFile         string   `json:"tlsKeyFile"`
+	LogLevel           string   `json:"logLevel"`
+	Database           Database `json:"database"`
+	Auth               Auth     `json:"auth"`
+	TrashRetentionDays int      `json:"trashRetentionDays"` // 0 keeps the trash forever
+	AuthCacheSeconds   int      `json:"authCacheSeconds"`   // 0 disables the token and role cache
+	DebugVars          bool     `json:"debugVars"`          // serves the unauthenticated /debug/vars
+}
+
+// Database is the connection and the startup actions of the database. DSN is used as is if it is set,
+// otherwise it is built from the other connection settings. SQLite only uses File.
+type Database struct {
+	Driver                 string `json:"driver"`
+	DSN                    string `json:"dsn"`
+	File                   string `json:"file"` // SQLite database file, :memory: keeps the data in memory
+	Host                   string `json:"host"`
+	Port                   int    `json:"port"`
+	User                   string `json:"user"`
+	Password               string `json:"password"`
+	Name                   string `json:"name"`
+	SSLMode                string `json:"sslMode"`
+	MaxOpenConns           int    `json:"maxOpenConns"`
+	MaxIdleConns           int    `json:"maxIdleConns"`
+	ConnMaxLifetimeSeconds int    `json:"connMaxLifetimeSeconds"`
+	Migrate                bool   `json:"migrate"` // applies the pending migrations on start
+	Reset                  bool   `json:"reset"`   // deletes all data on start
+	Seed                   bool   `json:"seed"`    // adds the test data after the reset
+}
+
+// Auth selects the identity provider which verifies the ID tokens, see authentication.NewVerifier.
+type Auth struct {
+	Provider     string `json:"provider"`
+	JWKS         string `json:"jwks"`
+	Issuer       string `json:"issuer"`
+	Audience     string `json:"audience"`
+	UserClaim    string `json:"userClaim"`
+	StaticTokens string `json:"staticTokens"`
+}
+
+// Default returns the default configuration.
+func Default() *Config {
+	return &Config{
+		Listen:   ":5000",
+		LogLevel: LogInfo,
+		Database: Database{
+			Driver:                 DriverPostgres,
+			File:                   "flashqudo.db",
+			Host:                   "localhost",
+			Port:                   5432,
+			MaxOpenConns:           25,
+			MaxIdleConns:           5,
+			ConnMaxLifetimeSeconds: 1800,
+			Migrate:                true,
+		},
+		Auth:               Auth{Provider: "firebase"},
+		TrashRetentionDays: 30,
+		AuthCacheSeconds:   30,
+	}
+}
+
+// Load reads the configuration from the file of the -config flag or CONFIG_FILE, the environment and the flags
+// in args. Returns the arguments after the flags.
+func Load(args []string) (*Config, []string, error) {
+	c := Default()
+	settings := c.settings()
+	fs := flag.NewFlagSet("flashqudo", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "JSON configuration file")
+	flags := make(map[string]string)
+	for _, s := range settings {
+		if len(s.flag) == 0 {
+			continue
+		}
+		_, isBool := s.value.(*bool)
+		fs.Var(&rawFlag{name: s.flag, flags: flags, isBool: isBool}, s.flag, s.usage+" ("+s.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if len(*file) != 0 {
+		if err := c.readFile(*file); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, s := range settings {
+		if v := os.Getenv(s.env); len(v) != 0 {
+			if err := s.set(v); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if v, ok := flags[s.flag]; ok {
+			if err := s.set(v); err != nil {
+				return nil, nil, fmt.Errorf("-%s: %w", s.flag, err)
+			}
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return c, fs.Args(), nil
+}
+
+// Validate checks the configuration is consistent.
+func (c *Config) Validate() error {
+	if c.LogLevel != LogDebug && c.LogLevel != LogInfo {
+		return fmt.Errorf("log level must be %s or %s", LogDebug, LogInfo)
+	}
+	if (len(c.TLSCertFile) == 0) != (len(c.TLSKeyFile) == 0) {
+		return errors.New("TLS requires both the certificate and the key file")
+	}
+	if c.Database.Driver != DriverPostgres && c.Database.Driver != DriverSQLite {
+		return fmt.Errorf("database driver must be %s or %s", DriverPostgres, DriverSQLite)
+	}
+	if c.Database.Seed && !c.Database.Reset {
+		return errors.New("seeding the test data requires resetting the database")
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 || c.Database.ConnMaxLifetimeSeconds < 0 {
+		return errors.New("database pool settings cannot be negative")
+	}
+	if c.TrashRetentionDays < 0 || c.AuthCacheSeconds < 0 {
+		return errors.New("trash retention and auth cache time cannot be negative")
+	}
+	return nil
+}
+
+// Debug reports if every request and database error should be logged.
+func (c *Config) Debug() bool {
+	return c.LogLevel == LogDebug
+}
+
+// TLS reports if the server should serve TLS.
+func (c *Config) TLS() bool {
+	return len(c.TLSCertFile) != 0
+}
+
+func (c *Config) TrashRetention() time.Duration {
+	return time.Duration(c.TrashRetentionDays) * 24 * time.Hour
+}
+
+func (c *Config) AuthCacheTTL() time.Duration {
+	return time.Duration(c.AuthCacheSeconds) * time.Second
+}
+
+// ConnString returns the DSN of the database. SQLite connections enforce the foreign keys and wait for the
+// lock of another connection instead of failing.
+func (d Database) ConnString() string {
+	if len(d.DSN) != 0 {
+		return d.DSN
+	}
+	if d.Driver == DriverSQLite {
+		return "file:" + d.File + "?_foreign_keys=on&_busy_timeout=5000"
+	}
+	parts := []string{"host=" + d.Host, "port=" + strconv.Itoa(d.Port)}
+	for _, kv := range [][2]string{{"user", d.User}, {"password", d.Password}, {"dbname", d.Name}, {"sslmode", d.SSLMode}} {
+		if len(kv[1]) != 0 {
+			parts = append(parts, kv[0]+"="+kv[1])
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (d Database) ConnMaxLifetime() time.Duration {
+	return time.Duration(d.ConnMaxLifetimeSeconds) * time.Second
+}
+
+// readFile reads the JSON file over the configuration. Unknown keys are rejected to catch typos.
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// setting is a value which can be set with an environment variable and a flag. Secrets have no flag,
+// so that they do not show up in the process list.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	value interface{} // *string, *int or *bool
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"listen", "LISTEN_ADDR", "address to listen on", &c.Listen},
+		{"tls-cert", "TLS_CERT_FILE", "TLS certificate file", &c.TLSCertFile},
+		{"tls-key", "TLS_KEY_FILE", "TLS key file", &c.TLSKeyFile},
+		{"log-level", "LOG_LEVEL", "debug or info", &c.LogLevel},
+		{"db-driver", "DB_DRIVER", "postgres or sqlite", &c.Database.Driver},
+		{"", "DB_DSN", "", &c.Database.DSN},
+		{"db-file", "DB_FILE", "SQLite database file", &c.Database.File},
+		{"db-host", "DB_HOST", "database host", &c.Database.Host},
+		{"db-port", "DB_PORT", "database port", &c.Database.Port},
+		{"db-user", "DB_USER", "database user", &c.Database.User},
+		{"", "DB_PASSWORD", "", &c.Database.Password},
+		{"db-name", "DB_DATABASE_NAME", "database name", &c.Database.Name},
+		{"db-sslmode", "DB_SSLMODE", "database sslmode", &c.Database.SSLMode},
+		{"db-max-open-conns", "DB_MAX_OPEN_CONNS", "maximum open database connections, 0 is unlimited", &c.Database.MaxOpenConns},
+		{"db-max-idle-conns", "DB_MAX_IDLE_CONNS", "maximum idle database connections", &c.Database.MaxIdleConns},
+		{"db-conn-max-lifetime", "DB_CONN_MAX_LIFETIME_SECONDS", "maximum lifetime of a database connection in seconds, 0 is unlimited", &c.Database.ConnMaxLifetimeSeconds},
+		{"db-migrate", "DB_MIGRATE", "apply the pending migrations on start", &c.Database.Migrate},
+		{"db-reset", "DB_RESET", "DELETE ALL DATA on start", &c.Database.Reset},
+		{"db-seed", "DB_SEED", "add the test data after the reset", &c.Database.Seed},
+		{"auth-provider", "AUTH_PROVIDER", "firebase, oidc or static", &c.Auth.Provider},
+		{"auth-jwks", "AUTH_JWKS", "file or URL of the JSON Web Key Set of the oidc provider", &c.Auth.JWKS},
+		{"auth-issuer", "AUTH_ISSUER", "issuer of the ID tokens", &c.Auth.Issuer},
+		{"auth-audience", "AUTH_AUDIENCE", "audience of the ID tokens", &c.Auth.Audience},
+		{"auth-user-claim", "AUTH_USER_CLAIM", "claim of the user id", &c.Auth.UserClaim},
+		{"", "AUTH_STATIC_TOKENS", "", &c.Auth.StaticTokens},
+		{"trash-retention-days", "TRASH_RETENTION_DAYS", "days the deleted bundles and cards stay in the trash, 0 is forever", &c.TrashRetentionDays},
+		{"auth-cache-seconds", "AUTH_CACHE_SECONDS", "seconds the tokens and roles are cached, 0 disables the cache", &c.AuthCacheSeconds},
+		{"debug-vars", "DEBUG_VARS", "serve the unauthenticated /debug/vars", &c.DebugVars},
+	}
+}
+
+func (s setting) set(v string) error {
+	switch p := s.value.(type) {
+	case *string:
+		*p = v
+	case *int:
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		*p = i
+	case *bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", v)
+		}
+		*p = b
+	}
+	return nil
+}
+
+// rawFlag keeps the value of a flag to apply it after the file and the environment.
+type rawFlag struct {
+	name   string
+	flags  map[string]string
+	isBool bool
+}
+
+func (f *rawFlag) String() string { return "" }
+
+func (f *rawFlag) Set(v string) error {
+	f.flags[f.name] = v
+	return nil
+}
+
+func (f *rawFlag) IsBoolFlag() bool { return f.isBool }
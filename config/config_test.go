@@ -0,0 +1,162 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setenv sets the environment variable until the end of the test.
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	old, ok := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// clearEnv empties the environment variables of the settings, so that the environment of the test run is ignored.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	setenv(t, "CONFIG_FILE", "")
+	for _, s := range Default().settings() {
+		setenv(t, s.env, "")
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	file := writeFile(t, `{"listen": ":6000", "logLevel": "debug", "database": {"port": 6432, "name": "file"}, "authCacheSeconds": 60}`)
+	setenv(t, "DB_PORT", "7432")
+	setenv(t, "DB_DATABASE_NAME", "env")
+	setenv(t, "AUTH_CACHE_SECONDS", "90")
+	c, args, err := Load([]string{"-config", file, "-db-port", "8432", "-db-migrate=false", "serve"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"default", c.Database.Host, "localhost"},
+		{"default bool", c.Database.Reset, false},
+		{"file", c.Listen, ":6000"},
+		{"file over default", c.LogLevel, LogDebug},
+		{"env over file", c.Database.Name, "env"},
+		{"env over file int", c.AuthCacheSeconds, 90},
+		{"flag over env", c.Database.Port, 8432},
+		{"flag over default", c.Database.Migrate, false},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if len(args) != 1 || args[0] != "serve" {
+		t.Errorf("args %v, want [serve]", args)
+	}
+}
+
+func TestLoadFileFromEnv(t *testing.T) {
+	clearEnv(t)
+	setenv(t, "CONFIG_FILE", writeFile(t, `{"listen": ":6000"}`))
+	c, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Listen != ":6000" {
+		t.Fatalf("listen %q, want %q", c.Listen, ":6000")
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	clearEnv(t)
+	c, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *c != *Default() {
+		t.Fatalf("%+v, want the defaults %+v", *c, *Default())
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		file string
+		args []string
+	}{
+		{name: "unknown key in the file", file: `{"listn": ":6000"}`},
+		{name: "invalid file", file: `{"listen": `},
+		{name: "missing file", args: []string{"-config", "does-not-exist.json"}},
+		{name: "number in env", env: map[string]string{"DB_PORT": "port"}},
+		{name: "bool in flag", args: []string{"-db-reset=maybe"}},
+		{name: "unknown flag", args: []string{"-unknown"}},
+		{name: "invalid result", env: map[string]string{"DB_SEED": "true"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				setenv(t, k, v)
+			}
+			args := tt.args
+			if len(tt.file) != 0 {
+				args = append([]string{"-config", writeFile(t, tt.file)}, args...)
+			}
+			if _, _, err := Load(args); err == nil {
+				t.Fatal("no error")
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		ok     bool
+	}{
+		{"defaults", func(c *Config) {}, true},
+		{"reset", func(c *Config) { c.Database.Reset = true }, true},
+		{"seed with reset", func(c *Config) { c.Database.Reset, c.Database.Seed = true, true }, true},
+		{"seed without reset", func(c *Config) { c.Database.Seed = true }, false},
+		{"sqlite", func(c *Config) { c.Database.Driver = DriverSQLite }, true},
+		{"unknown driver", func(c *Config) { c.Database.Driver = "mysql" }, false},
+		{"unknown log level", func(c *Config) { c.LogLevel = "trace" }, false},
+		{"TLS", func(c *Config) { c.TLSCertFile, c.TLSKeyFile = "cert.pem", "key.pem" }, true},
+		{"TLS without key", func(c *Config) { c.TLSCertFile = "cert.pem" }, false},
+		{"TLS without certificate", func(c *Config) { c.TLSKeyFile = "key.pem" }, false},
+		{"negative pool", func(c *Config) { c.Database.MaxIdleConns = -1 }, false},
+		{"negative trash retention", func(c *Config) { c.TrashRetentionDays = -1 }, false},
+		{"negative auth cache", func(c *Config) { c.AuthCacheSeconds = -1 }, false},
+	}
+	for _, tt := range tests {
+		c := Default()
+		tt.change(c)
+		if err := c.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: error %v, want ok %t", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+	"gorm.io/gorm"
+)
+
+// accessTokenPrefixLength is the length of the start of a token which is stored to recognize the token.
+const accessTokenPrefixLength = len(model.AccessTokenPrefix) + 4
+
+// IsAccessToken checks the token is a personal access token, not an ID token.
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, model.AccessTokenPrefix)
+}
+
+// InsertAccessToken creates a personal access token. Only the returned response contains the token itself.
+func (db *Database) InsertAccessToken(token model.AccessToken) (*response.AccessToken, error) {
+	if len(token.UserID) == 0 || len(token.Name) == 0 {
+		return nil, ErrParamNotFound
+	}
+	raw := generator.CreateAccessToken(model.AccessTokenPrefix)
+	token.ID = generator.CreateID()
+	token.Hash = hashToken(raw)
+	token.Prefix = raw[:accessTokenPrefixLength]
+	token.CreatedAt = time.Now()
+	if err := db.db.Create(&token).Error; err != nil {
+		db.logError("InsertAccessToken", err.Error(), token.UserID, token.Name)
+		return nil, ErrGormCreate
+	}
+	res := accessTokenResponse(token)
+	res.Token = raw
+	return &res, nil
+}
+
+// GetAccessTokens returns the personal access tokens of the user, the latest first.
+func (db *Database) GetAccessTokens(userID string) ([]response.AccessToken, error) {
+	if len(userID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	var tokens []model.AccessToken
+	if err := db.db.Where("user_id = ?", userID).Order("created_at desc, id").Find(&tokens).Error; err != nil {
+		db.logError("GetAccessTokens", err.Error(), userID)
+		return nil, ErrGormGet
+	}
+	res := make([]response.AccessToken, 0, len(tokens))
+	for _, t := range tokens {
+		res = append(res, accessTokenResponse(t))
+	}
+	return res, nil
+}
+
+// RevokeAccessToken revokes the personal access token of the user.
+func (db *Database) RevokeAccessToken(userID, tokenID string) error {
+	if len(userID) == 0 || len(tokenID) == 0 {
+		return ErrParamNotFound
+	}
+	res := db.db.Model(&model.AccessToken{}).
+		Where("id = ? and user_id = ? and revoked_at is null", tokenID, userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		db.logError("RevokeAccessToken", res.Error.Error(), userID, tokenID)
+		return ErrGormUpdate
+	}
+	if res.RowsAffected == 0 {
+		return ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+// AuthAccessToken returns the personal access token if it is not expired or revoked, and records its use.
+func (db *Database) AuthAccessToken(token string) (*model.AccessToken, error) {
+	t := model.AccessToken{}
+	if err := db.db.Where("hash = ?", hashToken(token)).First(&t).Error; err != nil {
+		db.logError("AuthAccessToken", err.Error())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccessTokenNotFound
+		}
+		return nil, ErrGormGet
+	}
+	now := time.Now()
+	if t.RevokedAt != nil || (t.ExpiresAt != nil && t.ExpiresAt.Before(now)) {
+		return nil, ErrAccessTokenInvalid
+	}
+	if err := db.db.Model(&t).UpdateColumn("last_used_at", now).Error; err != nil {
+		db.logError("AuthAccessToken", err.Error())
+	}
+	t.LastUsedAt = &now
+	return &t, nil
+}
+
+func accessTokenResponse(t model.AccessToken) response.AccessToken {
+	return response.AccessToken{
+		ID:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Scopes:     strings.Fields(t.Scopes),
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		RevokedAt:  t.RevokedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
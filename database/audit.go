@@ -0,0 +1,82 @@
+package database
+
+import (
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+// AuditQuery filters the audit log of a group. Empty fields do not filter.
+type AuditQuery struct {
+	ActorID string
+	Action  string
+	From    *time.Time
+	To      *time.Time
+}
+
+// InsertAuditEntry records the entry in the audit log. If the group of the entry is empty, it is found from
+// the bundle or card target, including the ones in the trash.
+func (db *Database) InsertAuditEntry(entry model.AuditEntry) error {
+	if len(entry.GroupID) == 0 {
+		var groupIDs []string
+		tx := db.db.Table("bundles").Select("bundles.group_id").Limit(1)
+		switch entry.TargetType {
+		case model.TargetBundle:
+			tx = tx.Where("bundles.id = ?", entry.TargetID)
+		case model.TargetCard:
+			tx = tx.Joins("join cards on cards.bundle_id = bundles.id").Where("cards.id = ?", entry.TargetID)
+		default:
+			return ErrParamNotFound
+		}
+		if err := tx.Find(&groupIDs).Error; err != nil {
+			db.logError("InsertAuditEntry", err.Error(), entry)
+			return ErrGormGet
+		}
+		if len(groupIDs) == 0 {
+			return ErrGroupNotFound
+		}
+		entry.GroupID = groupIDs[0]
+	}
+	if len(entry.ActorID) == 0 || len(entry.Action) == 0 {
+		return ErrParamNotFound
+	}
+	entry.ID = generator.CreateID()
+	entry.CreatedAt = time.Now()
+	if err := db.db.Create(&entry).Error; err != nil {
+		db.logError("InsertAuditEntry", err.Error(), entry)
+		return ErrGormCreate
+	}
+	return nil
+}
+
+// GetAuditEntries returns the audit log of the group filtered by q, the latest first.
+func (db *Database) GetAuditEntries(groupID string, q AuditQuery, page, limit int) ([]response.AuditEntry, error) {
+	if len(groupID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	tx := db.db.Table("audit_entries").
+		Select("audit_entries.*, users.username as actor_username").
+		Joins("left join users on users.id = audit_entries.actor_id").
+		Where("audit_entries.group_id = ?", groupID)
+	if len(q.ActorID) != 0 {
+		tx = tx.Where("audit_entries.actor_id = ?", q.ActorID)
+	}
+	if len(q.Action) != 0 {
+		tx = tx.Where("audit_entries.action = ?", q.Action)
+	}
+	if q.From != nil {
+		tx = tx.Where("audit_entries.created_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		tx = tx.Where("audit_entries.created_at < ?", *q.To)
+	}
+	entries := []response.AuditEntry{}
+	if err := tx.Order("audit_entries.created_at desc, audit_entries.id").
+		Limit(limit).Offset((page - 1) * limit).Scan(&entries).Error; err != nil {
+		db.logError("GetAuditEntries", err.Error(), groupID, q, page, limit)
+		return nil, ErrGormGet
+	}
+	return entries, nil
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/cache"
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+// The ID tokens and the roles of the users are cached, so that an authorized request does not query them again
+// and again. Writes of this process invalidate the entries after they are committed, writes of other processes
+// are seen after the TTL. A value is only cached if nothing was invalidated while it was loaded, so that a
+// lookup which read the database before a commit cannot cache the stale value after the invalidation.
+// Personal access tokens are not cached, their last use is updated on every request anyway.
+//
+// Role keys are <userID>:<kind>:<id>, so that the roles of a user are dropped when his/her memberships change.
+const (
+	roleGroup  = "group"
+	roleBundle = "bundle"
+	roleCard   = "card"
+	roleTrash  = "trash"
+)
+
+// EnableAuthCache caches the ID tokens and the roles for ttl. The caches are disabled until it is called.
+func (db *Database) EnableAuthCache(ttl time.Duration) {
+	db.tokens = cache.New("tokens", ttl)
+	db.roles = cache.New("roles", ttl)
+}
+
+// cachedToken returns the saved ID token with the hash if it is cached and not expired.
+func (db *Database) cachedToken(hash string) (model.Token, bool) {
+	v, ok := db.tokens.Get(hash)
+	if !ok {
+		return model.Token{}, false
+	}
+	t := v.(model.Token)
+	if t.Expires < time.Now().Unix() {
+		db.tokens.Delete(hash)
+		return model.Token{}, false
+	}
+	return t, true
+}
+
+// forgetUserTokens drops the cached ID tokens of the user.
+func (db *Database) forgetUserTokens(userID string) {
+	db.afterCommit(func() {
+		db.tokens.DeleteFunc(func(_ string, v interface{}) bool {
+			return v.(model.Token).UserID == userID
+		})
+	})
+}
+
+// cachedRole returns the cached role of the user for the group, bundle, card or trash item,
+// or loads and caches it.
+func (db *Database) cachedRole(kind, id, userID string, load func() (string, error)) (string, error) {
+	key := userID + ":" + kind + ":" + id
+	if role, ok := db.roles.Get(key); ok {
+		return role.(string), nil
+	}
+	gen := db.roles.Generation()
+	role, err := load()
+	if err != nil {
+		return "", err
+	}
+	// the uncommitted writes of a transaction must not be cached
+	if db.afterTx == nil {
+		db.roles.SetIfGeneration(key, role, gen)
+	}
+	return role, nil
+}
+
+// forgetRoles drops the cached roles of the users after their memberships change.
+func (db *Database) forgetRoles(userIDs ...string) {
+	db.afterCommit(func() {
+		for _, userID := range userIDs {
+			db.roles.DeletePrefix(userID + ":")
+		}
+	})
+}
+
+// forgetAllRoles drops every cached role. Deleting, restoring and purging bundles and cards changes the roles
+// of all members for them, and such writes are rare compared to the lookups.
+func (db *Database) forgetAllRoles() {
+	db.afterCommit(db.roles.Clear)
+}
+
+// afterCommit runs the invalidation f when the transaction of Transaction ends, or now outside of it.
+// The callers invalidate after their own writes, which are committed unless they run in such a transaction.
+func (db *Database) afterCommit(f func()) {
+	if db.afterTx != nil {
+		*db.afterTx = append(*db.afterTx, f)
+		return
+	}
+	f()
+}
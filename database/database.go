@@ -1,56 +1,87 @@
 package database
 
 import (
-	"fmt"
 	"log"
 	"math/rand"
 	"os"
 	"time"
 
 	"github.com/bxcodec/faker/v3"
+	"github.com/ironstone95/FlashQudoV2/cache"
+	"github.com/ironstone95/FlashQudoV2/config"
 	"github.com/ironstone95/FlashQudoV2/model"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
 type Database struct {
-	l        *log.Logger
-	db       *gorm.DB
-	debugLog *log.Logger
+	l              *log.Logger
+	db             *gorm.DB
+	debugLog       *log.Logger
+	trashRetention time.Duration // 0 keeps the trash forever
+	tokens         *cache.Cache  // ID tokens by hash, see EnableAuthCache
+	roles          *cache.Cache
+	afterTx        *[]func() // cache invalidations of the transaction of Transaction, run after it ends
 }
 
-// ConnectDB connects to the database with dsn configuration.
-// If migrateDB is true, the tables will be created for given models.
-// If deletePrevData is true, the data will be deleted but tables will remain.
-// If deletePrevData and addTestData is true, the test data will be added.
+// ConnectDB connects to the postgres or SQLite database of the configuration.
+// If cfg.Migrate is true, the pending migrations are applied.
+// If cfg.Reset is true, the data will be deleted but tables will remain.
+// If cfg.Reset and cfg.Seed is true, the test data will be added.
 // If fullLog is true, all actions will be logged.
-func ConnectDB(l *log.Logger, migrateDB, deletePrevData, addTestData, fullLog bool) *Database {
+func ConnectDB(l *log.Logger, cfg config.Database, fullLog bool) *Database {
 	rd := Database{}
 	rd.l = l
-	host := os.Getenv("DB_HOST")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_DATABASE_NAME")
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=5432", host, user, password, dbname)
+	rd.EnableAuthCache(0)
 	dbLogger := logger.New(l, logger.Config{
 		IgnoreRecordNotFoundError: true,
 	})
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: dbLogger})
+	dialector := postgres.Open(cfg.ConnString())
+	if cfg.Driver == config.DriverSQLite {
+		dialector = sqlite.Open(cfg.ConnString())
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: dbLogger})
 	if err != nil {
 		l.Fatal(err)
 	}
 	rd.db = db
-	models := []interface{}{&model.Token{}, &model.Member{}, &model.Card{}, &model.Bundle{}, &model.Group{}, &model.User{}}
-	if migrateDB {
-		err := rd.migrate(models)
+	sqlDB, err := db.DB()
+	if err != nil {
+		l.Fatal(err)
+	}
+	if cfg.Driver == config.DriverSQLite {
+		// SQLite has a single writer, and an in-memory database lives as long as its connection
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+	} else {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime())
+	}
+
+	// the members are the join table of the users and the groups, the associations need it
+	if err := db.SetupJoinTable(&model.User{}, "Groups", &model.Member{}); err != nil {
+		l.Fatal(err)
+	}
+	if err := db.SetupJoinTable(&model.Group{}, "Members", &model.Member{}); err != nil {
+		l.Fatal(err)
+	}
+	if cfg.Migrate {
+		applied, err := rd.MigrateUp()
 		if err != nil {
 			l.Fatal(err)
 		}
+		for _, m := range applied {
+			l.Printf("applied migration %d %s", m.Version, m.Name)
+		}
 	}
-	if deletePrevData {
+	if cfg.Reset {
+		l.Println("deleting all data")
 		rd.clear()
-		if addTestData {
+		if cfg.Seed {
 			rd.addTestData()
 		}
 	}
@@ -62,23 +93,33 @@ func ConnectDB(l *log.Logger, migrateDB, deletePrevData, addTestData, fullLog bo
 	return &rd
 }
 
-// migrate creates tables inside the database with given models.
-func (db *Database) migrate(models []interface{}) error {
-	for _, m := range models {
-		if err := db.db.Statement.AutoMigrate(m); err != nil {
-			return err
+// Transaction runs fn in a transaction of the database. The methods of s which use a transaction themselves run
+// in a nested one, so that their failure does not roll back the writes of fn before them.
+// The cached entries changed by fn are dropped when the transaction ends, not before it is committed.
+func (db *Database) Transaction(fn func(s Store) error) error {
+	if db.afterTx != nil {
+		return db.db.Transaction(func(tx *gorm.DB) error {
+			return fn(db.withTx(tx))
+		})
+	}
+	var afterTx []func()
+	defer func() {
+		for _, f := range afterTx {
+			f()
 		}
-	}
-	err := db.db.Statement.SetupJoinTable(&model.User{}, "Groups", &model.Member{})
-	if err != nil {
-		return err
-	}
+	}()
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		txDB := db.withTx(tx)
+		txDB.afterTx = &afterTx
+		return fn(txDB)
+	})
+}
 
-	err = db.db.Statement.SetupJoinTable(&model.Group{}, "Members", &model.Member{})
-	if err != nil {
-		return err
-	}
-	return nil
+// withTx returns the database which runs its queries in the transaction tx.
+func (db *Database) withTx(tx *gorm.DB) *Database {
+	txDB := *db
+	txDB.db = tx
+	return &txDB
 }
 
 // clear deletes all data from the tables. queries must be written by hand.
@@ -86,6 +127,30 @@ func (db *Database) clear() {
 	if err := db.db.Exec("Delete From tokens").Error; err != nil {
 		db.l.Fatal(err)
 	}
+	if err := db.db.Exec("Delete From access_tokens").Error; err != nil {
+		db.l.Fatal(err)
+	}
+	if err := db.db.Exec("Delete From review_logs").Error; err != nil {
+		db.l.Fatal(err)
+	}
+	if err := db.db.Exec("Delete From card_revisions").Error; err != nil {
+		db.l.Fatal(err)
+	}
+	if err := db.db.Exec("Delete From review_states").Error; err != nil {
+		db.l.Fatal(err)
+	}
+	if err := db.db.Exec("Delete From scheduler_params").Error; err != nil {
+		db.l.Fatal(err)
+	}
+	if err := db.db.Exec("Delete From audit_entries").Error; err != nil {
+		db.l.Fatal(err)
+	}
+	if err := db.db.Exec("Delete From join_requests").Error; err != nil {
+		db.l.Fatal(err)
+	}
+	if err := db.db.Exec("Delete From invites").Error; err != nil {
+		db.l.Fatal(err)
+	}
 	if err := db.db.Exec("Delete From members").Error; err != nil {
 		db.l.Fatal(err)
 	}
@@ -105,17 +170,17 @@ func (db *Database) clear() {
 
 // addTestData adds the test data to the database. Data must be written by hand.
 func (db *Database) addTestData() {
-	tokens := []model.Token{{Token: "token1", UserID: "user1", Expires: 1660403886},
-		{Token: "token2", UserID: "user2", Expires: 1660403886},
-		{Token: "token3", UserID: "user3", Expires: 1660403886},
+	tokens := []model.Token{{Hash: hashToken("token1"), UserID: "user1", Expires: 1660403886},
+		{Hash: hashToken("token2"), UserID: "user2", Expires: 1660403886},
+		{Hash: hashToken("token3"), UserID: "user3", Expires: 1660403886},
 	}
 	if err := db.db.Create(&tokens).Error; err != nil {
 		db.l.Fatal(err)
 	}
 
-	users := []model.User{{ID: "user1", Username: "user1", ImageURL: "Default"},
-		{ID: "user2", Username: "user2", ImageURL: "Default"},
-		{ID: "user3", Username: "user3", ImageURL: "Default"},
+	users := []model.User{{ID: "user1", Username: "user1", ImageURL: model.DefaultImageURL},
+		{ID: "user2", Username: "user2", ImageURL: model.DefaultImageURL},
+		{ID: "user3", Username: "user3", ImageURL: model.DefaultImageURL},
 	}
 	if err := db.db.Create(&users).Error; err != nil {
 		db.l.Fatal(err)
@@ -126,8 +191,8 @@ func (db *Database) addTestData() {
 		db.l.Fatal(err)
 	}
 
-	members := []model.Member{{GroupID: "group1", UserID: "user1", IsAdmin: true, MemberSince: time.Now()},
-		{GroupID: "group1", UserID: "user2", IsAdmin: false, MemberSince: time.Now()},
+	members := []model.Member{{GroupID: "group1", UserID: "user1", Role: model.RoleOwner, MemberSince: time.Now()},
+		{GroupID: "group1", UserID: "user2", Role: model.RoleViewer, MemberSince: time.Now()},
 	}
 	if err := db.db.Create(&members).Error; err != nil {
 		db.l.Fatal(err)
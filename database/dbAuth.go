@@ -1,41 +1,118 @@
 package database
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 
 	"github.com/ironstone95/FlashQudoV2/model"
+	"gorm.io/gorm"
 )
 
-// AuthToken checks if the token is in the database and token expiration date is not reached.
+// AuthToken checks if the token is in the database, not revoked and token expiration date is not reached.
 // If token is expired, then deletes it from the database.
-func (db *Database) AuthToken(token string) error {
-	t := &model.Token{Token: token}
-	if err := db.db.Model(&t).First(&t).Error; err != nil {
-		db.logError("AuthToken", err.Error(), token)
-		return ErrTokenNotFound
+func (db *Database) AuthToken(token string) (*model.Token, error) {
+	hash := hashToken(token)
+	if t, ok := db.cachedToken(hash); ok {
+		return &t, nil
+	}
+	gen := db.tokens.Generation()
+	t := &model.Token{}
+	if err := db.db.Where("hash = ?", hash).First(&t).Error; err != nil {
+		db.logError("AuthToken", err.Error())
+		return nil, ErrTokenNotFound
 	}
 	if t.Expires < time.Now().Unix() {
 		if err := db.db.Delete(&t).Error; err != nil {
-			db.logError("AuthToken", err.Error(), token)
-			return ErrGormDelete
+			db.logError("AuthToken", err.Error(), t.UserID)
+			return nil, ErrGormDelete
 		}
-		db.logError("AuthToken", ErrTokenExpired.Error(), token)
-		return ErrTokenExpired
+		db.logError("AuthToken", ErrTokenExpired.Error(), t.UserID)
+		return nil, ErrTokenExpired
 	}
-	return nil
+	if t.RevokedAt != nil {
+		db.logError("AuthToken", ErrTokenRevoked.Error(), t.UserID)
+		return nil, ErrTokenRevoked
+	}
+	db.tokens.SetIfGeneration(hash, *t, gen)
+	return t, nil
 }
 
-// InserToken inserts given token to the database and returns created token.
-func (db *Database) InsertToken(token model.Token) (*model.Token, error) {
-	if err := db.db.Create(&token).Error; err != nil {
-		db.logError("InsertToken", err.Error(), token.Token, token.UserID)
+// InsertToken saves the hash of the verified token of the user. Tokens issued before the user logged out
+// everywhere are rejected. issuedAt and expires are unix seconds.
+func (db *Database) InsertToken(token, userID string, issuedAt, expires int64) (*model.Token, error) {
+	var validAfter []int64
+	if err := db.db.Model(&model.User{}).Where("id = ?", userID).Limit(1).Pluck("tokens_valid_after", &validAfter).Error; err != nil {
+		db.logError("InsertToken", err.Error(), userID)
+		return nil, ErrGormGet
+	}
+	if len(validAfter) != 0 && issuedAt < validAfter[0] {
+		return nil, ErrTokenRevoked
+	}
+	t := model.Token{Hash: hashToken(token), UserID: userID, Expires: expires, CheckedAt: time.Now().Unix()}
+	if err := db.db.Create(&t).Error; err != nil {
+		db.logError("InsertToken", err.Error(), userID)
 		return nil, ErrGormCreate
 	}
-	if err := db.db.Model(&token).First(&token).Error; err != nil {
-		db.logError("InsertToken", err.Error(), token.Token, token.UserID)
-		return nil, ErrGormGet
+	db.tokens.Set(t.Hash, t)
+	return &t, nil
+}
+
+// MarkTokenChecked records that the revocation of the token was checked now.
+func (db *Database) MarkTokenChecked(token string) error {
+	hash := hashToken(token)
+	if err := db.db.Model(&model.Token{}).Where("hash = ?", hash).
+		Update("checked_at", time.Now().Unix()).Error; err != nil {
+		db.logError("MarkTokenChecked", err.Error())
+		return ErrGormUpdate
 	}
-	return &token, nil
+	db.tokens.Delete(hash)
+	return nil
+}
+
+// RevokeToken logs the token out. It is kept as revoked until it expires, so that it is not verified again.
+func (db *Database) RevokeToken(token string) error {
+	hash := hashToken(token)
+	res := db.db.Model(&model.Token{}).Where("hash = ? and revoked_at is null", hash).Update("revoked_at", time.Now())
+	db.tokens.Delete(hash)
+	if res.Error != nil {
+		db.logError("RevokeToken", res.Error.Error())
+		return ErrGormUpdate
+	}
+	if res.RowsAffected == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// RevokeUserTokens logs the user out everywhere. The cached tokens are revoked and the tokens issued
+// until now are not accepted anymore.
+func (db *Database) RevokeUserTokens(userID string) error {
+	if len(userID) == 0 {
+		return ErrParamNotFound
+	}
+	now := time.Now()
+	defer db.forgetUserTokens(userID)
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Token{}).Where("user_id = ? and revoked_at is null", userID).
+			Update("revoked_at", now).Error; err != nil {
+			db.logError("RevokeUserTokens", err.Error(), userID)
+			return ErrGormUpdate
+		}
+		// tokens issued in the current second are rejected too
+		if err := tx.Model(&model.User{}).Where("id = ?", userID).
+			UpdateColumn("tokens_valid_after", now.Unix()+1).Error; err != nil {
+			db.logError("RevokeUserTokens", err.Error(), userID)
+			return ErrGormUpdate
+		}
+		return nil
+	})
+}
+
+// hashToken returns the hex encoded SHA-256 hash of the token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 // GetIDFromUsername returns the id of the username.
@@ -50,35 +127,16 @@ func (db *Database) GetIDFromUsername(username string) (string, error) {
 	return userID, nil
 }
 
-// GetIDFromToken returns the id of the token.
-func (db *Database) GetIDFromToken(token string) (string, error) {
-	if len(token) == 0 {
-		db.logError("GetIDFromToken", ErrParamNotFound.Error(), token)
-		return "", ErrParamNotFound
-	}
-	var id string
-	if err := db.db.Model(&model.Token{}).Where("token = ?", token).Select("user_id").First(&id).Error; err != nil {
-		db.logError("GetIDFromToken", err.Error(), token)
-		return "", ErrGormGet
-	}
-	if len(id) == 0 {
-		db.logError("GetIDFromToken", "", token)
-		return "", ErrIDLength
-	}
-	return id, nil
-}
-
 func (db *Database) IsGroupMember(groupID, userID string) (bool, error) {
 	if len(groupID) == 0 || len(userID) == 0 {
 		db.logError("IsGroupMember", ErrParamNotFound.Error(), groupID, userID)
 		return false, ErrParamNotFound
 	}
-	m := model.Member{UserID: userID, GroupID: groupID}
-	if err := db.db.Model(&m).First(&m).Error; err != nil {
-		db.logError("IsGroupMember", err.Error(), groupID, userID)
+	role, err := db.GetMemberRole(groupID, userID)
+	if err != nil {
 		return false, err
 	}
-	return true, nil
+	return len(role) != 0, nil
 }
 
 func (db *Database) CanSeeBundle(bundleID, userID string) (bool, error) {
@@ -86,69 +144,106 @@ func (db *Database) CanSeeBundle(bundleID, userID string) (bool, error) {
 		db.logError("CanSeeBundle", ErrParamNotFound.Error(), bundleID, userID)
 		return false, ErrParamNotFound
 	}
-	var count int64
-	if err := db.db.Model(&model.Member{}).
-		Distinct("user_id").
-		Joins("left join bundles on members.group_id = bundles.group_id").
-		Where("bundles.id = ? and members.user_id = ?", bundleID, userID).
-		Count(&count).Error; err != nil {
-		db.logError("CanSeeBundle", err.Error(), bundleID, userID)
-		return false, ErrGormGet
+	role, err := db.GetBundleRole(bundleID, userID)
+	if err != nil {
+		return false, err
 	}
-	return count >= 1, nil
+	return len(role) != 0, nil
 }
 
-func (db *Database) CanEditBundle(bundleID, userID string) (bool, error) {
-	if len(bundleID) == 0 || len(userID) == 0 {
-		return false, ErrParamNotFound
+// GetMemberRole returns the role of the user in the group, empty if the user is not a member.
+func (db *Database) GetMemberRole(groupID, userID string) (string, error) {
+	if len(groupID) == 0 || len(userID) == 0 {
+		return "", ErrParamNotFound
 	}
-	var editBundle bool
-	if err := db.db.Table("members").Select("members.is_admin").
-		Joins("left join bundles on bundles.group_id = members.group_id").
-		Where("bundles.id = ? and members.user_id = ?", bundleID, userID).
-		Find(&editBundle).Error; err != nil {
-		db.logError("CanEditBundle", err.Error(), bundleID, userID)
-		return false, ErrGormGet
+	return db.cachedRole(roleGroup, groupID, userID, func() (string, error) {
+		var roles []string
+		if err := db.db.Table("members").Select("members.role").
+			Where("group_id = ? and user_id = ?", groupID, userID).
+			Limit(1).Find(&roles).Error; err != nil {
+			db.logError("GetMemberRole", err.Error(), groupID, userID)
+			return "", ErrGormGet
+		}
+		if len(roles) == 0 {
+			return "", nil
+		}
+		return roles[0], nil
+	})
+}
+
+// GetBundleRole returns the role of the user in the group of the bundle, empty if the user is not a member.
+func (db *Database) GetBundleRole(bundleID, userID string) (string, error) {
+	if len(bundleID) == 0 || len(userID) == 0 {
+		return "", ErrParamNotFound
 	}
-	return editBundle, nil
+	return db.cachedRole(roleBundle, bundleID, userID, func() (string, error) {
+		var roles []string
+		if err := db.db.Table("members").Select("members.role").
+			Joins("join bundles on bundles.group_id = members.group_id").
+			Where("bundles.id = ? and members.user_id = ? and bundles.deleted_at is null", bundleID, userID).
+			Limit(1).Find(&roles).Error; err != nil {
+			db.logError("GetBundleRole", err.Error(), bundleID, userID)
+			return "", ErrGormGet
+		}
+		if len(roles) == 0 {
+			return "", nil
+		}
+		return roles[0], nil
+	})
 }
 
-func (db *Database) CanEditCard(cardID, userID string) (bool, error) {
+// GetCardRole returns the role of the user in the group of the card, empty if the user is not a member.
+func (db *Database) GetCardRole(cardID, userID string) (string, error) {
 	if len(cardID) == 0 || len(userID) == 0 {
-		return false, ErrParamNotFound
-	}
-	var isAdmin bool
-	if err := db.db.Table("members").Select("members.is_admin").
-		Joins("left join bundles on bundles.group_id = members.group_id").
-		Joins("left join cards on cards.bundle_id = bundles.id").
-		Where("cards.id = ? and members.user_id = ?", cardID, userID).Find(&isAdmin).Error; err != nil {
-		db.logError("CanEditCard", err.Error(), cardID, userID)
-		return false, ErrGormGet
+		return "", ErrParamNotFound
 	}
-	return isAdmin, nil
+	return db.cachedRole(roleCard, cardID, userID, func() (string, error) {
+		var roles []string
+		if err := db.db.Table("members").Select("members.role").
+			Joins("join bundles on bundles.group_id = members.group_id").
+			Joins("join cards on cards.bundle_id = bundles.id").
+			Where("cards.id = ? and members.user_id = ? and cards.deleted_at is null and bundles.deleted_at is null", cardID, userID).
+			Limit(1).Find(&roles).Error; err != nil {
+			db.logError("GetCardRole", err.Error(), cardID, userID)
+			return "", ErrGormGet
+		}
+		if len(roles) == 0 {
+			return "", nil
+		}
+		return roles[0], nil
+	})
 }
 
-func (db *Database) IsAdmin(groupID, userID string) (bool, error) {
-	if len(groupID) == 0 || len(userID) == 0 {
-		return false, ErrParamNotFound
-	}
-	var admin bool
-	var memberCount int64
-	if err := db.db.Table("members").Select("members.is_admin").Where("group_id = ? and user_id = ?", groupID, userID).Find(&admin).Error; err != nil {
-		db.logError("IsAdmin", err.Error(), groupID, userID)
-		return false, ErrGormGet
+// GetTrashItemRole returns the role of the user in the group of the bundle or card with the id, deleted or not.
+// Empty if the user is not a member.
+func (db *Database) GetTrashItemRole(itemID, userID string) (string, error) {
+	if len(itemID) == 0 || len(userID) == 0 {
+		return "", ErrParamNotFound
 	}
-
-	if !admin {
-		if err := db.db.Table("members").Where("group_id = ?", groupID).Count(&memberCount).Error; err != nil {
-			db.logError("IsAdmin", err.Error(), groupID, userID)
-			return false, ErrGormGet
+	return db.cachedRole(roleTrash, itemID, userID, func() (string, error) {
+		var roles []string
+		if err := db.db.Table("members").Select("members.role").
+			Joins("join bundles on bundles.group_id = members.group_id").
+			Where("members.user_id = ? and (bundles.id = ? or bundles.id in (select bundle_id from cards where cards.id = ?))", userID, itemID, itemID).
+			Limit(1).Find(&roles).Error; err != nil {
+			db.logError("GetTrashItemRole", err.Error(), itemID, userID)
+			return "", ErrGormGet
 		}
-		if memberCount == 0 { // shouldn't enter here
-			return true, nil
+		if len(roles) == 0 {
+			return "", nil
 		}
+		return roles[0], nil
+	})
+}
+
+// isMember checks the membership inside the transaction tx.
+func (db *Database) isMember(tx *gorm.DB, groupID, userID string) (bool, error) {
+	var count int64
+	if err := tx.Model(&model.Member{}).Where("group_id = ? and user_id = ?", groupID, userID).Count(&count).Error; err != nil {
+		db.logError("isMember", err.Error(), groupID, userID)
+		return false, ErrGormGet
 	}
-	return admin, nil
+	return count != 0, nil
 }
 
 func (db *Database) memberCount(groupID string) int64 {
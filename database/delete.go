@@ -1,9 +1,17 @@
 package database
 
 import (
+	"errors"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/handler/response"
 	"github.com/ironstone95/FlashQudoV2/model"
+	"gorm.io/gorm"
 )
 
+// groupCards selects the ids of the cards in the bundles of a group.
+const groupCards = "select cards.id from cards join bundles on bundles.id = cards.bundle_id where bundles.group_id = ?"
+
 // TODO authorization required
 func (db *Database) DeleteGroup(groupID string) error {
 	if len(groupID) == 0 {
@@ -13,55 +21,129 @@ func (db *Database) DeleteGroup(groupID string) error {
 		db.logError("DeleteGroup", ErrMembersExists.Error(), groupID)
 		return ErrMembersExists
 	}
-	if err := db.db.Delete(&model.Group{ID: groupID}).Error; err != nil {
+	err := db.db.Delete(&model.Group{ID: groupID}).Error
+	db.forgetAllRoles()
+	if err != nil {
 		db.logError("DeleteGroup", err.Error(), groupID)
 		return ErrGormDelete
 	}
 	return nil
 }
 
+// DeleteGroupCascade deletes the group with its bundles, cards, review history, card revisions, members, invites,
+// and join requests in one transaction. Bundles and cards in the trash are deleted too, the audit log is kept.
+// Returns the number of the deleted rows of each kind.
+func (db *Database) DeleteGroupCascade(groupID string) (*response.GroupDeletion, error) {
+	if len(groupID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	gd := response.GroupDeletion{GroupID: groupID}
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id").First(&model.Group{}, "id = ?", groupID).Error; err != nil {
+			db.logError("DeleteGroupCascade", err.Error(), groupID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrGroupNotFound
+			}
+			return ErrGormGet
+		}
+
+		steps := []struct {
+			count *int64
+			value interface{}
+			where string
+		}{
+			{&gd.ReviewLogs, &model.ReviewLog{}, "card_id in (" + groupCards + ")"},
+			{&gd.ReviewStates, &model.ReviewState{}, "card_id in (" + groupCards + ")"},
+			{&gd.CardRevisions, &model.CardRevision{}, "card_id in (" + groupCards + ")"},
+			{&gd.Cards, &model.Card{}, "bundle_id in (select id from bundles where group_id = ?)"},
+			{&gd.Bundles, &model.Bundle{}, "group_id = ?"},
+			{&gd.Invites, &model.Invite{}, "group_id = ?"},
+			{&gd.JoinRequests, &model.JoinRequest{}, "group_id = ?"},
+			{&gd.Members, &model.Member{}, "group_id = ?"},
+		}
+		for _, step := range steps {
+			res := tx.Unscoped().Where(step.where, groupID).Delete(step.value)
+			if res.Error != nil {
+				db.logError("DeleteGroupCascade", res.Error.Error(), groupID)
+				return ErrGormDelete
+			}
+			*step.count = res.RowsAffected
+		}
+		if err := tx.Delete(&model.Group{ID: groupID}).Error; err != nil {
+			db.logError("DeleteGroupCascade", err.Error(), groupID)
+			return ErrGormDelete
+		}
+		return nil
+	})
+	db.forgetAllRoles()
+	if err != nil {
+		return nil, err
+	}
+	return &gd, nil
+}
+
 func (db *Database) DeleteMember(groupID, userID string) error {
 	if len(groupID) == 0 || len(userID) == 0 {
 		return ErrParamNotFound
 	}
-	m := model.Member{GroupID: groupID, UserID: userID}
-	if err := db.db.Delete(&m).Error; err != nil {
+	err := db.db.Where("group_id = ? and user_id = ?", groupID, userID).Delete(&model.Member{}).Error
+	db.forgetRoles(userID)
+	if err != nil {
 		db.logError("DeleteMember", err.Error(), groupID, userID)
 		return ErrGormDelete
 	}
 	return nil
 }
 
+// DeleteBundle moves the bundle and its cards to the trash. The cards get the same deletion time as the bundle,
+// so that they are restored with it.
 func (db *Database) DeleteBundle(bundleID string) error {
 	if len(bundleID) == 0 {
 		return ErrParamNotFound
 	}
-	if err := db.db.Delete(&model.Bundle{ID: bundleID}).Error; err != nil {
-		db.logError("DeleteBundle", err.Error(), bundleID)
-		return ErrGormDelete
-	}
-	return nil
+	now := time.Now()
+	defer db.forgetAllRoles()
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Card{}).Where("bundle_id = ? and deleted_at is null", bundleID).
+			UpdateColumn("deleted_at", now).Error; err != nil {
+			db.logError("DeleteBundle", err.Error(), bundleID)
+			return ErrGormDelete
+		}
+		if err := tx.Model(&model.Bundle{}).Where("id = ? and deleted_at is null", bundleID).
+			UpdateColumn("deleted_at", now).Error; err != nil {
+			db.logError("DeleteBundle", err.Error(), bundleID)
+			return ErrGormDelete
+		}
+		return nil
+	})
 }
 
+// DeleteCard moves the card to the trash.
 func (db *Database) DeleteCard(cardID string) error {
 	if len(cardID) == 0 {
 		return ErrParamNotFound
 	}
 	c := model.Card{ID: cardID}
-	if err := db.db.Delete(&c).Error; err != nil {
+	err := db.db.Delete(&c).Error
+	db.forgetAllRoles()
+	if err != nil {
 		db.logError("DeleteCard", err.Error(), cardID)
 		return ErrGormDelete
 	}
 	return nil
 }
 
+// DeleteBundleCards moves every card of the bundle to the trash.
 func (db *Database) DeleteBundleCards(bundleID string) error {
 	if len(bundleID) == 0 {
 		return ErrParamNotFound
 	}
 
-	if err := db.db.Where("bundle_id = ?", bundleID).Delete(&model.Card{}).Error; err != nil {
-		return err
+	err := db.db.Where("bundle_id = ?", bundleID).Delete(&model.Card{}).Error
+	db.forgetAllRoles()
+	if err != nil {
+		db.logError("DeleteBundleCards", err.Error(), bundleID)
+		return ErrGormDelete
 	}
 	return nil
 }
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/config"
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+// addMember inserts a new user as a member of the group with the role.
+func addMember(t *testing.T, db *Database, groupID, role string) *model.User {
+	t.Helper()
+	user, err := db.InsertUser(*generator.GetRandomUser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.InsertMember(model.Member{GroupID: groupID, UserID: user.ID, Role: role}); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func role(t *testing.T, db *Database, groupID, userID string) string {
+	t.Helper()
+	var m model.Member
+	if err := db.db.Where("group_id = ? and user_id = ?", groupID, userID).First(&m).Error; err != nil {
+		t.Fatal(err)
+	}
+	return m.Role
+}
+
+func TestTransferOwnership(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) {})
+	owner, bundle, _ := newBundle(t, db, 0)
+	groupID := bundle.GroupID
+	admin := addMember(t, db, groupID, model.RoleAdmin)
+	editor := addMember(t, db, groupID, model.RoleEditor)
+
+	// the admin is not the owner, nothing changes
+	if _, err := db.TransferOwnership(groupID, admin.ID, editor.ID); !errors.Is(err, ErrNotOwner) {
+		t.Fatalf("transfer by the admin returned %v, want %v", err, ErrNotOwner)
+	}
+	if r := role(t, db, groupID, editor.ID); r != model.RoleEditor {
+		t.Fatalf("role of the editor %s after the failed transfer, want %s", r, model.RoleEditor)
+	}
+	if r := role(t, db, groupID, owner.ID); r != model.RoleOwner {
+		t.Fatalf("role of the owner %s after the failed transfer, want %s", r, model.RoleOwner)
+	}
+
+	if _, err := db.TransferOwnership(groupID, owner.ID, owner.ID+"x"); !errors.Is(err, ErrMemberNotFound) {
+		t.Fatalf("transfer to a non member returned %v, want %v", err, ErrMemberNotFound)
+	}
+
+	m, err := db.TransferOwnership(groupID, owner.ID, editor.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.UserID != editor.ID || m.Role != model.RoleOwner {
+		t.Fatalf("new owner %+v, want the editor as the owner", *m)
+	}
+	if r := role(t, db, groupID, owner.ID); r != model.RoleAdmin {
+		t.Fatalf("role of the old owner %s, want %s", r, model.RoleAdmin)
+	}
+
+	// the old owner cannot transfer the ownership again
+	if _, err := db.TransferOwnership(groupID, owner.ID, admin.ID); !errors.Is(err, ErrNotOwner) {
+		t.Fatalf("second transfer by the old owner returned %v, want %v", err, ErrNotOwner)
+	}
+	if r := role(t, db, groupID, admin.ID); r != model.RoleAdmin {
+		t.Fatalf("role of the admin %s after the failed transfer, want %s", r, model.RoleAdmin)
+	}
+}
+
+func TestDeleteGroupCascade(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) {})
+	owner, bundle, cards := newBundle(t, db, 2)
+	groupID := bundle.GroupID
+	trashed, _ := addBundle(t, db, groupID, 1)
+	viewer := addMember(t, db, groupID, model.RoleViewer)
+
+	review(t, db, owner.ID, cards[0].ID, 4)
+	review(t, db, owner.ID, cards[0].ID, 4)
+	review(t, db, viewer.ID, cards[1].ID, 3)
+	if _, err := db.UpdateCard(cards[1].ID, owner.ID, map[string]interface{}{"question": "changed"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteCard(cards[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteBundle(trashed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.InsertInvite(model.Invite{GroupID: groupID, CreatedBy: owner.ID, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	outsider, err := db.InsertUser(*generator.GetRandomUser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	jr := model.JoinRequest{ID: generator.CreateID(), GroupID: groupID, UserID: outsider.ID, Status: model.JoinRequestPending}
+	if err := db.db.Create(&jr).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InsertAuditEntry(model.AuditEntry{GroupID: groupID, ActorID: owner.ID, Action: model.AuditBundleDelete,
+		TargetType: model.TargetBundle, TargetID: trashed.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a group of the same owner which is kept
+	other, err := db.InsertGroup(*generator.GetRandomGroup(), owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherCards := addBundle(t, db, other.ID, 1)
+	review(t, db, owner.ID, otherCards[0].ID, 4)
+
+	gd, err := db.DeleteGroupCascade(groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := response.GroupDeletion{GroupID: groupID, Bundles: 2, Cards: 3, ReviewStates: 2, ReviewLogs: 3,
+		CardRevisions: 1, Members: 2, Invites: 1, JoinRequests: 1}
+	if *gd != want {
+		t.Fatalf("deleted %+v, want %+v", *gd, want)
+	}
+
+	if _, err := db.DeleteGroupCascade(groupID); !errors.Is(err, ErrGroupNotFound) {
+		t.Fatalf("second deletion returned %v, want %v", err, ErrGroupNotFound)
+	}
+	var audits, kept int64
+	if err := db.db.Model(&model.AuditEntry{}).Where("group_id = ?", groupID).Count(&audits).Error; err != nil {
+		t.Fatal(err)
+	}
+	if audits != 1 {
+		t.Fatalf("%d audit entries of the deleted group, want 1", audits)
+	}
+	if err := db.db.Model(&model.ReviewLog{}).Where("card_id = ?", otherCards[0].ID).Count(&kept).Error; err != nil {
+		t.Fatal(err)
+	}
+	if kept != 1 || db.memberCount(other.ID) != 1 {
+		t.Fatalf("other group has %d review logs and %d members, want 1 and 1", kept, db.memberCount(other.ID))
+	}
+}
@@ -1,12 +1,22 @@
 package database
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
 
 // errors generated by database api
 
 // token errors
 var ErrTokenNotFound = errors.New("token not found")
 var ErrTokenExpired = errors.New("token expired")
+var ErrTokenRevoked = errors.New("token revoked")
+
+// access token errors
+var ErrAccessTokenNotFound = errors.New("access token not found")
+var ErrAccessTokenInvalid = errors.New("access token is expired or revoked")
 
 // parameter errors
 var ErrParamNotFound = errors.New("parameter required")
@@ -20,11 +30,39 @@ var ErrGormAssocAppend = errors.New("gorm association append error")
 var ErrGormSave = errors.New("gorm save error")
 var ErrGormUpdate = errors.New("gorm update error")
 var ErrUserNotFound = errors.New("user not found")
+var ErrUserExists = errors.New("user already exists")
+var ErrUsernameExists = errors.New("username is already taken")
 
 // custom errors
 var ErrIDLength = errors.New("id length zero")
 var ErrMembersExists = errors.New("group must be empty to be deleted")
 var ErrQuestionExists = errors.New("question already exists error")
+var ErrMemberNotFound = errors.New("user is not a member of the group")
+var ErrNotOwner = errors.New("user is not the owner of the group")
+var ErrInvalidConflictMode = errors.New("conflict mode must be skip, overwrite or fail")
+var ErrNotSupported = errors.New("not supported by the store")
+
+// invite errors
+var ErrInviteNotFound = errors.New("invite not found")
+var ErrInviteInvalid = errors.New("invite is expired, revoked or used up")
+var ErrAlreadyMember = errors.New("user is already a member of the group")
+
+// join request errors
+var ErrGroupNotFound = errors.New("group not found")
+var ErrGroupNotJoinable = errors.New("group does not accept join requests")
+var ErrJoinRequestExists = errors.New("join request is already pending")
+var ErrJoinRequestNotFound = errors.New("join request not found")
+var ErrJoinRequestDecided = errors.New("join request is already decided")
+
+// revision errors
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// trash errors
+var ErrTrashItemNotFound = errors.New("item is not in the trash")
+var ErrBundleInTrash = errors.New("bundle of the card is in the trash, restore the bundle")
+
+// review errors
+var ErrInvalidGrade = errors.New("grade must be between 0 and 5")
 
 // logError prints errors with given prefix (mostly name of the function), message and parameters. errorLogger must be present.
 func (db *Database) logError(prefix, message string, parameters ...interface{}) {
@@ -32,3 +70,16 @@ func (db *Database) logError(prefix, message string, parameters ...interface{})
 		db.debugLog.Printf("[%s] Error: %s, Params: %#v", prefix, message, parameters)
 	}
 }
+
+// isUniqueViolation reports whether err is the violation of a unique index or a primary key, by PostgreSQL or SQLite.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	return false
+}
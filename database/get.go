@@ -1,8 +1,11 @@
 package database
 
 import (
+	"errors"
+
 	"github.com/ironstone95/FlashQudoV2/handler/response"
 	"github.com/ironstone95/FlashQudoV2/model"
+	"gorm.io/gorm"
 )
 
 // GetUser fetches the first user with given query parameters. Suggested values are id and username.
@@ -10,6 +13,9 @@ func (db *Database) GetUser(query map[string]interface{}) (*model.User, error) {
 	user := &model.User{}
 	if err := db.db.Where(query).First(&user).Error; err != nil {
 		db.logError("GetUser", err.Error(), query)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
 		return nil, ErrGormGet
 	}
 	return user, nil
@@ -20,6 +26,9 @@ func (db *Database) GetGroup(query map[string]interface{}) (*model.Group, error)
 	group := &model.Group{}
 	if err := db.db.Where(query).First(&group).Error; err != nil {
 		db.logError("GetGroup", err.Error(), query)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGroupNotFound
+		}
 		return nil, ErrGormGet
 	}
 	return group, nil
@@ -41,7 +50,7 @@ func (db *Database) GetGroupUsers(groupID string, page, limit int) ([]model.User
 func (db *Database) GetGroupMembers(groupID string, page, limit int) ([]response.GroupMember, error) {
 	var members []response.GroupMember
 	if err := db.db.Table("users").
-		Select("users.id, users.username, users.image_url, members.member_since, members.is_admin").
+		Select("users.id, users.username, users.image_url, members.member_since, members.role").
 		Joins("left join members on users.id = members.user_id").
 		Where("members.group_id = ?", groupID).Limit(limit).Offset((page - 1) * limit).Scan(&members).Error; err != nil {
 		return nil, err
@@ -52,7 +61,7 @@ func (db *Database) GetGroupMembers(groupID string, page, limit int) ([]response
 func (db *Database) GetUserGroups(userID string, page, limit int) ([]response.UserGroup, error) {
 	var groups []response.UserGroup
 	if err := db.db.Table("groups").
-		Select("groups.id, groups.name, members.member_since, members.is_admin").
+		Select("groups.id, groups.name, members.member_since, members.role").
 		Joins("left join members on groups.id = members.group_id").
 		Where("members.user_id = ?", userID).
 		Limit(limit).Offset((page - 1) * limit).Scan(&groups).Error; err != nil {
@@ -61,12 +70,17 @@ func (db *Database) GetUserGroups(userID string, page, limit int) ([]response.Us
 	return groups, nil
 }
 
+// bundleColumns are the columns of a bundle in the bundle listings. The listings group by all of them,
+// which every database accepts next to the card count.
+const bundleColumns = "bundles.id, bundles.title, bundles.description, bundles.group_id"
+
 func (db *Database) GetGroupBundles(groupID string, page, limit int) ([]response.GroupBundle, error) {
 	var bundles []response.GroupBundle
 	if err := db.db.Table("bundles").
-		Select("bundles.id, bundles.title, bundles.description, bundles.group_id, count(cards.id) as \"card_count\"").
-		Joins("left join cards on cards.bundle_id = bundles.id").Where("bundles.group_id = ?", groupID).
-		Group("bundles.id").Limit(limit).Offset((page - 1) * limit).Scan(&bundles).Error; err != nil {
+		Select(bundleColumns+", count(cards.id) as card_count").
+		Joins("left join cards on cards.bundle_id = bundles.id and cards.deleted_at is null").
+		Where("bundles.group_id = ? and bundles.deleted_at is null", groupID).
+		Group(bundleColumns).Order("bundles.created_at, bundles.id").Limit(limit).Offset((page - 1) * limit).Scan(&bundles).Error; err != nil {
 		return nil, err
 	}
 	return bundles, nil
@@ -75,9 +89,10 @@ func (db *Database) GetGroupBundles(groupID string, page, limit int) ([]response
 func (db *Database) GetBundle(bundleID string) (*response.GroupBundle, error) {
 	b := response.GroupBundle{}
 	if err := db.db.Table("bundles").
-		Select("bundles.id, bundles.title, bundles.description, bundles.group_id, count(cards.id) as \"card_count\"").
-		Joins("left join cards on cards.bundle_id = bundles.id").Where("bundles.id = ?", bundleID).
-		Group("bundles.id").Scan(&b).Error; err != nil {
+		Select(bundleColumns+", count(cards.id) as card_count").
+		Joins("left join cards on cards.bundle_id = bundles.id and cards.deleted_at is null").
+		Where("bundles.id = ? and bundles.deleted_at is null", bundleID).
+		Group(bundleColumns).Scan(&b).Error; err != nil {
 		return nil, err
 	}
 	return &b, nil
@@ -96,6 +111,16 @@ func (db *Database) GetBundleCards(bundleID string, page, limit int) ([]model.Ca
 	return cards, nil
 }
 
+// GetAllBundleCards returns every card of the bundle in the order of creation.
+func (db *Database) GetAllBundleCards(bundleID string) ([]model.Card, error) {
+	var cards []model.Card
+	if err := db.db.Where("bundle_id = ?", bundleID).Order("created_at, id").Find(&cards).Error; err != nil {
+		db.logError("GetAllBundleCards", err.Error(), bundleID)
+		return nil, ErrGormGet
+	}
+	return cards, nil
+}
+
 // func (db *Database) getUserID(username string) (string, error) {
 // 	var id string
 // 	if err := db.db.Model(&model.User{Username: username}).Select("id").First(&id).Error; err != nil {
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/config"
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+func TestGetGroupBundlesOrder(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) {})
+	user, err := db.InsertUser(*generator.GetRandomUser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, err := db.InsertGroup(*generator.GetRandomGroup(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the ids are in the reverse order of the creation
+	created := time.Now()
+	ids := []string{"c", "b", "a"}
+	for i, id := range ids {
+		b := model.Bundle{ID: id, Title: id, GroupID: group.ID, CreatedAt: created.Add(time.Duration(i) * time.Second)}
+		if err := db.db.Create(&b).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for page, want := range [][]string{{"c", "b"}, {"a"}} {
+		bundles, err := db.GetGroupBundles(group.ID, page+1, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(bundles) != len(want) {
+			t.Fatalf("page %d has %d bundles, want %d", page+1, len(bundles), len(want))
+		}
+		for i, b := range bundles {
+			if b.ID != want[i] {
+				t.Errorf("page %d bundle %d is %s, want %s", page+1, i, b.ID, want[i])
+			}
+		}
+	}
+}
+
+func TestGetNotFound(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) {})
+	if _, err := db.GetUser(map[string]interface{}{"username": "nobody"}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user returned %v, want %v", err, ErrUserNotFound)
+	}
+	if _, err := db.GetGroup(map[string]interface{}{"id": "nothing"}); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("unknown group returned %v, want %v", err, ErrGroupNotFound)
+	}
+}
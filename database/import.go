@@ -0,0 +1,99 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+	"gorm.io/gorm"
+)
+
+// handling of imported cards whose question already exists in the bundle
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictFail      = "fail"
+)
+
+// CardRow is a card read from an import file. Row is the position of the card in the file, starting from 1.
+type CardRow struct {
+	Row      int
+	Question string
+	Answer   string
+}
+
+// ImportCards inserts the rows to the bundle in a single transaction. Rows whose question already exists in the
+// bundle or appeared in a previous row are skipped, overwrite the answer, or fail the whole import with
+// ErrQuestionExists depending on onConflict. Overwritten cards are updated like UpdateCard by editorID, with a
+// revision, and returned in the result for the audit log. The result reports the conflicting rows.
+func (db *Database) ImportCards(bundleID, editorID string, rows []CardRow, onConflict string) (*response.CardImport, error) {
+	if len(bundleID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	if onConflict != ConflictSkip && onConflict != ConflictOverwrite && onConflict != ConflictFail {
+		return nil, ErrInvalidConflictMode
+	}
+	res := &response.CardImport{Errors: []response.RowError{}}
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		var existing []model.Card
+		if err := tx.Select("id, question").Where("bundle_id = ?", bundleID).Find(&existing).Error; err != nil {
+			db.logError("ImportCards", err.Error(), bundleID, onConflict)
+			return ErrGormGet
+		}
+		ids := make(map[string]string)
+		for _, c := range existing {
+			ids[c.Question] = c.ID
+		}
+
+		var cards []model.Card
+		pending := make(map[string]int) // question to index in cards
+		for _, row := range rows {
+			id, exists := ids[row.Question]
+			i, isPending := pending[row.Question]
+			if !exists && !isPending {
+				pending[row.Question] = len(cards)
+				cards = append(cards, model.Card{ID: generator.CreateID(), BundleID: bundleID, Question: row.Question, Answer: row.Answer})
+				continue
+			}
+			switch onConflict {
+			case ConflictFail:
+				res.Errors = append(res.Errors, response.RowError{Row: row.Row, Message: ErrQuestionExists.Error()})
+			case ConflictSkip:
+				res.Skipped++
+			case ConflictOverwrite:
+				if isPending {
+					cards[i].Answer = row.Answer
+					res.Updated++
+					continue
+				}
+				answer := row.Answer
+				c, err := db.updateCard(tx, id, editorID, nil, &answer, nil)
+				if err != nil {
+					return err
+				}
+				res.Overwritten = append(res.Overwritten, *c)
+				res.Updated++
+			}
+		}
+		if len(res.Errors) != 0 {
+			return ErrQuestionExists
+		}
+		if len(cards) != 0 {
+			if err := tx.CreateInBatches(&cards, 100).Error; err != nil {
+				db.logError("ImportCards", err.Error(), bundleID, len(cards))
+				return ErrGormCreate
+			}
+		}
+		res.Inserted = len(cards)
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrQuestionExists) {
+			res.Inserted, res.Updated, res.Skipped, res.Overwritten = 0, 0, 0, nil
+			return res, err
+		}
+		return nil, err
+	}
+	return res, nil
+}
@@ -8,10 +8,22 @@ import (
 	"gorm.io/gorm"
 )
 
+// InsertUser creates the user. It returns ErrUserExists if the user has already been created and
+// ErrUsernameExists if another user has the username.
 func (db *Database) InsertUser(u model.User) (*model.User, error) {
-	if err := db.db.Create(&u).Error; err != nil {
+	if err := db.create(&u); err != nil {
 		db.logError("InsertUser", err.Error(), u)
-		return nil, ErrGormCreate
+		if !isUniqueViolation(err) {
+			return nil, ErrGormCreate
+		}
+		var count int64
+		if err := db.db.Model(&model.User{}).Where("id = ?", u.ID).Count(&count).Error; err != nil {
+			db.logError("InsertUser", err.Error(), u)
+			return nil, ErrGormGet
+		} else if count != 0 {
+			return nil, ErrUserExists
+		}
+		return nil, ErrUsernameExists
 	}
 	return &u, nil
 }
@@ -19,16 +31,13 @@ func (db *Database) InsertUser(u model.User) (*model.User, error) {
 func (db *Database) InsertGroup(g model.Group, userID string) (*model.Group, error) {
 	g.ID = generator.CreateID()
 	err := db.db.Transaction(func(tx *gorm.DB) error {
-		if err := db.db.Create(&g).Error; err != nil {
+		if err := tx.Create(&g).Error; err != nil {
 			db.logError("InsertGroup", err.Error(), g, userID)
 			return ErrGormCreate
 		}
-		m := model.Member{GroupID: g.ID, UserID: userID, IsAdmin: true}
-		if _, err := db.InsertMember(m); err != nil {
-			return ErrGormCreate
-		}
-		return nil
+		return db.addMember(tx, model.Member{GroupID: g.ID, UserID: userID, Role: model.RoleOwner})
 	})
+	db.forgetRoles(userID)
 	if err != nil {
 		return nil, err
 	}
@@ -37,23 +46,29 @@ func (db *Database) InsertGroup(g model.Group, userID string) (*model.Group, err
 
 func (db *Database) InsertMember(member model.Member) (*model.Member, error) {
 	err := db.db.Transaction(func(tx *gorm.DB) error {
+		if isMember, err := db.isMember(tx, member.GroupID, member.UserID); err != nil {
+			return err
+		} else if isMember {
+			return ErrAlreadyMember
+		}
 		member.MemberSince = time.Now()
 		g := model.Group{ID: member.GroupID}
 		u := model.User{ID: member.UserID}
-		if err := db.db.Model(&model.User{ID: member.UserID}).Association("Groups").Append(&g); err != nil {
+		if err := tx.Model(&model.User{ID: member.UserID}).Association("Groups").Append(&g); err != nil {
 			db.logError("InsertMember", err.Error(), member)
 			return ErrGormAssocAppend
 		}
-		if err := db.db.Model(&model.Group{ID: member.GroupID}).Association("Members").Append(&u); err != nil {
+		if err := tx.Model(&model.Group{ID: member.GroupID}).Association("Members").Append(&u); err != nil {
 			db.logError("InsertMember", err.Error(), member)
 			return ErrGormAssocAppend
 		}
-		if err := db.db.Save(&member).Error; err != nil {
+		if err := tx.Save(&member).Error; err != nil {
 			db.logError("InsertMember", err.Error(), member)
 			return ErrGormSave
 		}
 		return nil
 	})
+	db.forgetRoles(member.UserID)
 	if err != nil {
 		return nil, err
 	}
@@ -71,11 +86,33 @@ func (db *Database) InsertBundle(bundle model.Bundle) (*model.Bundle, error) {
 	return &bundle, nil
 }
 
+// InsertCard creates the card. It returns ErrQuestionExists if another card of the bundle has the question.
 func (db *Database) InsertCard(card model.Card) (*model.Card, error) {
 	card.ID = generator.CreateID()
-	if err := db.db.Create(&card).Error; err != nil {
+	if err := db.create(&card); err != nil {
 		db.logError("InsertCard", err.Error(), card)
+		if isUniqueViolation(err) {
+			return nil, ErrQuestionExists
+		}
 		return nil, ErrGormCreate
 	}
 	return &card, nil
 }
+
+// create inserts value in a savepoint of the current transaction, so that a failed insert, like a unique
+// violation, does not abort the transaction on PostgreSQL.
+func (db *Database) create(value interface{}) error {
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(value).Error
+	})
+}
+
+// addMember creates the membership inside the transaction tx.
+func (db *Database) addMember(tx *gorm.DB, member model.Member) error {
+	member.MemberSince = time.Now()
+	if err := tx.Create(&member).Error; err != nil {
+		db.logError("addMember", err.Error(), member)
+		return ErrGormCreate
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ironstone95/FlashQudoV2/config"
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+func TestInsertDuplicates(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) {})
+	user, err := db.InsertUser(*generator.GetRandomUser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.InsertUser(*user); !errors.Is(err, ErrUserExists) {
+		t.Fatalf("inserting the user again: error %v, want %v", err, ErrUserExists)
+	}
+	other := generator.GetRandomUser()
+	other.Username = user.Username
+	if _, err := db.InsertUser(*other); !errors.Is(err, ErrUsernameExists) {
+		t.Fatalf("inserting a user with the username: error %v, want %v", err, ErrUsernameExists)
+	}
+
+	group, err := db.InsertGroup(*generator.GetRandomGroup(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := db.InsertBundle(*generator.GetRandomBundle(group.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	card, err := db.InsertCard(*generator.GetRandomCard(bundle.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the duplicate does not end the transaction
+	err = db.Transaction(func(s Store) error {
+		if _, err := s.InsertCard(model.Card{BundleID: bundle.ID, Question: card.Question, Answer: "other"}); !errors.Is(err, ErrQuestionExists) {
+			t.Fatalf("inserting the question again: error %v, want %v", err, ErrQuestionExists)
+		}
+		_, err := s.InsertCard(model.Card{BundleID: bundle.ID, Question: card.Question + "?", Answer: "other"})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cards, err := db.GetAllBundleCards(bundle.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("%d cards, want 2", len(cards))
+	}
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/model"
+	"gorm.io/gorm"
+)
+
+// InsertInvite creates an invite with a new code for the group of the invite.
+func (db *Database) InsertInvite(invite model.Invite) (*model.Invite, error) {
+	if len(invite.GroupID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	invite.ID = generator.CreateID()
+	invite.Code = generator.CreateCode()
+	invite.Uses = 0
+	invite.RevokedAt = nil
+	if err := db.db.Create(&invite).Error; err != nil {
+		db.logError("InsertInvite", err.Error(), invite)
+		return nil, ErrGormCreate
+	}
+	return &invite, nil
+}
+
+// GetGroupInvites returns the invites of the group, newest first. Revoked and expired invites are included.
+func (db *Database) GetGroupInvites(groupID string, page, limit int) ([]model.Invite, error) {
+	invites := []model.Invite{}
+	if err := db.db.Where("group_id = ?", groupID).Order("created_at desc").
+		Limit(limit).Offset((page - 1) * limit).Find(&invites).Error; err != nil {
+		db.logError("GetGroupInvites", err.Error(), groupID, page, limit)
+		return nil, ErrGormGet
+	}
+	return invites, nil
+}
+
+// RevokeInvite revokes the invite of the group. Revoked invites cannot be accepted anymore.
+func (db *Database) RevokeInvite(groupID, inviteID string) error {
+	if len(groupID) == 0 || len(inviteID) == 0 {
+		return ErrParamNotFound
+	}
+	res := db.db.Model(&model.Invite{}).
+		Where("id = ? and group_id = ? and revoked_at is null", inviteID, groupID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		db.logError("RevokeInvite", res.Error.Error(), groupID, inviteID)
+		return ErrGormUpdate
+	}
+	if res.RowsAffected == 0 {
+		db.logError("RevokeInvite", ErrInviteNotFound.Error(), groupID, inviteID)
+		return ErrInviteNotFound
+	}
+	return nil
+}
+
+// AcceptInvite makes the user a member of the group of the invite with the given code and uses the invite once.
+func (db *Database) AcceptInvite(code, userID string) (*model.Member, error) {
+	if len(code) == 0 || len(userID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	var m model.Member
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		invite := model.Invite{}
+		if err := tx.Where("code = ?", code).First(&invite).Error; err != nil {
+			db.logError("AcceptInvite", err.Error(), code, userID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInviteNotFound
+			}
+			return ErrGormGet
+		}
+
+		// checking and using the invite in a single statement keeps concurrent accepts within the limit
+		res := tx.Model(&model.Invite{}).
+			Where("id = ? and revoked_at is null and expires_at > ? and (max_uses = 0 or uses < max_uses)", invite.ID, time.Now()).
+			Update("uses", gorm.Expr("uses + 1"))
+		if res.Error != nil {
+			db.logError("AcceptInvite", res.Error.Error(), code, userID)
+			return ErrGormUpdate
+		}
+		if res.RowsAffected == 0 {
+			db.logError("AcceptInvite", ErrInviteInvalid.Error(), code, userID)
+			return ErrInviteInvalid
+		}
+
+		if isMember, err := db.isMember(tx, invite.GroupID, userID); err != nil {
+			return err
+		} else if isMember {
+			return ErrAlreadyMember
+		}
+
+		m = model.Member{GroupID: invite.GroupID, UserID: userID, Role: invite.Role}
+		return db.addMember(tx, m)
+	})
+	db.forgetRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+	"gorm.io/gorm"
+)
+
+// GetDiscoverableGroups returns the discoverable and open groups whose name contains the query.
+func (db *Database) GetDiscoverableGroups(query string, page, limit int) ([]response.GroupListing, error) {
+	groups := []response.GroupListing{}
+	tx := db.db.Table("groups").
+		Select("groups.id, groups.name, groups.visibility, count(members.user_id) as member_count").
+		Joins("left join members on members.group_id = groups.id").
+		Where("groups.visibility in ?", []string{model.VisibilityDiscoverable, model.VisibilityOpen})
+	if q := strings.TrimSpace(query); len(q) != 0 {
+		tx = tx.Where("lower(groups.name) like ? escape '\\'", "%"+likeEscaper.Replace(strings.ToLower(q))+"%")
+	}
+	if err := tx.Group("groups.id, groups.name, groups.visibility").Order("member_count desc, groups.name").
+		Limit(limit).Offset((page - 1) * limit).Scan(&groups).Error; err != nil {
+		db.logError("GetDiscoverableGroups", err.Error(), query, page, limit)
+		return nil, ErrGormGet
+	}
+	return groups, nil
+}
+
+// InsertJoinRequest creates a join request of the user to the group. Requests to open groups are approved
+// immediately and the user becomes a member, requests to discoverable groups wait for an admin.
+func (db *Database) InsertJoinRequest(groupID, userID string) (*model.JoinRequest, error) {
+	if len(groupID) == 0 || len(userID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	jr := model.JoinRequest{ID: generator.CreateID(), GroupID: groupID, UserID: userID, Status: model.JoinRequestPending}
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		g := model.Group{}
+		if err := tx.Where("id = ?", groupID).First(&g).Error; err != nil {
+			db.logError("InsertJoinRequest", err.Error(), groupID, userID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrGroupNotFound
+			}
+			return ErrGormGet
+		}
+		if g.Visibility != model.VisibilityDiscoverable && g.Visibility != model.VisibilityOpen {
+			return ErrGroupNotJoinable
+		}
+		if isMember, err := db.isMember(tx, groupID, userID); err != nil {
+			return err
+		} else if isMember {
+			return ErrAlreadyMember
+		}
+
+		var pending int64
+		if err := tx.Model(&model.JoinRequest{}).
+			Where("group_id = ? and user_id = ? and status = ?", groupID, userID, model.JoinRequestPending).
+			Count(&pending).Error; err != nil {
+			db.logError("InsertJoinRequest", err.Error(), groupID, userID)
+			return ErrGormGet
+		}
+		if pending != 0 {
+			return ErrJoinRequestExists
+		}
+
+		if g.Visibility == model.VisibilityOpen {
+			now := time.Now()
+			jr.Status = model.JoinRequestApproved
+			jr.DecidedAt = &now
+			if err := db.addMember(tx, model.Member{GroupID: groupID, UserID: userID, Role: model.RoleViewer}); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&jr).Error; err != nil {
+			db.logError("InsertJoinRequest", err.Error(), groupID, userID)
+			return ErrGormCreate
+		}
+		return nil
+	})
+	db.forgetRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &jr, nil
+}
+
+// GetGroupJoinRequests returns the join requests of the group with the given status, oldest first.
+// All requests are returned if status is empty.
+func (db *Database) GetGroupJoinRequests(groupID, status string, page, limit int) ([]model.JoinRequest, error) {
+	requests := []model.JoinRequest{}
+	tx := db.db.Where("group_id = ?", groupID)
+	if len(status) != 0 {
+		tx = tx.Where("status = ?", status)
+	}
+	if err := tx.Order("created_at").Limit(limit).Offset((page - 1) * limit).Find(&requests).Error; err != nil {
+		db.logError("GetGroupJoinRequests", err.Error(), groupID, status, page, limit)
+		return nil, ErrGormGet
+	}
+	return requests, nil
+}
+
+// DecideJoinRequest approves or rejects the pending join request of the group. Approving makes the user a member,
+// added is false if the user has become a member another way since the request.
+func (db *Database) DecideJoinRequest(groupID, requestID, deciderID string, approve bool) (jr *model.JoinRequest, added bool, err error) {
+	if len(groupID) == 0 || len(requestID) == 0 {
+		return nil, false, ErrParamNotFound
+	}
+	jr = &model.JoinRequest{}
+	err = db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? and group_id = ?", requestID, groupID).First(jr).Error; err != nil {
+			db.logError("DecideJoinRequest", err.Error(), groupID, requestID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrJoinRequestNotFound
+			}
+			return ErrGormGet
+		}
+
+		status := model.JoinRequestRejected
+		if approve {
+			status = model.JoinRequestApproved
+		}
+		now := time.Now()
+		res := tx.Model(&model.JoinRequest{}).
+			Where("id = ? and status = ?", requestID, model.JoinRequestPending).
+			Updates(map[string]interface{}{"status": status, "decided_by": deciderID, "decided_at": now})
+		if res.Error != nil {
+			db.logError("DecideJoinRequest", res.Error.Error(), groupID, requestID)
+			return ErrGormUpdate
+		}
+		if res.RowsAffected == 0 {
+			return ErrJoinRequestDecided
+		}
+		jr.Status, jr.DecidedBy, jr.DecidedAt = status, deciderID, &now
+
+		if approve {
+			if isMember, err := db.isMember(tx, groupID, jr.UserID); err != nil {
+				return err
+			} else if isMember {
+				return nil
+			}
+			added = true
+			return db.addMember(tx, model.Member{GroupID: groupID, UserID: jr.UserID, Role: model.RoleViewer})
+		}
+		return nil
+	})
+	db.forgetRoles(jr.UserID)
+	if err != nil {
+		return nil, false, err
+	}
+	return jr, added, nil
+}
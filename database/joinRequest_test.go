@@ -0,0 +1,96 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/ironstone95/FlashQudoV2/config"
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+func TestGetDiscoverableGroupsWildcards(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) {})
+	user, err := db.InsertUser(*generator.GetRandomUser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"100% Spanish", "100 Spanish", "first_words", "firstXwords", `back\slash`} {
+		g := model.Group{Name: name, Visibility: model.VisibilityDiscoverable}
+		if _, err := db.InsertGroup(g, user.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "100%", want: []string{"100% Spanish"}},
+		{query: "t_w", want: []string{"first_words"}},
+		{query: `k\s`, want: []string{`back\slash`}},
+		{query: "%", want: []string{"100% Spanish"}},
+		{query: "_", want: []string{"first_words"}},
+	}
+	for _, tt := range tests {
+		groups, err := db.GetDiscoverableGroups(tt.query, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, g := range groups {
+			names = append(names, g.Name)
+		}
+		if len(names) != len(tt.want) || (len(names) != 0 && names[0] != tt.want[0]) {
+			t.Errorf("query %q found %q, want %q", tt.query, names, tt.want)
+		}
+	}
+}
+
+func TestDecideJoinRequestOfMember(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) {})
+	owner, err := db.InsertUser(*generator.GetRandomUser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, err := db.InsertGroup(model.Group{Name: "Club", Visibility: model.VisibilityDiscoverable}, owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newcomer, err := db.InsertUser(*generator.GetRandomUser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	late, err := db.InsertUser(*generator.GetRandomUser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := make(map[string]*model.JoinRequest)
+	for _, u := range []*model.User{newcomer, late} {
+		if requests[u.ID], err = db.InsertJoinRequest(group.ID, u.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jr, added, err := db.DecideJoinRequest(group.ID, requests[newcomer.ID].ID, owner.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !added || jr.Status != model.JoinRequestApproved || role(t, db, group.ID, newcomer.ID) != model.RoleViewer {
+		t.Fatalf("approval %+v added %v, want the newcomer added as a viewer", *jr, added)
+	}
+
+	// the late user is invited as an editor before the request is approved
+	if _, err := db.InsertMember(model.Member{GroupID: group.ID, UserID: late.ID, Role: model.RoleEditor}); err != nil {
+		t.Fatal(err)
+	}
+	jr, added, err = db.DecideJoinRequest(group.ID, requests[late.ID].ID, owner.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added || jr.Status != model.JoinRequestApproved {
+		t.Fatalf("approval %+v added %v, want the request approved without adding the member", *jr, added)
+	}
+	if r := role(t, db, group.ID, late.ID); r != model.RoleEditor {
+		t.Fatalf("role of the member %s after the approval, want %s", r, model.RoleEditor)
+	}
+}
@@ -0,0 +1,1767 @@
+package database
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/diff"
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+	"gorm.io/gorm"
+)
+
+// Memory is a Store which keeps the data in memory, with the same uniqueness and authorization rules as the
+// database. It is meant for the tests, the trash is kept forever.
+type Memory struct {
+	mu   *sync.Mutex
+	inTx bool // the lock is held by Transaction
+	*memoryData
+}
+
+// memoryData is the data of a Memory. Transaction works on a copy of it.
+type memoryData struct {
+	users           map[string]model.User
+	groups          map[string]model.Group
+	members         map[[2]string]model.Member // by group id and user id
+	bundles         map[string]model.Bundle
+	cards           map[string]model.Card
+	revisions       []model.CardRevision
+	tokens          map[string]model.Token // by hash
+	accessTokens    map[string]model.AccessToken
+	invites         map[string]model.Invite
+	joinRequests    map[string]model.JoinRequest
+	reviewStates    map[[2]string]model.ReviewState // by user id and card id
+	reviewLogs      []model.ReviewLog
+	schedulerParams map[string]model.SchedulerParams // by user id
+	audit           []model.AuditEntry
+}
+
+var _ Store = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	return &Memory{mu: &sync.Mutex{}, memoryData: newMemoryData()}
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		users:           make(map[string]model.User),
+		groups:          make(map[string]model.Group),
+		members:         make(map[[2]string]model.Member),
+		bundles:         make(map[string]model.Bundle),
+		cards:           make(map[string]model.Card),
+		tokens:          make(map[string]model.Token),
+		accessTokens:    make(map[string]model.AccessToken),
+		invites:         make(map[string]model.Invite),
+		joinRequests:    make(map[string]model.JoinRequest),
+		reviewStates:    make(map[[2]string]model.ReviewState),
+		schedulerParams: make(map[string]model.SchedulerParams),
+	}
+}
+
+// clone copies the data. The values are replaced on every write, so that copying the maps and slices is enough.
+func (d *memoryData) clone() *memoryData {
+	c := newMemoryData()
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+	for k, v := range d.groups {
+		c.groups[k] = v
+	}
+	for k, v := range d.members {
+		c.members[k] = v
+	}
+	for k, v := range d.bundles {
+		c.bundles[k] = v
+	}
+	for k, v := range d.cards {
+		c.cards[k] = v
+	}
+	for k, v := range d.tokens {
+		c.tokens[k] = v
+	}
+	for k, v := range d.accessTokens {
+		c.accessTokens[k] = v
+	}
+	for k, v := range d.invites {
+		c.invites[k] = v
+	}
+	for k, v := range d.joinRequests {
+		c.joinRequests[k] = v
+	}
+	for k, v := range d.reviewStates {
+		c.reviewStates[k] = v
+	}
+	for k, v := range d.schedulerParams {
+		c.schedulerParams[k] = v
+	}
+	c.revisions = append([]model.CardRevision(nil), d.revisions...)
+	c.reviewLogs = append([]model.ReviewLog(nil), d.reviewLogs...)
+	c.audit = append([]model.AuditEntry(nil), d.audit...)
+	return c
+}
+
+// Transaction runs fn with a store on a copy of the data, which replaces the data when fn returns nil.
+// The other calls wait until the transaction ends, so fn must only use s.
+func (m *Memory) Transaction(fn func(s Store) error) error {
+	m.lock()
+	defer m.unlock()
+	tx := &Memory{mu: m.mu, inTx: true, memoryData: m.memoryData.clone()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	*m.memoryData = *tx.memoryData
+	return nil
+}
+
+func (m *Memory) lock() {
+	if !m.inTx {
+		m.mu.Lock()
+	}
+}
+
+func (m *Memory) unlock() {
+	if !m.inTx {
+		m.mu.Unlock()
+	}
+}
+
+// pageBounds returns the part of the n sorted items in the page, as the start and the end index.
+func pageBounds(n, page, limit int) (int, int) {
+	start := (page - 1) * limit
+	if start < 0 {
+		start = 0
+	}
+	if start > n {
+		start = n
+	}
+	end := start + limit
+	if limit < 0 || end > n {
+		end = n
+	}
+	return start, end
+}
+
+func (m *Memory) InsertUser(u model.User) (*model.User, error) {
+	m.lock()
+	defer m.unlock()
+	if len(u.ID) == 0 || len(u.Username) == 0 {
+		return nil, ErrGormCreate
+	}
+	if _, ok := m.users[u.ID]; ok {
+		return nil, ErrUserExists
+	}
+	if _, ok := m.userByUsername(u.Username); ok {
+		return nil, ErrUsernameExists
+	}
+	if len(u.Scheduler) == 0 {
+		u.Scheduler = model.SchedulerSM2
+	}
+	if u.DesiredRetention == 0 {
+		u.DesiredRetention = model.DefaultRetention
+	}
+	u.CreatedAt = time.Now()
+	u.UpdatedAt = u.CreatedAt
+	u.Groups = nil
+	m.users[u.ID] = u
+	return &u, nil
+}
+
+// GetUser supports the id and username queries.
+func (m *Memory) GetUser(query map[string]interface{}) (*model.User, error) {
+	m.lock()
+	defer m.unlock()
+	for key, value := range query {
+		if key != "id" && key != "username" {
+			return nil, ErrNotSupported
+		}
+		if _, ok := value.(string); !ok {
+			return nil, ErrNotSupported
+		}
+	}
+	for _, u := range m.sortedUsers() {
+		if id, ok := query["id"]; ok && u.ID != id {
+			continue
+		}
+		if username, ok := query["username"]; ok && u.Username != username {
+			continue
+		}
+		return &u, nil
+	}
+	return nil, ErrUserNotFound
+}
+
+func (m *Memory) UpdateUser(userID string, updates map[string]interface{}) (*model.User, error) {
+	if len(userID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	u, ok := m.users[userID]
+	canUpdate := false
+	if imageURL, exists := updates["image_url"]; exists {
+		s, isString := imageURL.(string)
+		if !isString {
+			return nil, ErrGormUpdate
+		}
+		u.ImageURL = s
+		canUpdate = true
+	}
+	if scheduler, exists := updates["scheduler"]; exists {
+		s, isString := scheduler.(string)
+		if !isString {
+			return nil, ErrGormUpdate
+		}
+		u.Scheduler = s
+		canUpdate = true
+	}
+	if retention, exists := updates["desired_retention"]; exists {
+		f, isFloat := retention.(float64)
+		if !isFloat {
+			return nil, ErrGormUpdate
+		}
+		u.DesiredRetention = f
+		canUpdate = true
+	}
+	if !canUpdate {
+		return nil, ErrUpdateValueNotFound
+	}
+	if !ok {
+		return nil, ErrGormGet
+	}
+	u.UpdatedAt = time.Now()
+	m.users[userID] = u
+	return &u, nil
+}
+
+func (m *Memory) GetIDFromUsername(username string) (string, error) {
+	if len(username) == 0 {
+		return "", ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	u, ok := m.userByUsername(username)
+	if !ok {
+		return "", ErrUserNotFound
+	}
+	return u.ID, nil
+}
+
+func (m *Memory) InsertGroup(g model.Group, userID string) (*model.Group, error) {
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.users[userID]; !ok {
+		return nil, ErrGormCreate
+	}
+	g.ID = generator.CreateID()
+	if len(g.Visibility) == 0 {
+		g.Visibility = model.VisibilityPrivate
+	}
+	g.CreatedAt = time.Now()
+	g.Members, g.Bundles = nil, nil
+	m.groups[g.ID] = g
+	m.addMember(model.Member{GroupID: g.ID, UserID: userID, Role: model.RoleOwner})
+	return &g, nil
+}
+
+// GetGroup supports the id and name queries.
+func (m *Memory) GetGroup(query map[string]interface{}) (*model.Group, error) {
+	m.lock()
+	defer m.unlock()
+	for key, value := range query {
+		if key != "id" && key != "name" {
+			return nil, ErrNotSupported
+		}
+		if _, ok := value.(string); !ok {
+			return nil, ErrNotSupported
+		}
+	}
+	for _, g := range m.sortedGroups() {
+		if id, ok := query["id"]; ok && g.ID != id {
+			continue
+		}
+		if name, ok := query["name"]; ok && g.Name != name {
+			continue
+		}
+		return &g, nil
+	}
+	return nil, ErrGroupNotFound
+}
+
+func (m *Memory) GetUserGroups(userID string, p, limit int) ([]response.UserGroup, error) {
+	m.lock()
+	defer m.unlock()
+	var groups []response.UserGroup
+	for _, member := range m.sortedMembers() {
+		g, ok := m.groups[member.GroupID]
+		if member.UserID != userID || !ok {
+			continue
+		}
+		groups = append(groups, response.UserGroup{ID: g.ID, Name: g.Name, MemberSince: member.MemberSince, Role: member.Role})
+	}
+	start, end := pageBounds(len(groups), p, limit)
+	return groups[start:end], nil
+}
+
+func (m *Memory) UpdateGroup(groupID string, updates map[string]interface{}) (*model.Group, error) {
+	if len(groupID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	g, ok := m.groups[groupID]
+	canUpdate := false
+	if name, exists := updates["name"]; exists {
+		s, isString := name.(string)
+		if !isString {
+			return nil, ErrGormUpdate
+		}
+		g.Name = s
+		canUpdate = true
+	}
+	if visibility, exists := updates["visibility"]; exists {
+		s, isString := visibility.(string)
+		if !isString {
+			return nil, ErrGormUpdate
+		}
+		g.Visibility = s
+		canUpdate = true
+	}
+	if !canUpdate {
+		return nil, ErrUpdateValueNotFound
+	}
+	if !ok {
+		return nil, ErrGormGet
+	}
+	m.groups[groupID] = g
+	return &g, nil
+}
+
+// DeleteGroup deletes a group whose only member is the owner.
+func (m *Memory) DeleteGroup(groupID string) error {
+	if len(groupID) == 0 {
+		return ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	if m.memberCount(groupID) > 1 {
+		return ErrMembersExists
+	}
+	for key := range m.members {
+		if key[0] == groupID {
+			delete(m.members, key)
+		}
+	}
+	delete(m.groups, groupID)
+	return nil
+}
+
+// DeleteGroupCascade deletes the group with everything in it like the database, the audit log is kept.
+func (m *Memory) DeleteGroupCascade(groupID string) (*response.GroupDeletion, error) {
+	if len(groupID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.groups[groupID]; !ok {
+		return nil, ErrGroupNotFound
+	}
+	gd := response.GroupDeletion{GroupID: groupID}
+	cardIDs := make(map[string]bool)
+	for bundleID, b := range m.bundles {
+		if b.GroupID != groupID {
+			continue
+		}
+		for cardID, c := range m.cards {
+			if c.BundleID == bundleID {
+				cardIDs[cardID] = true
+			}
+		}
+		delete(m.bundles, bundleID)
+		gd.Bundles++
+	}
+	logs := m.reviewLogs[:0]
+	for _, rl := range m.reviewLogs {
+		if cardIDs[rl.CardID] {
+			gd.ReviewLogs++
+			continue
+		}
+		logs = append(logs, rl)
+	}
+	m.reviewLogs = logs
+	for key := range m.reviewStates {
+		if cardIDs[key[1]] {
+			delete(m.reviewStates, key)
+			gd.ReviewStates++
+		}
+	}
+	revisions := m.revisions[:0]
+	for _, rev := range m.revisions {
+		if cardIDs[rev.CardID] {
+			gd.CardRevisions++
+			continue
+		}
+		revisions = append(revisions, rev)
+	}
+	m.revisions = revisions
+	for cardID := range cardIDs {
+		delete(m.cards, cardID)
+		gd.Cards++
+	}
+	for id, invite := range m.invites {
+		if invite.GroupID == groupID {
+			delete(m.invites, id)
+			gd.Invites++
+		}
+	}
+	for id, jr := range m.joinRequests {
+		if jr.GroupID == groupID {
+			delete(m.joinRequests, id)
+			gd.JoinRequests++
+		}
+	}
+	for key := range m.members {
+		if key[0] == groupID {
+			delete(m.members, key)
+			gd.Members++
+		}
+	}
+	delete(m.groups, groupID)
+	return &gd, nil
+}
+
+func (m *Memory) InsertMember(member model.Member) (*model.Member, error) {
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.groups[member.GroupID]; !ok {
+		return nil, ErrGormAssocAppend
+	}
+	if _, ok := m.users[member.UserID]; !ok {
+		return nil, ErrGormAssocAppend
+	}
+	if _, ok := m.members[[2]string{member.GroupID, member.UserID}]; ok {
+		return nil, ErrAlreadyMember
+	}
+	if len(member.Role) == 0 {
+		member.Role = model.RoleViewer
+	}
+	member = m.addMember(member)
+	return &member, nil
+}
+
+func (m *Memory) GetGroupMembers(groupID string, p, limit int) ([]response.GroupMember, error) {
+	m.lock()
+	defer m.unlock()
+	var members []response.GroupMember
+	for _, member := range m.sortedMembers() {
+		u, ok := m.users[member.UserID]
+		if member.GroupID != groupID || !ok {
+			continue
+		}
+		members = append(members, response.GroupMember{ID: u.ID, Username: u.Username, ImageURL: u.ImageURL, Role: member.Role, MemberSince: member.MemberSince})
+	}
+	start, end := pageBounds(len(members), p, limit)
+	return members[start:end], nil
+}
+
+func (m *Memory) GetGroupUsers(groupID string, p, limit int) ([]model.User, error) {
+	m.lock()
+	defer m.unlock()
+	var users []model.User
+	for _, member := range m.sortedMembers() {
+		u, ok := m.users[member.UserID]
+		if member.GroupID != groupID || !ok {
+			continue
+		}
+		users = append(users, u)
+	}
+	start, end := pageBounds(len(users), p, limit)
+	return users[start:end], nil
+}
+
+func (m *Memory) UpdateMember(groupID, userID string, updates map[string]interface{}) (*model.Member, error) {
+	if len(groupID) == 0 || len(userID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	role, ok := updates["role"]
+	if !ok {
+		return nil, ErrUpdateValueNotFound
+	}
+	s, ok := role.(string)
+	if !ok {
+		return nil, ErrGormUpdate
+	}
+	m.lock()
+	defer m.unlock()
+	key := [2]string{groupID, userID}
+	member, ok := m.members[key]
+	if !ok {
+		return nil, ErrGormGet
+	}
+	member.Role = s
+	m.members[key] = member
+	return &member, nil
+}
+
+// TransferOwnership makes the member newOwnerID the owner of the group, the current owner ownerID becomes an admin.
+func (m *Memory) TransferOwnership(groupID, ownerID, newOwnerID string) (*model.Member, error) {
+	if len(groupID) == 0 || len(ownerID) == 0 || len(newOwnerID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	newOwner, ok := m.members[[2]string{groupID, newOwnerID}]
+	if !ok {
+		return nil, ErrMemberNotFound
+	}
+	owner, ok := m.members[[2]string{groupID, ownerID}]
+	if !ok || owner.Role != model.RoleOwner {
+		return nil, ErrNotOwner
+	}
+	owner.Role = model.RoleAdmin
+	m.members[[2]string{groupID, ownerID}] = owner
+	if newOwnerID == ownerID {
+		newOwner = owner
+	}
+	newOwner.Role = model.RoleOwner
+	m.members[[2]string{groupID, newOwnerID}] = newOwner
+	return &newOwner, nil
+}
+
+func (m *Memory) DeleteMember(groupID, userID string) error {
+	if len(groupID) == 0 || len(userID) == 0 {
+		return ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	delete(m.members, [2]string{groupID, userID})
+	return nil
+}
+
+func (m *Memory) IsGroupMember(groupID, userID string) (bool, error) {
+	role, err := m.GetMemberRole(groupID, userID)
+	if err != nil {
+		return false, err
+	}
+	return len(role) != 0, nil
+}
+
+func (m *Memory) GetMemberRole(groupID, userID string) (string, error) {
+	if len(groupID) == 0 || len(userID) == 0 {
+		return "", ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	return m.members[[2]string{groupID, userID}].Role, nil
+}
+
+func (m *Memory) InsertBundle(bundle model.Bundle) (*model.Bundle, error) {
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.groups[bundle.GroupID]; !ok {
+		return nil, ErrGormCreate
+	}
+	bundle.ID = generator.CreateID()
+	bundle.CreatedAt = time.Now()
+	bundle.UpdatedAt = bundle.CreatedAt
+	bundle.DeletedAt = gorm.DeletedAt{}
+	bundle.Cards = nil
+	m.bundles[bundle.ID] = bundle
+	return &bundle, nil
+}
+
+// GetBundle returns an empty bundle if the bundle does not exist or it is in the trash, like the database.
+func (m *Memory) GetBundle(bundleID string) (*response.GroupBundle, error) {
+	m.lock()
+	defer m.unlock()
+	b, ok := m.bundles[bundleID]
+	if !ok || b.DeletedAt.Valid {
+		return &response.GroupBundle{}, nil
+	}
+	gb := m.groupBundle(b)
+	return &gb, nil
+}
+
+func (m *Memory) GetGroupBundles(groupID string, p, limit int) ([]response.GroupBundle, error) {
+	m.lock()
+	defer m.unlock()
+	var bundles []model.Bundle
+	for _, b := range m.bundles {
+		if b.GroupID == groupID && !b.DeletedAt.Valid {
+			bundles = append(bundles, b)
+		}
+	}
+	sort.Slice(bundles, func(i, j int) bool {
+		return byCreation(bundles[i].CreatedAt, bundles[i].ID, bundles[j].CreatedAt, bundles[j].ID)
+	})
+	start, end := pageBounds(len(bundles), p, limit)
+	var res []response.GroupBundle
+	for _, b := range bundles[start:end] {
+		res = append(res, m.groupBundle(b))
+	}
+	return res, nil
+}
+
+func (m *Memory) UpdateBundle(bundleID string, updates map[string]interface{}) (*model.Bundle, error) {
+	if len(bundleID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	b, ok := m.bundles[bundleID]
+	canUpdate := false
+	if title, exists := updates["title"]; exists {
+		s, isString := title.(string)
+		if !isString {
+			return nil, ErrGormUpdate
+		}
+		b.Title = s
+		canUpdate = true
+	}
+	if desc, exists := updates["description"]; exists {
+		s, isString := desc.(string)
+		if !isString {
+			return nil, ErrGormUpdate
+		}
+		b.Description = s
+		canUpdate = true
+	}
+	if !canUpdate {
+		return nil, ErrUpdateValueNotFound
+	}
+	if !ok || b.DeletedAt.Valid {
+		return nil, ErrGormGet
+	}
+	b.UpdatedAt = time.Now()
+	m.bundles[bundleID] = b
+	return &b, nil
+}
+
+// DeleteBundle moves the bundle and its cards to the trash with the same deletion time.
+func (m *Memory) DeleteBundle(bundleID string) error {
+	if len(bundleID) == 0 {
+		return ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	deletedAt := gorm.DeletedAt{Time: time.Now(), Valid: true}
+	m.trashBundleCards(bundleID, deletedAt)
+	if b, ok := m.bundles[bundleID]; ok && !b.DeletedAt.Valid {
+		b.DeletedAt = deletedAt
+		m.bundles[bundleID] = b
+	}
+	return nil
+}
+
+func (m *Memory) CanSeeBundle(bundleID, userID string) (bool, error) {
+	role, err := m.GetBundleRole(bundleID, userID)
+	if err != nil {
+		return false, err
+	}
+	return len(role) != 0, nil
+}
+
+func (m *Memory) GetBundleRole(bundleID, userID string) (string, error) {
+	if len(bundleID) == 0 || len(userID) == 0 {
+		return "", ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	b, ok := m.bundles[bundleID]
+	if !ok || b.DeletedAt.Valid {
+		return "", nil
+	}
+	return m.members[[2]string{b.GroupID, userID}].Role, nil
+}
+
+// InsertCard creates the card. The question must be unique among the cards of the bundle which are not in the trash.
+func (m *Memory) InsertCard(card model.Card) (*model.Card, error) {
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.bundles[card.BundleID]; !ok || len(card.Question) == 0 {
+		return nil, ErrGormCreate
+	}
+	if m.questionExists(card.BundleID, card.Question, "") {
+		return nil, ErrQuestionExists
+	}
+	card = m.addCard(card)
+	return &card, nil
+}
+
+// ImportCards inserts the rows to the bundle like the database. In the fail mode nothing is written before
+// the conflicts are found, so that a failed import does not change the bundle.
+func (m *Memory) ImportCards(bundleID, editorID string, rows []CardRow, onConflict string) (*response.CardImport, error) {
+	if len(bundleID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	if onConflict != ConflictSkip && onConflict != ConflictOverwrite && onConflict != ConflictFail {
+		return nil, ErrInvalidConflictMode
+	}
+	m.lock()
+	defer m.unlock()
+	res := &response.CardImport{Errors: []response.RowError{}}
+	ids := make(map[string]string)
+	for _, c := range m.bundleCards(bundleID) {
+		ids[c.Question] = c.ID
+	}
+
+	var cards []model.Card
+	pending := make(map[string]int) // question to index in cards
+	for _, row := range rows {
+		id, exists := ids[row.Question]
+		i, isPending := pending[row.Question]
+		if !exists && !isPending {
+			pending[row.Question] = len(cards)
+			cards = append(cards, model.Card{BundleID: bundleID, Question: row.Question, Answer: row.Answer})
+			continue
+		}
+		switch onConflict {
+		case ConflictFail:
+			res.Errors = append(res.Errors, response.RowError{Row: row.Row, Message: ErrQuestionExists.Error()})
+		case ConflictSkip:
+			res.Skipped++
+		case ConflictOverwrite:
+			if isPending {
+				cards[i].Answer = row.Answer
+				res.Updated++
+				continue
+			}
+			answer := row.Answer
+			c, err := m.updateCard(id, editorID, nil, &answer, nil)
+			if err != nil {
+				return nil, err
+			}
+			res.Overwritten = append(res.Overwritten, *c)
+			res.Updated++
+		}
+	}
+	if len(res.Errors) != 0 {
+		return res, ErrQuestionExists
+	}
+	if _, ok := m.bundles[bundleID]; !ok && len(cards) != 0 {
+		return nil, ErrGormCreate
+	}
+	for _, c := range cards {
+		m.addCard(c)
+	}
+	res.Inserted = len(cards)
+	return res, nil
+}
+
+func (m *Memory) GetBundleCards(bundleID string, p, limit int) ([]model.Card, error) {
+	m.lock()
+	defer m.unlock()
+	cards := m.bundleCards(bundleID)
+	start, end := pageBounds(len(cards), p, limit)
+	return cards[start:end], nil
+}
+
+func (m *Memory) GetAllBundleCards(bundleID string) ([]model.Card, error) {
+	m.lock()
+	defer m.unlock()
+	return m.bundleCards(bundleID), nil
+}
+
+// UpdateCard updates the question and/or the answer of the card, and records the change as a revision by editorID.
+func (m *Memory) UpdateCard(cardID, editorID string, updates map[string]interface{}) (*model.Card, error) {
+	if len(cardID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	var question, answer *string
+	if q, ok := updates["question"].(string); ok {
+		question = &q
+	}
+	if a, ok := updates["answer"].(string); ok {
+		answer = &a
+	}
+	if question == nil && answer == nil {
+		return nil, ErrUpdateValueNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	return m.updateCard(cardID, editorID, question, answer, nil)
+}
+
+func (m *Memory) DeleteCard(cardID string) error {
+	if len(cardID) == 0 {
+		return ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	if c, ok := m.cards[cardID]; ok && !c.DeletedAt.Valid {
+		c.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		m.cards[cardID] = c
+	}
+	return nil
+}
+
+func (m *Memory) DeleteBundleCards(bundleID string) error {
+	if len(bundleID) == 0 {
+		return ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	m.trashBundleCards(bundleID, gorm.DeletedAt{Time: time.Now(), Valid: true})
+	return nil
+}
+
+func (m *Memory) GetCardRole(cardID, userID string) (string, error) {
+	if len(cardID) == 0 || len(userID) == 0 {
+		return "", ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	c, ok := m.cards[cardID]
+	if !ok || c.DeletedAt.Valid {
+		return "", nil
+	}
+	b, ok := m.bundles[c.BundleID]
+	if !ok || b.DeletedAt.Valid {
+		return "", nil
+	}
+	return m.members[[2]string{b.GroupID, userID}].Role, nil
+}
+
+func (m *Memory) AuthToken(token string) (*model.Token, error) {
+	m.lock()
+	defer m.unlock()
+	hash := hashToken(token)
+	t, ok := m.tokens[hash]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	if t.Expires < time.Now().Unix() {
+		delete(m.tokens, hash)
+		return nil, ErrTokenExpired
+	}
+	if t.RevokedAt != nil {
+		return nil, ErrTokenRevoked
+	}
+	return &t, nil
+}
+
+func (m *Memory) InsertToken(token, userID string, issuedAt, expires int64) (*model.Token, error) {
+	m.lock()
+	defer m.unlock()
+	if u, ok := m.users[userID]; ok && issuedAt < u.TokensValidAfter {
+		return nil, ErrTokenRevoked
+	}
+	t := model.Token{Hash: hashToken(token), UserID: userID, Expires: expires, CheckedAt: time.Now().Unix()}
+	if _, ok := m.tokens[t.Hash]; ok {
+		return nil, ErrGormCreate
+	}
+	m.tokens[t.Hash] = t
+	return &t, nil
+}
+
+func (m *Memory) MarkTokenChecked(token string) error {
+	m.lock()
+	defer m.unlock()
+	hash := hashToken(token)
+	if t, ok := m.tokens[hash]; ok {
+		t.CheckedAt = time.Now().Unix()
+		m.tokens[hash] = t
+	}
+	return nil
+}
+
+func (m *Memory) RevokeToken(token string) error {
+	m.lock()
+	defer m.unlock()
+	hash := hashToken(token)
+	t, ok := m.tokens[hash]
+	if !ok || t.RevokedAt != nil {
+		return ErrTokenNotFound
+	}
+	now := time.Now()
+	t.RevokedAt = &now
+	m.tokens[hash] = t
+	return nil
+}
+
+func (m *Memory) RevokeUserTokens(userID string) error {
+	if len(userID) == 0 {
+		return ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	now := time.Now()
+	for hash, t := range m.tokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+			m.tokens[hash] = t
+		}
+	}
+	if u, ok := m.users[userID]; ok {
+		// tokens issued in the current second are rejected too
+		u.TokensValidAfter = now.Unix() + 1
+		m.users[userID] = u
+	}
+	return nil
+}
+
+func (m *Memory) InsertAccessToken(token model.AccessToken) (*response.AccessToken, error) {
+	if len(token.UserID) == 0 || len(token.Name) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.users[token.UserID]; !ok {
+		return nil, ErrGormCreate
+	}
+	raw := generator.CreateAccessToken(model.AccessTokenPrefix)
+	token.ID = generator.CreateID()
+	token.Hash = hashToken(raw)
+	token.Prefix = raw[:accessTokenPrefixLength]
+	token.CreatedAt = time.Now()
+	token.User = model.User{}
+	m.accessTokens[token.ID] = token
+	res := accessTokenResponse(token)
+	res.Token = raw
+	return &res, nil
+}
+
+func (m *Memory) GetAccessTokens(userID string) ([]response.AccessToken, error) {
+	if len(userID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	var tokens []model.AccessToken
+	for _, t := range m.accessTokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return byLatest(tokens[i].CreatedAt, tokens[i].ID, tokens[j].CreatedAt, tokens[j].ID)
+	})
+	res := make([]response.AccessToken, 0, len(tokens))
+	for _, t := range tokens {
+		res = append(res, accessTokenResponse(t))
+	}
+	return res, nil
+}
+
+func (m *Memory) AuthAccessToken(token string) (*model.AccessToken, error) {
+	m.lock()
+	defer m.unlock()
+	hash := hashToken(token)
+	for id, t := range m.accessTokens {
+		if t.Hash != hash {
+			continue
+		}
+		now := time.Now()
+		if t.RevokedAt != nil || (t.ExpiresAt != nil && t.ExpiresAt.Before(now)) {
+			return nil, ErrAccessTokenInvalid
+		}
+		t.LastUsedAt = &now
+		m.accessTokens[id] = t
+		return &t, nil
+	}
+	return nil, ErrAccessTokenNotFound
+}
+
+func (m *Memory) RevokeAccessToken(userID, tokenID string) error {
+	if len(userID) == 0 || len(tokenID) == 0 {
+		return ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	t, ok := m.accessTokens[tokenID]
+	if !ok || t.UserID != userID || t.RevokedAt != nil {
+		return ErrAccessTokenNotFound
+	}
+	now := time.Now()
+	t.RevokedAt = &now
+	m.accessTokens[tokenID] = t
+	return nil
+}
+
+func (m *Memory) InsertInvite(invite model.Invite) (*model.Invite, error) {
+	if len(invite.GroupID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.groups[invite.GroupID]; !ok {
+		return nil, ErrGormCreate
+	}
+	invite.ID = generator.CreateID()
+	invite.Code = generator.CreateCode()
+	invite.Uses = 0
+	invite.RevokedAt = nil
+	if len(invite.Role) == 0 {
+		invite.Role = model.RoleViewer
+	}
+	invite.CreatedAt = time.Now()
+	invite.Group = model.Group{}
+	m.invites[invite.ID] = invite
+	return &invite, nil
+}
+
+// GetGroupInvites returns the invites of the group, newest first. Revoked and expired invites are included.
+func (m *Memory) GetGroupInvites(groupID string, p, limit int) ([]model.Invite, error) {
+	m.lock()
+	defer m.unlock()
+	invites := []model.Invite{}
+	for _, invite := range m.invites {
+		if invite.GroupID == groupID {
+			invites = append(invites, invite)
+		}
+	}
+	sort.Slice(invites, func(i, j int) bool {
+		return byLatest(invites[i].CreatedAt, invites[i].ID, invites[j].CreatedAt, invites[j].ID)
+	})
+	start, end := pageBounds(len(invites), p, limit)
+	return invites[start:end], nil
+}
+
+func (m *Memory) RevokeInvite(groupID, inviteID string) error {
+	if len(groupID) == 0 || len(inviteID) == 0 {
+		return ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	invite, ok := m.invites[inviteID]
+	if !ok || invite.GroupID != groupID || invite.RevokedAt != nil {
+		return ErrInviteNotFound
+	}
+	now := time.Now()
+	invite.RevokedAt = &now
+	m.invites[inviteID] = invite
+	return nil
+}
+
+// AcceptInvite makes the user a member of the group of the invite with the given code and uses the invite once.
+func (m *Memory) AcceptInvite(code, userID string) (*model.Member, error) {
+	if len(code) == 0 || len(userID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	var invite model.Invite
+	found := false
+	for _, i := range m.invites {
+		if i.Code == code {
+			invite, found = i, true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrInviteNotFound
+	}
+	if invite.RevokedAt != nil || !invite.ExpiresAt.After(time.Now()) || (invite.MaxUses != 0 && invite.Uses >= invite.MaxUses) {
+		return nil, ErrInviteInvalid
+	}
+	if _, ok := m.members[[2]string{invite.GroupID, userID}]; ok {
+		return nil, ErrAlreadyMember
+	}
+	invite.Uses++
+	m.invites[invite.ID] = invite
+	member := m.addMember(model.Member{GroupID: invite.GroupID, UserID: userID, Role: invite.Role})
+	return &member, nil
+}
+
+// GetDiscoverableGroups returns the discoverable and open groups whose name contains the query, ignoring the case.
+func (m *Memory) GetDiscoverableGroups(query string, p, limit int) ([]response.GroupListing, error) {
+	m.lock()
+	defer m.unlock()
+	q := strings.ToLower(strings.TrimSpace(query))
+	groups := []response.GroupListing{}
+	for _, g := range m.groups {
+		if g.Visibility != model.VisibilityDiscoverable && g.Visibility != model.VisibilityOpen {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(g.Name), q) {
+			continue
+		}
+		groups = append(groups, response.GroupListing{ID: g.ID, Name: g.Name, Visibility: g.Visibility, MemberCount: int(m.memberCount(g.ID))})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].MemberCount != groups[j].MemberCount {
+			return groups[i].MemberCount > groups[j].MemberCount
+		}
+		if groups[i].Name != groups[j].Name {
+			return groups[i].Name < groups[j].Name
+		}
+		return groups[i].ID < groups[j].ID
+	})
+	start, end := pageBounds(len(groups), p, limit)
+	return groups[start:end], nil
+}
+
+// InsertJoinRequest creates a join request of the user to the group. Requests to open groups are approved
+// immediately and the user becomes a member, requests to discoverable groups wait for an admin.
+func (m *Memory) InsertJoinRequest(groupID, userID string) (*model.JoinRequest, error) {
+	if len(groupID) == 0 || len(userID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	g, ok := m.groups[groupID]
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+	if g.Visibility != model.VisibilityDiscoverable && g.Visibility != model.VisibilityOpen {
+		return nil, ErrGroupNotJoinable
+	}
+	if _, ok := m.members[[2]string{groupID, userID}]; ok {
+		return nil, ErrAlreadyMember
+	}
+	for _, jr := range m.joinRequests {
+		if jr.GroupID == groupID && jr.UserID == userID && jr.Status == model.JoinRequestPending {
+			return nil, ErrJoinRequestExists
+		}
+	}
+	jr := model.JoinRequest{ID: generator.CreateID(), GroupID: groupID, UserID: userID, Status: model.JoinRequestPending, CreatedAt: time.Now()}
+	if g.Visibility == model.VisibilityOpen {
+		now := time.Now()
+		jr.Status = model.JoinRequestApproved
+		jr.DecidedAt = &now
+		m.addMember(model.Member{GroupID: groupID, UserID: userID, Role: model.RoleViewer})
+	}
+	m.joinRequests[jr.ID] = jr
+	return &jr, nil
+}
+
+// GetGroupJoinRequests returns the join requests of the group with the given status, oldest first.
+// All requests are returned if status is empty.
+func (m *Memory) GetGroupJoinRequests(groupID, status string, p, limit int) ([]model.JoinRequest, error) {
+	m.lock()
+	defer m.unlock()
+	requests := []model.JoinRequest{}
+	for _, jr := range m.joinRequests {
+		if jr.GroupID == groupID && (len(status) == 0 || jr.Status == status) {
+			requests = append(requests, jr)
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return byCreation(requests[i].CreatedAt, requests[i].ID, requests[j].CreatedAt, requests[j].ID)
+	})
+	start, end := pageBounds(len(requests), p, limit)
+	return requests[start:end], nil
+}
+
+// DecideJoinRequest approves or rejects the pending join request of the group. Approving makes the user a member,
+// added is false if the user has become a member another way since the request.
+func (m *Memory) DecideJoinRequest(groupID, requestID, deciderID string, approve bool) (*model.JoinRequest, bool, error) {
+	if len(groupID) == 0 || len(requestID) == 0 {
+		return nil, false, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	jr, ok := m.joinRequests[requestID]
+	if !ok || jr.GroupID != groupID {
+		return nil, false, ErrJoinRequestNotFound
+	}
+	if jr.Status != model.JoinRequestPending {
+		return nil, false, ErrJoinRequestDecided
+	}
+	jr.Status = model.JoinRequestRejected
+	if approve {
+		jr.Status = model.JoinRequestApproved
+	}
+	now := time.Now()
+	jr.DecidedBy, jr.DecidedAt = deciderID, &now
+	m.joinRequests[requestID] = jr
+	added := false
+	if _, isMember := m.members[[2]string{groupID, jr.UserID}]; approve && !isMember {
+		m.addMember(model.Member{GroupID: groupID, UserID: jr.UserID, Role: model.RoleViewer})
+		added = true
+	}
+	return &jr, added, nil
+}
+
+// ReviewCard grades the card for the user and reschedules it with the scheduler selected by the user, like the database.
+func (m *Memory) ReviewCard(userID, cardID string, grade int, responseTimeMs int64) (*model.ReviewState, error) {
+	if len(userID) == 0 || len(cardID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	if grade < minGrade || grade > maxGrade {
+		return nil, ErrInvalidGrade
+	}
+	m.lock()
+	defer m.unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	if _, ok := m.cards[cardID]; !ok {
+		return nil, ErrGormSave
+	}
+	key := [2]string{userID, cardID}
+	rs, ok := m.reviewStates[key]
+	if !ok {
+		rs = model.ReviewState{UserID: userID, CardID: cardID, EaseFactor: defaultEaseFactor}
+	}
+	params := model.SchedulerParams{UserID: userID, StabilityScale: 1}
+	if saved, ok := m.schedulerParams[userID]; ok && u.Scheduler == model.SchedulerFSRS {
+		params = saved
+	}
+
+	now := time.Now()
+	rl := model.ReviewLog{
+		ID:             generator.CreateID(),
+		UserID:         userID,
+		CardID:         cardID,
+		Grade:          grade,
+		ResponseTimeMs: responseTimeMs,
+		IntervalBefore: rs.Interval,
+		CreatedAt:      now,
+	}
+	newScheduler(&u, &params).Schedule(&rs, grade, now)
+	rs.UpdatedAt = now
+	m.reviewStates[key] = rs
+	rl.IntervalAfter = rs.Interval
+	m.reviewLogs = append(m.reviewLogs, rl)
+	if u.Scheduler == model.SchedulerFSRS {
+		params.UpdatedAt = now
+		m.schedulerParams[userID] = params
+	}
+	return &rs, nil
+}
+
+// GetDueCards returns the cards of every group of the user whose review is due, followed by the cards never reviewed.
+func (m *Memory) GetDueCards(userID string, q DueQuery) (*response.StudyQueue, error) {
+	if len(userID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	now := time.Now()
+	var due, unseen []model.Card
+	for _, c := range m.studyCards(userID) {
+		b := m.bundles[c.BundleID]
+		if (len(q.GroupID) != 0 && b.GroupID != q.GroupID) || (len(q.BundleID) != 0 && b.ID != q.BundleID) {
+			continue
+		}
+		if rs, ok := m.reviewStates[[2]string{userID, c.ID}]; !ok {
+			unseen = append(unseen, c)
+		} else if !rs.DueAt.After(now) {
+			due = append(due, c)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return byCreation(m.reviewStates[[2]string{userID, due[i].ID}].DueAt, due[i].ID,
+			m.reviewStates[[2]string{userID, due[j].ID}].DueAt, due[j].ID)
+	})
+	sort.Slice(unseen, func(i, j int) bool {
+		return byCreation(unseen[i].CreatedAt, unseen[i].ID, unseen[j].CreatedAt, unseen[j].ID)
+	})
+
+	var reviews, newCards []response.DueCard
+	for i := 0; i < len(due) && i < q.ReviewLimit; i++ {
+		c := due[i]
+		rs := m.reviewStates[[2]string{userID, c.ID}]
+		reviews = append(reviews, response.DueCard{
+			ID: c.ID, BundleID: c.BundleID, GroupID: m.bundles[c.BundleID].GroupID, Question: c.Question, Answer: c.Answer,
+			DueAt: &rs.DueAt, Interval: rs.Interval, Repetitions: rs.Repetitions,
+		})
+	}
+	for i := 0; i < len(unseen) && i < q.NewLimit; i++ {
+		c := unseen[i]
+		newCards = append(newCards, response.DueCard{
+			ID: c.ID, BundleID: c.BundleID, GroupID: m.bundles[c.BundleID].GroupID, Question: c.Question, Answer: c.Answer, IsNew: true,
+		})
+	}
+
+	cards := append(reviews, newCards...)
+	if q.Interleave {
+		cards = interleaveByBundle(cards)
+	}
+	if cards == nil {
+		cards = []response.DueCard{}
+	}
+	return &response.StudyQueue{NewCount: len(newCards), ReviewCount: len(reviews), Cards: cards}, nil
+}
+
+// GetCardStats returns the statistics of the review log of the card for the user.
+// Lapses and retention only count the reviews of cards which were reviewed before.
+func (m *Memory) GetCardStats(userID, cardID string) (*response.CardStats, error) {
+	if len(userID) == 0 || len(cardID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	stats := response.CardStats{CardID: cardID}
+	grades, recalls, matured := 0, 0, 0
+	for _, rl := range m.reviewLogs {
+		if rl.UserID != userID || rl.CardID != cardID {
+			continue
+		}
+		stats.Reviews++
+		grades += rl.Grade
+		stats.TimeSpentMs += rl.ResponseTimeMs
+		if rl.IntervalBefore > 0 {
+			matured++
+			if rl.Grade < 3 {
+				stats.Lapses++
+			} else {
+				recalls++
+			}
+		}
+	}
+	if stats.Reviews > 0 {
+		stats.AverageGrade = float64(grades) / float64(stats.Reviews)
+	}
+	if matured > 0 {
+		stats.Retention = float64(recalls) / float64(matured)
+	}
+	if rs, ok := m.reviewStates[[2]string{userID, cardID}]; ok {
+		stats.Interval = rs.Interval
+		stats.LastReviewedAt = &rs.LastReviewedAt
+		stats.DueAt = &rs.DueAt
+	}
+	return &stats, nil
+}
+
+// SearchCards finds the cards the user can see which contain every word of the query, like the database on SQLite.
+func (m *Memory) SearchCards(userID, query string, p, limit int) ([]response.SearchHit, error) {
+	if len(userID) == 0 || len(strings.TrimSpace(query)) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	words := strings.Fields(strings.ToLower(query))
+	hits := []response.SearchHit{}
+	for _, c := range m.studyCards(userID) {
+		question, answer := strings.ToLower(c.Question), strings.ToLower(c.Answer)
+		rank := 0
+		for _, w := range words {
+			if !strings.Contains(question+" "+answer, w) {
+				rank = -1
+				break
+			}
+			if strings.Contains(question, w) {
+				rank += 2
+			}
+			if strings.Contains(answer, w) {
+				rank++
+			}
+		}
+		if rank < 0 {
+			continue
+		}
+		b := m.bundles[c.BundleID]
+		hits = append(hits, response.SearchHit{
+			CardID:          c.ID,
+			BundleID:        b.ID,
+			BundleTitle:     b.Title,
+			GroupID:         b.GroupID,
+			GroupName:       m.groups[b.GroupID].Name,
+			QuestionSnippet: highlight(c.Question, words),
+			AnswerSnippet:   highlight(c.Answer, words),
+			Rank:            float64(rank),
+		})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Rank != hits[j].Rank {
+			return hits[i].Rank > hits[j].Rank
+		}
+		return hits[i].CardID < hits[j].CardID
+	})
+	start, end := pageBounds(len(hits), p, limit)
+	return hits[start:end], nil
+}
+
+// GetGroupTrash returns the deleted bundles and cards of the group, the latest deleted first.
+// Cards deleted with their bundle are not listed one by one.
+func (m *Memory) GetGroupTrash(groupID string, p, limit int) ([]response.TrashItem, error) {
+	if len(groupID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	if p < 1 {
+		p = 1
+	}
+	m.lock()
+	defer m.unlock()
+	items := []response.TrashItem{}
+	for _, b := range m.bundles {
+		if b.GroupID != groupID || !b.DeletedAt.Valid {
+			continue
+		}
+		item := response.TrashItem{ID: b.ID, Kind: response.TrashBundle, Title: b.Title, DeletedAt: b.DeletedAt.Time}
+		for _, c := range m.cards {
+			if c.BundleID == b.ID && c.DeletedAt.Valid && c.DeletedAt.Time.Equal(b.DeletedAt.Time) {
+				item.CardCount++
+			}
+		}
+		items = append(items, item)
+	}
+	for _, c := range m.cards {
+		b, ok := m.bundles[c.BundleID]
+		if !ok || b.GroupID != groupID || !c.DeletedAt.Valid || b.DeletedAt.Valid {
+			continue
+		}
+		items = append(items, response.TrashItem{
+			ID: c.ID, Kind: response.TrashCard, Title: c.Question, BundleID: c.BundleID, CardCount: 1, DeletedAt: c.DeletedAt.Time,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return byLatest(items[i].DeletedAt, items[i].ID, items[j].DeletedAt, items[j].ID)
+	})
+	start, end := pageBounds(len(items), p, limit)
+	return items[start:end], nil
+}
+
+// RestoreTrashItem restores the deleted bundle or card with the id. A bundle is restored with the cards deleted with it.
+// A card cannot be restored while its bundle is in the trash or another card of the bundle has the same question.
+func (m *Memory) RestoreTrashItem(itemID string) (*response.TrashItem, error) {
+	if len(itemID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	if b, ok := m.bundles[itemID]; ok && b.DeletedAt.Valid {
+		item := response.TrashItem{ID: itemID, Kind: response.TrashBundle, Title: b.Title, DeletedAt: b.DeletedAt.Time}
+		for id, c := range m.cards {
+			if c.BundleID == itemID && c.DeletedAt.Valid && c.DeletedAt.Time.Equal(b.DeletedAt.Time) {
+				c.DeletedAt = gorm.DeletedAt{}
+				m.cards[id] = c
+				item.CardCount++
+			}
+		}
+		b.DeletedAt = gorm.DeletedAt{}
+		m.bundles[itemID] = b
+		return &item, nil
+	}
+
+	c, ok := m.cards[itemID]
+	if !ok || !c.DeletedAt.Valid {
+		return nil, ErrTrashItemNotFound
+	}
+	if b, ok := m.bundles[c.BundleID]; ok && b.DeletedAt.Valid {
+		return nil, ErrBundleInTrash
+	}
+	if m.questionExists(c.BundleID, c.Question, "") {
+		return nil, ErrQuestionExists
+	}
+	item := response.TrashItem{ID: itemID, Kind: response.TrashCard, Title: c.Question, BundleID: c.BundleID, CardCount: 1, DeletedAt: c.DeletedAt.Time}
+	c.DeletedAt = gorm.DeletedAt{}
+	m.cards[itemID] = c
+	return &item, nil
+}
+
+// GetTrashItemRole returns the role of the user in the group of the bundle or card with the id, deleted or not.
+// Empty if the user is not a member.
+func (m *Memory) GetTrashItemRole(itemID, userID string) (string, error) {
+	if len(itemID) == 0 || len(userID) == 0 {
+		return "", ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	bundleID := itemID
+	if c, ok := m.cards[itemID]; ok {
+		bundleID = c.BundleID
+	}
+	b, ok := m.bundles[bundleID]
+	if !ok {
+		return "", nil
+	}
+	return m.members[[2]string{b.GroupID, userID}].Role, nil
+}
+
+// GetCardRevisions returns the revisions of the card with the word diffs of the question and the answer, the latest first.
+func (m *Memory) GetCardRevisions(cardID string, p, limit int) ([]response.CardRevision, error) {
+	if len(cardID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	var matches []model.CardRevision
+	for _, rev := range m.revisions {
+		if rev.CardID == cardID {
+			matches = append(matches, rev)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return byLatest(matches[i].CreatedAt, matches[i].ID, matches[j].CreatedAt, matches[j].ID)
+	})
+	start, end := pageBounds(len(matches), p, limit)
+	revisions := []response.CardRevision{}
+	for _, rev := range matches[start:end] {
+		revisions = append(revisions, response.CardRevision{
+			ID:             rev.ID,
+			CardID:         rev.CardID,
+			EditorID:       rev.EditorID,
+			EditorUsername: m.users[rev.EditorID].Username,
+			OldQuestion:    rev.OldQuestion,
+			NewQuestion:    rev.NewQuestion,
+			OldAnswer:      rev.OldAnswer,
+			NewAnswer:      rev.NewAnswer,
+			RevertOf:       rev.RevertOf,
+			CreatedAt:      rev.CreatedAt,
+			QuestionDiff:   diff.Words(rev.OldQuestion, rev.NewQuestion),
+			AnswerDiff:     diff.Words(rev.OldAnswer, rev.NewAnswer),
+		})
+	}
+	return revisions, nil
+}
+
+// RevertCardRevision sets the question and the answer of the card back to the values before the revision.
+// The revert is recorded as a new revision by editorID.
+func (m *Memory) RevertCardRevision(cardID, revisionID, editorID string) (*model.Card, error) {
+	if len(cardID) == 0 || len(revisionID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	for _, rev := range m.revisions {
+		if rev.ID == revisionID && rev.CardID == cardID {
+			return m.updateCard(cardID, editorID, &rev.OldQuestion, &rev.OldAnswer, &rev.ID)
+		}
+	}
+	return nil, ErrRevisionNotFound
+}
+
+// InsertAuditEntry records the entry. If the group of the entry is empty, it is found from the bundle or card
+// target, including the ones in the trash.
+func (m *Memory) InsertAuditEntry(entry model.AuditEntry) error {
+	m.lock()
+	defer m.unlock()
+	if len(entry.GroupID) == 0 {
+		bundleID := entry.TargetID
+		switch entry.TargetType {
+		case model.TargetBundle:
+		case model.TargetCard:
+			bundleID = m.cards[entry.TargetID].BundleID
+		default:
+			return ErrParamNotFound
+		}
+		b, ok := m.bundles[bundleID]
+		if !ok {
+			return ErrGroupNotFound
+		}
+		entry.GroupID = b.GroupID
+	}
+	if len(entry.ActorID) == 0 || len(entry.Action) == 0 {
+		return ErrParamNotFound
+	}
+	entry.ID = generator.CreateID()
+	entry.CreatedAt = time.Now()
+	m.audit = append(m.audit, entry)
+	return nil
+}
+
+// GetAuditEntries returns the audit log of the group filtered by q, the latest first.
+func (m *Memory) GetAuditEntries(groupID string, q AuditQuery, p, limit int) ([]response.AuditEntry, error) {
+	if len(groupID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m.lock()
+	defer m.unlock()
+	var matches []model.AuditEntry
+	for _, e := range m.audit {
+		if e.GroupID != groupID ||
+			(len(q.ActorID) != 0 && e.ActorID != q.ActorID) ||
+			(len(q.Action) != 0 && e.Action != q.Action) ||
+			(q.From != nil && e.CreatedAt.Before(*q.From)) ||
+			(q.To != nil && !e.CreatedAt.Before(*q.To)) {
+			continue
+		}
+		matches = append(matches, e)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return byLatest(matches[i].CreatedAt, matches[i].ID, matches[j].CreatedAt, matches[j].ID)
+	})
+	start, end := pageBounds(len(matches), p, limit)
+	entries := []response.AuditEntry{}
+	for _, e := range matches[start:end] {
+		entries = append(entries, response.AuditEntry{
+			ID:            e.ID,
+			ActorID:       e.ActorID,
+			ActorUsername: m.users[e.ActorID].Username,
+			Action:        e.Action,
+			TargetType:    e.TargetType,
+			TargetID:      e.TargetID,
+			Before:        e.Before,
+			After:         e.After,
+			CreatedAt:     e.CreatedAt,
+		})
+	}
+	return entries, nil
+}
+
+// the helpers below must be called with the lock held
+
+func (m *Memory) userByUsername(username string) (model.User, bool) {
+	for _, u := range m.users {
+		if u.Username == username {
+			return u, true
+		}
+	}
+	return model.User{}, false
+}
+
+func (m *Memory) sortedUsers() []model.User {
+	users := make([]model.User, 0, len(m.users))
+	for _, u := range m.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return byCreation(users[i].CreatedAt, users[i].ID, users[j].CreatedAt, users[j].ID)
+	})
+	return users
+}
+
+func (m *Memory) sortedGroups() []model.Group {
+	groups := make([]model.Group, 0, len(m.groups))
+	for _, g := range m.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return byCreation(groups[i].CreatedAt, groups[i].ID, groups[j].CreatedAt, groups[j].ID)
+	})
+	return groups
+}
+
+func (m *Memory) sortedMembers() []model.Member {
+	members := make([]model.Member, 0, len(m.members))
+	for _, member := range m.members {
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return byCreation(members[i].MemberSince, members[i].GroupID+":"+members[i].UserID,
+			members[j].MemberSince, members[j].GroupID+":"+members[j].UserID)
+	})
+	return members
+}
+
+func (m *Memory) memberCount(groupID string) int64 {
+	var count int64
+	for key := range m.members {
+		if key[0] == groupID {
+			count++
+		}
+	}
+	return count
+}
+
+func (m *Memory) addMember(member model.Member) model.Member {
+	member.MemberSince = time.Now()
+	m.members[[2]string{member.GroupID, member.UserID}] = member
+	return member
+}
+
+func (m *Memory) addCard(card model.Card) model.Card {
+	card.ID = generator.CreateID()
+	card.CreatedAt = time.Now()
+	card.UpdatedAt = card.CreatedAt
+	card.DeletedAt = gorm.DeletedAt{}
+	m.cards[card.ID] = card
+	return card
+}
+
+// updateCard sets the non-nil question and answer of the card, and records the revision like the database.
+func (m *Memory) updateCard(cardID, editorID string, question, answer, revertOf *string) (*model.Card, error) {
+	c, ok := m.cards[cardID]
+	if !ok || c.DeletedAt.Valid {
+		return nil, ErrGormGet
+	}
+	now := time.Now()
+	rev := model.CardRevision{
+		ID:          generator.CreateID(),
+		CardID:      cardID,
+		EditorID:    editorID,
+		OldQuestion: c.Question,
+		NewQuestion: c.Question,
+		OldAnswer:   c.Answer,
+		NewAnswer:   c.Answer,
+		RevertOf:    revertOf,
+		CreatedAt:   now,
+	}
+	if question != nil {
+		rev.NewQuestion = *question
+	}
+	if answer != nil {
+		rev.NewAnswer = *answer
+	}
+	if rev.NewQuestion == rev.OldQuestion && rev.NewAnswer == rev.OldAnswer {
+		return &c, nil
+	}
+	if rev.NewQuestion != rev.OldQuestion && m.questionExists(c.BundleID, rev.NewQuestion, cardID) {
+		return nil, ErrQuestionExists
+	}
+	c.Question, c.Answer, c.UpdatedAt = rev.NewQuestion, rev.NewAnswer, now
+	m.cards[cardID] = c
+	m.revisions = append(m.revisions, rev)
+	return &c, nil
+}
+
+// trashBundleCards moves the cards of the bundle which are not in the trash to the trash.
+func (m *Memory) trashBundleCards(bundleID string, deletedAt gorm.DeletedAt) {
+	for id, c := range m.cards {
+		if c.BundleID == bundleID && !c.DeletedAt.Valid {
+			c.DeletedAt = deletedAt
+			m.cards[id] = c
+		}
+	}
+}
+
+// studyCards returns the cards the user can see, which are not in the trash.
+func (m *Memory) studyCards(userID string) []model.Card {
+	var cards []model.Card
+	for _, c := range m.cards {
+		b, ok := m.bundles[c.BundleID]
+		if !ok || c.DeletedAt.Valid || b.DeletedAt.Valid {
+			continue
+		}
+		if _, isMember := m.members[[2]string{b.GroupID, userID}]; isMember {
+			cards = append(cards, c)
+		}
+	}
+	return cards
+}
+
+// bundleCards returns the cards of the bundle which are not in the trash, in the order of creation.
+func (m *Memory) bundleCards(bundleID string) []model.Card {
+	var cards []model.Card
+	for _, c := range m.cards {
+		if c.BundleID == bundleID && !c.DeletedAt.Valid {
+			cards = append(cards, c)
+		}
+	}
+	sort.Slice(cards, func(i, j int) bool {
+		return byCreation(cards[i].CreatedAt, cards[i].ID, cards[j].CreatedAt, cards[j].ID)
+	})
+	return cards
+}
+
+// questionExists checks another card of the bundle which is not in the trash has the question.
+func (m *Memory) questionExists(bundleID, question, cardID string) bool {
+	for _, c := range m.cards {
+		if c.BundleID == bundleID && c.Question == question && c.ID != cardID && !c.DeletedAt.Valid {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) groupBundle(b model.Bundle) response.GroupBundle {
+	gb := response.GroupBundle{ID: b.ID, Title: b.Title, Description: b.Description, GroupID: b.GroupID}
+	for _, c := range m.cards {
+		if c.BundleID == b.ID && !c.DeletedAt.Valid {
+			gb.CardCount++
+		}
+	}
+	return gb
+}
+
+// byCreation orders by the creation time, then by the id.
+func byCreation(t1 time.Time, id1 string, t2 time.Time, id2 string) bool {
+	if !t1.Equal(t2) {
+		return t1.Before(t2)
+	}
+	return id1 < id2
+}
+
+// byLatest orders by the time, the latest first, then by the id.
+func byLatest(t1 time.Time, id1 string, t2 time.Time, id2 string) bool {
+	if !t1.Equal(t2) {
+		return t1.After(t2)
+	}
+	return id1 < id2
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ironstone95/FlashQudoV2/config"
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+// storeBundle inserts a user, a group of the user and a bundle with n cards to the store.
+func storeBundle(t *testing.T, s Store, n int) (*model.User, *model.Bundle, []*model.Card) {
+	t.Helper()
+	u, err := s.InsertUser(*generator.GetRandomUser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := s.InsertGroup(*generator.GetRandomGroup(), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.InsertBundle(*generator.GetRandomBundle(g.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cards []*model.Card
+	for i := 0; i < n; i++ {
+		c, err := s.InsertCard(*generator.GetRandomCard(b.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cards = append(cards, c)
+	}
+	return u, b, cards
+}
+
+func TestMemoryTransaction(t *testing.T) {
+	m := NewMemory()
+	u, b, _ := storeBundle(t, m, 0)
+	errRollback := errors.New("rollback")
+
+	err := m.Transaction(func(s Store) error {
+		if _, err := s.InsertCard(model.Card{BundleID: b.ID, Question: "Rolled back", Answer: "A"}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("transaction returned %v, want %v", err, errRollback)
+	}
+	if cards, _ := m.GetAllBundleCards(b.ID); len(cards) != 0 {
+		t.Fatalf("bundle has %d cards after the rollback, want 0", len(cards))
+	}
+
+	// a failed nested transaction does not roll back the writes before it
+	err = m.Transaction(func(s Store) error {
+		if _, err := s.InsertCard(model.Card{BundleID: b.ID, Question: "Committed", Answer: "A"}); err != nil {
+			return err
+		}
+		if err := s.Transaction(func(s Store) error {
+			if _, err := s.UpdateCard(b.ID, u.ID, map[string]interface{}{"answer": "B"}); err == nil {
+				t.Error("updating the bundle id as a card succeeded")
+			}
+			if _, err := s.InsertCard(model.Card{BundleID: b.ID, Question: "Nested", Answer: "A"}); err != nil {
+				return err
+			}
+			return errRollback
+		}); !errors.Is(err, errRollback) {
+			t.Errorf("nested transaction returned %v, want %v", err, errRollback)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cards, err := m.GetAllBundleCards(b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cards) != 1 || cards[0].Question != "Committed" {
+		t.Fatalf("bundle has the cards %+v, want the committed card only", cards)
+	}
+}
+
+// TestMemoryMatchesDatabase runs the same changes on both stores and compares the results which do not
+// depend on the generated ids.
+func TestMemoryMatchesDatabase(t *testing.T) {
+	type result struct {
+		deletion response.GroupDeletion
+		trash    []string // kinds and card counts of the trash
+		audit    int
+	}
+	run := func(s Store) result {
+		owner, b, cards := storeBundle(t, s, 3)
+		if _, err := s.UpdateCard(cards[0].ID, owner.ID, map[string]interface{}{"answer": "Revised"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.ReviewCard(owner.ID, cards[1].ID, 4, 1000); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.InsertInvite(model.Invite{GroupID: b.GroupID, CreatedBy: owner.ID}); err != nil {
+			t.Fatal(err)
+		}
+		other, err := s.InsertBundle(*generator.GetRandomBundle(b.GroupID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.InsertCard(*generator.GetRandomCard(other.ID)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteCard(cards[2].ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteBundle(other.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.InsertAuditEntry(model.AuditEntry{ActorID: owner.ID, Action: model.AuditBundleDelete, TargetType: model.TargetBundle, TargetID: other.ID}); err != nil {
+			t.Fatal(err)
+		}
+
+		var res result
+		items, err := s.GetGroupTrash(b.GroupID, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range items {
+			res.trash = append(res.trash, fmt.Sprintf("%s:%d", item.Kind, item.CardCount))
+		}
+		gd, err := s.DeleteGroupCascade(b.GroupID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.deletion = *gd
+		res.deletion.GroupID = ""
+		entries, err := s.GetAuditEntries(b.GroupID, AuditQuery{}, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.audit = len(entries)
+		if _, err := s.GetGroup(map[string]interface{}{"id": b.GroupID}); !errors.Is(err, ErrGroupNotFound) {
+			t.Errorf("getting the deleted group returned %v, want %v", err, ErrGroupNotFound)
+		}
+		return res
+	}
+
+	want := run(newSQLite(t, func(cfg *config.Database) {}))
+	got := run(NewMemory())
+	if got.deletion != want.deletion {
+		t.Errorf("memory deleted %+v, database deleted %+v", got.deletion, want.deletion)
+	}
+	if len(got.trash) != len(want.trash) {
+		t.Fatalf("memory trash %v, database trash %v", got.trash, want.trash)
+	}
+	for i := range want.trash {
+		if got.trash[i] != want.trash[i] {
+			t.Errorf("memory trash %v, database trash %v", got.trash, want.trash)
+			break
+		}
+	}
+	if got.audit != want.audit {
+		t.Errorf("memory kept %d audit entries, database kept %d", got.audit, want.audit)
+	}
+}
@@ -0,0 +1,222 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationFiles holds the numbered migrations of each dialect, <version>_<name>.up.sql and <version>_<name>.down.sql.
+// Migrations are never changed once they are released, a new migration is added instead.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationLock is the key of the advisory lock which keeps two instances from migrating at the same time.
+const migrationLock = 7201653394018
+
+// Migration is a versioned change of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus is a migration and the time it was applied, nil if it is pending.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// schemaMigration is a row of schema_migrations.
+type schemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrateUp applies the pending migrations in order and returns them. Each migration runs in its own transaction.
+func (db *Database) MigrateUp() ([]Migration, error) {
+	migrations, err := db.migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied := []Migration{}
+	for _, m := range migrations {
+		ran, err := db.runMigration(m, true)
+		if err != nil {
+			return applied, err
+		}
+		if ran {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// MigrateDown rolls back the last steps applied migrations and returns them.
+func (db *Database) MigrateDown(steps int) ([]Migration, error) {
+	migrations, err := db.migrations()
+	if err != nil {
+		return nil, err
+	}
+	rolledBack := []Migration{}
+	for i := len(migrations) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		ran, err := db.runMigration(migrations[i], false)
+		if err != nil {
+			return rolledBack, err
+		}
+		if ran {
+			rolledBack = append(rolledBack, migrations[i])
+		}
+	}
+	return rolledBack, nil
+}
+
+// MigrationStatus returns every migration with the time it was applied.
+func (db *Database) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := db.migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]time.Time)
+	if db.db.Migrator().HasTable(&schemaMigration{}) {
+		var rows []schemaMigration
+		if err := db.db.Find(&rows).Error; err != nil {
+			db.logError("MigrationStatus", err.Error())
+			return nil, ErrGormGet
+		}
+		for _, row := range rows {
+			applied[row.Version] = row.AppliedAt
+		}
+	}
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		ms := MigrationStatus{Version: m.Version, Name: m.Name}
+		if t, ok := applied[m.Version]; ok {
+			ms.AppliedAt = &t
+		}
+		status = append(status, ms)
+	}
+	return status, nil
+}
+
+// runMigration applies or rolls back the migration if it is not done yet and reports if it did.
+// The check and the change happen under the migration lock, so concurrent instances run every migration once.
+func (db *Database) runMigration(m Migration, up bool) (bool, error) {
+	ran := false
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		if err := db.lockMigrations(tx); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&schemaMigration{}).Where("version = ?", m.Version).Count(&count).Error; err != nil {
+			return err
+		}
+		if up == (count != 0) {
+			return nil
+		}
+		if up {
+			if err := tx.Exec(m.up).Error; err != nil {
+				return fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+			}
+			ran = true
+			return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		}
+		if len(m.down) == 0 {
+			return fmt.Errorf("migration %d %s cannot be rolled back", m.Version, m.Name)
+		}
+		if err := tx.Exec(m.down).Error; err != nil {
+			return fmt.Errorf("rollback %d %s: %w", m.Version, m.Name, err)
+		}
+		ran = true
+		return tx.Delete(&schemaMigration{Version: m.Version}).Error
+	})
+	if err != nil {
+		db.logError("runMigration", err.Error(), m.Version, up)
+		return false, err
+	}
+	return ran, nil
+}
+
+// lockMigrations takes the migration lock until the end of the transaction and creates schema_migrations.
+// SQLite databases are not shared by several instances, so they are not locked.
+func (db *Database) lockMigrations(tx *gorm.DB) error {
+	timeType := "datetime"
+	if tx.Dialector.Name() == "postgres" {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLock).Error; err != nil {
+			return err
+		}
+		timeType = "timestamptz"
+	}
+	return tx.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version bigint PRIMARY KEY, name text NOT NULL, applied_at " + timeType + " NOT NULL)").Error
+}
+
+// migrations returns the migrations of the dialect of the database, ordered by version.
+func (db *Database) migrations() ([]Migration, error) {
+	dir := path.Join("migrations", db.db.Dialector.Name())
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s", db.db.Dialector.Name())
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		name := e.Name()
+		var up bool
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			up = true
+		case strings.HasSuffix(name, ".down.sql"):
+		default:
+			continue
+		}
+		i := strings.Index(name, "_")
+		if i <= 0 {
+			return nil, fmt.Errorf("migration %s must start with its version", name)
+		}
+		version, err := strconv.Atoi(name[:i])
+		if err != nil {
+			return nil, fmt.Errorf("migration %s must start with its version", name)
+		}
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: strings.TrimSuffix(strings.TrimSuffix(name[i+1:], ".up.sql"), ".down.sql")}
+			byVersion[version] = m
+		}
+		if up {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if len(m.up) == 0 {
+			return nil, fmt.Errorf("migration %d has no up migration", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	if len(migrations) == 0 {
+		return nil, errors.New("no migrations found")
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
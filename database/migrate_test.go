@@ -0,0 +1,129 @@
+package database
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"github.com/ironstone95/FlashQudoV2/config"
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+// newSQLite connects to a new in-memory SQLite database with the startup actions of cfg.
+func newSQLite(t *testing.T, change func(cfg *config.Database)) *Database {
+	t.Helper()
+	cfg := config.Default().Database
+	cfg.Driver = config.DriverSQLite
+	cfg.File = ":memory:"
+	change(&cfg)
+	return ConnectDB(log.New(ioutil.Discard, "", 0), cfg, false)
+}
+
+// checkStatus checks the number of the applied migrations, they must be the first ones.
+func checkStatus(t *testing.T, db *Database, applied int) {
+	t.Helper()
+	status, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ms := range status {
+		if i > 0 && ms.Version <= status[i-1].Version {
+			t.Fatalf("migration %d is after %d", ms.Version, status[i-1].Version)
+		}
+		if got := ms.AppliedAt != nil; got != (i < applied) {
+			t.Fatalf("migration %d %s applied %t, want %t", ms.Version, ms.Name, got, i < applied)
+		}
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) { cfg.Migrate = false })
+	migrations, err := db.migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := len(migrations)
+	checkStatus(t, db, 0)
+
+	applied, err := db.MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != all {
+		t.Fatalf("%d migrations applied, want %d", len(applied), all)
+	}
+	checkStatus(t, db, all)
+	if applied, err = db.MigrateUp(); err != nil || len(applied) != 0 {
+		t.Fatalf("second run applied %d migrations, error %v", len(applied), err)
+	}
+
+	rolledBack, err := db.MigrateDown(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rolledBack) != 1 || rolledBack[0].Version != migrations[all-1].Version {
+		t.Fatalf("rolled back %v, want the last migration", rolledBack)
+	}
+	checkStatus(t, db, all-1)
+
+	if rolledBack, err = db.MigrateDown(all); err != nil {
+		t.Fatal(err)
+	}
+	if len(rolledBack) != all-1 {
+		t.Fatalf("%d migrations rolled back, want %d", len(rolledBack), all-1)
+	}
+	checkStatus(t, db, 0)
+	if db.db.Migrator().HasTable("users") {
+		t.Fatal("users table is left after rolling back every migration")
+	}
+
+	if applied, err = db.MigrateUp(); err != nil || len(applied) != all {
+		t.Fatalf("applied %d migrations again, error %v", len(applied), err)
+	}
+}
+
+func TestMemberForeignKeys(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) {})
+	user, err := db.InsertUser(*generator.GetRandomUser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, err := db.InsertGroup(*generator.GetRandomGroup(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.db.Create(&model.Member{GroupID: group.ID, UserID: "nobody", Role: model.RoleViewer}).Error; err == nil {
+		t.Fatal("member of an unknown user is inserted")
+	}
+	if err := db.db.Create(&model.Member{GroupID: "nothing", UserID: user.ID, Role: model.RoleViewer}).Error; err == nil {
+		t.Fatal("member of an unknown group is inserted")
+	}
+
+	if err := db.InsertAuditEntry(model.AuditEntry{GroupID: group.ID, ActorID: user.ID, Action: model.AuditGroupRename}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.db.Delete(&model.User{ID: user.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n := db.memberCount(group.ID); n != 0 {
+		t.Fatalf("%d members after the user is deleted, want 0", n)
+	}
+	if _, err := db.DeleteGroupCascade(group.ID); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := db.GetAuditEntries(group.ID, AuditQuery{}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("%d audit entries after the group is deleted, want 1", len(entries))
+	}
+}
+
+func TestSeed(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) { cfg.Reset, cfg.Seed = true, true })
+	if n := db.memberCount("group1"); n != 2 {
+		t.Fatalf("%d members of the seeded group, want 2", n)
+	}
+}
@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS "access_tokens";
+DROP TABLE IF EXISTS "audit_entries";
+DROP TABLE IF EXISTS "card_revisions";
+DROP TABLE IF EXISTS "join_requests";
+DROP TABLE IF EXISTS "invites";
+DROP TABLE IF EXISTS "review_logs";
+DROP TABLE IF EXISTS "scheduler_params";
+DROP TABLE IF EXISTS "review_states";
+DROP TABLE IF EXISTS "tokens";
+DROP TABLE IF EXISTS "cards";
+DROP TABLE IF EXISTS "bundles";
+DROP TABLE IF EXISTS "members";
+DROP TABLE IF EXISTS "groups";
+DROP TABLE IF EXISTS "users";
@@ -0,0 +1,83 @@
+-- The schema which was created by AutoMigrate before the versioned migrations. Every statement can run on a
+-- database created by AutoMigrate, so that such databases are taken over without changes, including the ones
+-- which still have the admin flags of the members and the raw tokens.
+
+CREATE TABLE IF NOT EXISTS "users" ("id" text,"username" text NOT NULL,"created_at" timestamptz,"updated_at" timestamptz,"image_url" text,"scheduler" text NOT NULL DEFAULT 'sm2',"desired_retention" decimal NOT NULL DEFAULT 0.900000,"tokens_valid_after" bigint NOT NULL DEFAULT 0,PRIMARY KEY ("id"));
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "scheduler" text NOT NULL DEFAULT 'sm2';
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "desired_retention" decimal NOT NULL DEFAULT 0.900000;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "tokens_valid_after" bigint NOT NULL DEFAULT 0;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_username" ON "users" ("username");
+
+CREATE TABLE IF NOT EXISTS "groups" ("id" text,"name" text,"visibility" text NOT NULL DEFAULT 'private',"created_at" timestamptz,PRIMARY KEY ("id"));
+ALTER TABLE "groups" ADD COLUMN IF NOT EXISTS "visibility" text NOT NULL DEFAULT 'private';
+CREATE INDEX IF NOT EXISTS "idx_groups_visibility" ON "groups" ("visibility");
+
+CREATE TABLE IF NOT EXISTS "members" ("group_id" text,"user_id" text,"member_since" timestamptz,"role" text NOT NULL DEFAULT 'viewer',PRIMARY KEY ("group_id","user_id"));
+ALTER TABLE "members" ADD COLUMN IF NOT EXISTS "role" text NOT NULL DEFAULT 'viewer';
+
+CREATE TABLE IF NOT EXISTS "bundles" ("id" text,"title" text,"description" text,"group_id" text,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_groups_bundles" FOREIGN KEY ("group_id") REFERENCES "groups"("id"));
+ALTER TABLE "bundles" ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_bundles_deleted_at" ON "bundles" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "cards" ("id" text,"bundle_id" text NOT NULL,"question" text NOT NULL,"answer" text,"updated_at" timestamptz,"created_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_bundles_cards" FOREIGN KEY ("bundle_id") REFERENCES "bundles"("id"));
+ALTER TABLE "cards" ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz;
+-- questions are unique among the cards which are not deleted
+DROP INDEX IF EXISTS "ux_card_question";
+CREATE UNIQUE INDEX IF NOT EXISTS "ux_card_active_question" ON "cards" ("bundle_id","question") WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS "idx_cards_deleted_at" ON "cards" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "ix_card_search" ON "cards" USING GIN (to_tsvector('simple', coalesce(question, '') || ' ' || coalesce(answer, '')));
+
+-- tokens used to be stored raw, the table is only a cache so it is created again
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'tokens' AND column_name = 'token') THEN
+		DROP TABLE "tokens";
+	END IF;
+END $$;
+CREATE TABLE IF NOT EXISTS "tokens" ("hash" text,"user_id" text NOT NULL,"expires" bigint,"checked_at" bigint,"revoked_at" timestamptz,PRIMARY KEY ("hash"));
+CREATE INDEX IF NOT EXISTS "idx_tokens_user_id" ON "tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "review_states" ("user_id" text,"card_id" text,"ease_factor" decimal NOT NULL,"interval" bigint,"repetitions" bigint,"lapses" bigint,"stability" decimal,"difficulty" decimal,"due_at" timestamptz,"last_reviewed_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("user_id","card_id"),CONSTRAINT "fk_review_states_card" FOREIGN KEY ("card_id") REFERENCES "cards"("id") ON DELETE CASCADE);
+CREATE INDEX IF NOT EXISTS "idx_review_states_due_at" ON "review_states" ("due_at");
+
+CREATE TABLE IF NOT EXISTS "scheduler_params" ("user_id" text,"stability_scale" decimal NOT NULL DEFAULT 1.000000,"reviews" bigint,"recalls" bigint,"predicted_recall" decimal,"tuned_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("user_id"));
+
+CREATE TABLE IF NOT EXISTS "review_logs" ("id" text,"user_id" text NOT NULL,"card_id" text NOT NULL,"grade" bigint,"response_time_ms" bigint,"interval_before" bigint,"interval_after" bigint,"created_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_review_logs_card" FOREIGN KEY ("card_id") REFERENCES "cards"("id") ON DELETE CASCADE);
+CREATE INDEX IF NOT EXISTS "ix_review_log_user_card" ON "review_logs" ("user_id","card_id");
+
+CREATE TABLE IF NOT EXISTS "invites" ("id" text,"group_id" text NOT NULL,"code" text NOT NULL,"created_by" text,"role" text NOT NULL DEFAULT 'viewer',"max_uses" bigint,"uses" bigint,"expires_at" timestamptz,"revoked_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_invites_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id") ON DELETE CASCADE);
+ALTER TABLE "invites" ADD COLUMN IF NOT EXISTS "role" text NOT NULL DEFAULT 'viewer';
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_invites_code" ON "invites" ("code");
+CREATE INDEX IF NOT EXISTS "idx_invites_group_id" ON "invites" ("group_id");
+
+-- admins keep their role, the earliest admin of each group becomes the owner
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'members' AND column_name = 'is_admin') THEN
+		UPDATE "members" SET "role" = 'admin' WHERE "is_admin";
+		UPDATE "members" SET "role" = 'owner' FROM (
+			SELECT DISTINCT ON ("group_id") "group_id", "user_id" FROM "members" WHERE "role" = 'admin' ORDER BY "group_id", "member_since"
+		) AS "first" WHERE "members"."group_id" = "first"."group_id" AND "members"."user_id" = "first"."user_id";
+		ALTER TABLE "members" DROP COLUMN "is_admin";
+	END IF;
+	IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'invites' AND column_name = 'is_admin') THEN
+		UPDATE "invites" SET "role" = 'admin' WHERE "is_admin";
+		ALTER TABLE "invites" DROP COLUMN "is_admin";
+	END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS "join_requests" ("id" text,"group_id" text NOT NULL,"user_id" text NOT NULL,"status" text NOT NULL,"decided_by" text,"decided_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_join_requests_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id") ON DELETE CASCADE);
+CREATE INDEX IF NOT EXISTS "idx_join_requests_user_id" ON "join_requests" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_join_requests_group_id" ON "join_requests" ("group_id");
+
+CREATE TABLE IF NOT EXISTS "card_revisions" ("id" text,"card_id" text NOT NULL,"editor_id" text,"old_question" text,"new_question" text,"old_answer" text,"new_answer" text,"revert_of" text,"created_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_card_revisions_card" FOREIGN KEY ("card_id") REFERENCES "cards"("id") ON DELETE CASCADE);
+CREATE INDEX IF NOT EXISTS "idx_card_revisions_card_id" ON "card_revisions" ("card_id");
+
+CREATE TABLE IF NOT EXISTS "audit_entries" ("id" text,"group_id" text NOT NULL,"actor_id" text NOT NULL,"action" text NOT NULL,"target_type" text,"target_id" text,"before" text,"after" text,"created_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_audit_entries_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id") ON DELETE CASCADE);
+CREATE INDEX IF NOT EXISTS "idx_audit_entries_action" ON "audit_entries" ("action");
+CREATE INDEX IF NOT EXISTS "idx_audit_entries_actor_id" ON "audit_entries" ("actor_id");
+CREATE INDEX IF NOT EXISTS "ix_audit_group_time" ON "audit_entries" ("group_id","created_at");
+
+CREATE TABLE IF NOT EXISTS "access_tokens" ("id" text,"user_id" text NOT NULL,"name" text NOT NULL,"hash" text NOT NULL,"prefix" text,"scopes" text NOT NULL,"expires_at" timestamptz,"last_used_at" timestamptz,"revoked_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_access_tokens_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_access_tokens_hash" ON "access_tokens" ("hash");
+CREATE INDEX IF NOT EXISTS "idx_access_tokens_user_id" ON "access_tokens" ("user_id");
@@ -0,0 +1,2 @@
+DELETE FROM "audit_entries" WHERE "group_id" NOT IN (SELECT "id" FROM "groups");
+ALTER TABLE "audit_entries" ADD CONSTRAINT "fk_audit_entries_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id") ON DELETE CASCADE;
@@ -0,0 +1,2 @@
+-- The audit log of a group is kept when the group is deleted, so that the deletion can still be answered for.
+ALTER TABLE "audit_entries" DROP CONSTRAINT IF EXISTS "fk_audit_entries_group";
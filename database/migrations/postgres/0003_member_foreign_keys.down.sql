@@ -0,0 +1,2 @@
+ALTER TABLE "members" DROP CONSTRAINT IF EXISTS "fk_members_user";
+ALTER TABLE "members" DROP CONSTRAINT IF EXISTS "fk_members_group";
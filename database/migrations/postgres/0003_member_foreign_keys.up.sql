@@ -0,0 +1,5 @@
+-- The memberships of a deleted user or group are deleted with it. Memberships which already lost their user or
+-- group are removed first, so that the constraints can be added.
+DELETE FROM "members" WHERE "user_id" NOT IN (SELECT "id" FROM "users") OR "group_id" NOT IN (SELECT "id" FROM "groups");
+ALTER TABLE "members" ADD CONSTRAINT "fk_members_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id") ON DELETE CASCADE;
+ALTER TABLE "members" ADD CONSTRAINT "fk_members_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE;
@@ -0,0 +1,49 @@
+-- The schema of the postgres baseline for SQLite. The times are datetime columns, so that the driver reads
+-- them as times, and there is no full text search index, the cards are searched with LIKE.
+
+CREATE TABLE IF NOT EXISTS "users" ("id" text,"username" text NOT NULL,"created_at" datetime,"updated_at" datetime,"image_url" text,"scheduler" text NOT NULL DEFAULT 'sm2',"desired_retention" real NOT NULL DEFAULT 0.900000,"tokens_valid_after" integer NOT NULL DEFAULT 0,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_username" ON "users" ("username");
+
+CREATE TABLE IF NOT EXISTS "groups" ("id" text,"name" text,"visibility" text NOT NULL DEFAULT 'private',"created_at" datetime,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_groups_visibility" ON "groups" ("visibility");
+
+CREATE TABLE IF NOT EXISTS "members" ("group_id" text,"user_id" text,"member_since" datetime,"role" text NOT NULL DEFAULT 'viewer',PRIMARY KEY ("group_id","user_id"));
+
+CREATE TABLE IF NOT EXISTS "bundles" ("id" text,"title" text,"description" text,"group_id" text,"created_at" datetime,"updated_at" datetime,"deleted_at" datetime,PRIMARY KEY ("id"),CONSTRAINT "fk_groups_bundles" FOREIGN KEY ("group_id") REFERENCES "groups"("id"));
+CREATE INDEX IF NOT EXISTS "idx_bundles_deleted_at" ON "bundles" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "cards" ("id" text,"bundle_id" text NOT NULL,"question" text NOT NULL,"answer" text,"updated_at" datetime,"created_at" datetime,"deleted_at" datetime,PRIMARY KEY ("id"),CONSTRAINT "fk_bundles_cards" FOREIGN KEY ("bundle_id") REFERENCES "bundles"("id"));
+CREATE INDEX IF NOT EXISTS "idx_cards_deleted_at" ON "cards" ("deleted_at");
+-- questions are unique among the cards which are not deleted
+CREATE UNIQUE INDEX IF NOT EXISTS "ux_card_active_question" ON "cards" ("bundle_id","question") WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS "tokens" ("hash" text,"user_id" text NOT NULL,"expires" integer,"checked_at" integer,"revoked_at" datetime,PRIMARY KEY ("hash"));
+CREATE INDEX IF NOT EXISTS "idx_tokens_user_id" ON "tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "review_states" ("user_id" text,"card_id" text,"ease_factor" real NOT NULL,"interval" integer,"repetitions" integer,"lapses" integer,"stability" real,"difficulty" real,"due_at" datetime,"last_reviewed_at" datetime,"updated_at" datetime,PRIMARY KEY ("user_id","card_id"),CONSTRAINT "fk_review_states_card" FOREIGN KEY ("card_id") REFERENCES "cards"("id") ON DELETE CASCADE);
+CREATE INDEX IF NOT EXISTS "idx_review_states_due_at" ON "review_states" ("due_at");
+
+CREATE TABLE IF NOT EXISTS "scheduler_params" ("user_id" text,"stability_scale" real NOT NULL DEFAULT 1.000000,"reviews" integer,"recalls" integer,"predicted_recall" real,"tuned_at" datetime,"updated_at" datetime,PRIMARY KEY ("user_id"));
+
+CREATE TABLE IF NOT EXISTS "review_logs" ("id" text,"user_id" text NOT NULL,"card_id" text NOT NULL,"grade" integer,"response_time_ms" integer,"interval_before" integer,"interval_after" integer,"created_at" datetime,PRIMARY KEY ("id"),CONSTRAINT "fk_review_logs_card" FOREIGN KEY ("card_id") REFERENCES "cards"("id") ON DELETE CASCADE);
+CREATE INDEX IF NOT EXISTS "ix_review_log_user_card" ON "review_logs" ("user_id","card_id");
+
+CREATE TABLE IF NOT EXISTS "invites" ("id" text,"group_id" text NOT NULL,"code" text NOT NULL,"created_by" text,"role" text NOT NULL DEFAULT 'viewer',"max_uses" integer,"uses" integer,"expires_at" datetime,"revoked_at" datetime,"created_at" datetime,PRIMARY KEY ("id"),CONSTRAINT "fk_invites_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id") ON DELETE CASCADE);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_invites_code" ON "invites" ("code");
+CREATE INDEX IF NOT EXISTS "idx_invites_group_id" ON "invites" ("group_id");
+
+CREATE TABLE IF NOT EXISTS "join_requests" ("id" text,"group_id" text NOT NULL,"user_id" text NOT NULL,"status" text NOT NULL,"decided_by" text,"decided_at" datetime,"created_at" datetime,PRIMARY KEY ("id"),CONSTRAINT "fk_join_requests_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id") ON DELETE CASCADE);
+CREATE INDEX IF NOT EXISTS "idx_join_requests_user_id" ON "join_requests" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_join_requests_group_id" ON "join_requests" ("group_id");
+
+CREATE TABLE IF NOT EXISTS "card_revisions" ("id" text,"card_id" text NOT NULL,"editor_id" text,"old_question" text,"new_question" text,"old_answer" text,"new_answer" text,"revert_of" text,"created_at" datetime,PRIMARY KEY ("id"),CONSTRAINT "fk_card_revisions_card" FOREIGN KEY ("card_id") REFERENCES "cards"("id") ON DELETE CASCADE);
+CREATE INDEX IF NOT EXISTS "idx_card_revisions_card_id" ON "card_revisions" ("card_id");
+
+CREATE TABLE IF NOT EXISTS "audit_entries" ("id" text,"group_id" text NOT NULL,"actor_id" text NOT NULL,"action" text NOT NULL,"target_type" text,"target_id" text,"before" text,"after" text,"created_at" datetime,PRIMARY KEY ("id"),CONSTRAINT "fk_audit_entries_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id") ON DELETE CASCADE);
+CREATE INDEX IF NOT EXISTS "idx_audit_entries_action" ON "audit_entries" ("action");
+CREATE INDEX IF NOT EXISTS "idx_audit_entries_actor_id" ON "audit_entries" ("actor_id");
+CREATE INDEX IF NOT EXISTS "ix_audit_group_time" ON "audit_entries" ("group_id","created_at");
+
+CREATE TABLE IF NOT EXISTS "access_tokens" ("id" text,"user_id" text NOT NULL,"name" text NOT NULL,"hash" text NOT NULL,"prefix" text,"scopes" text NOT NULL,"expires_at" datetime,"last_used_at" datetime,"revoked_at" datetime,"created_at" datetime,PRIMARY KEY ("id"),CONSTRAINT "fk_access_tokens_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_access_tokens_hash" ON "access_tokens" ("hash");
+CREATE INDEX IF NOT EXISTS "idx_access_tokens_user_id" ON "access_tokens" ("user_id");
@@ -0,0 +1,9 @@
+-- The audit log of a group is kept when the group is deleted, so that the deletion can still be answered for.
+-- SQLite cannot drop a constraint, the table is created again without it.
+CREATE TABLE "audit_entries_new" ("id" text,"group_id" text NOT NULL,"actor_id" text NOT NULL,"action" text NOT NULL,"target_type" text,"target_id" text,"before" text,"after" text,"created_at" datetime,PRIMARY KEY ("id"));
+INSERT INTO "audit_entries_new" SELECT "id","group_id","actor_id","action","target_type","target_id","before","after","created_at" FROM "audit_entries";
+DROP TABLE "audit_entries";
+ALTER TABLE "audit_entries_new" RENAME TO "audit_entries";
+CREATE INDEX "idx_audit_entries_action" ON "audit_entries" ("action");
+CREATE INDEX "idx_audit_entries_actor_id" ON "audit_entries" ("actor_id");
+CREATE INDEX "ix_audit_group_time" ON "audit_entries" ("group_id","created_at");
@@ -0,0 +1,4 @@
+CREATE TABLE "members_new" ("group_id" text,"user_id" text,"member_since" datetime,"role" text NOT NULL DEFAULT 'viewer',PRIMARY KEY ("group_id","user_id"));
+INSERT INTO "members_new" SELECT "group_id","user_id","member_since","role" FROM "members";
+DROP TABLE "members";
+ALTER TABLE "members_new" RENAME TO "members";
@@ -0,0 +1,6 @@
+-- The memberships of a deleted user or group are deleted with it. Memberships which already lost their user or
+-- group are not copied. SQLite cannot add a constraint, the table is created again with them.
+CREATE TABLE "members_new" ("group_id" text,"user_id" text,"member_since" datetime,"role" text NOT NULL DEFAULT 'viewer',PRIMARY KEY ("group_id","user_id"),CONSTRAINT "fk_members_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id") ON DELETE CASCADE,CONSTRAINT "fk_members_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE);
+INSERT INTO "members_new" SELECT "group_id","user_id","member_since","role" FROM "members" WHERE "user_id" IN (SELECT "id" FROM "users") AND "group_id" IN (SELECT "id" FROM "groups");
+DROP TABLE "members";
+ALTER TABLE "members_new" RENAME TO "members";
@@ -0,0 +1,238 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+	"gorm.io/gorm"
+)
+
+// DueQuery holds the options of a study queue request.
+// GroupID and BundleID are optional filters. If Interleave is true, cards of different bundles alternate.
+type DueQuery struct {
+	GroupID     string
+	BundleID    string
+	NewLimit    int
+	ReviewLimit int
+	Interleave  bool
+}
+
+const (
+	minGrade          = 0
+	maxGrade          = 5
+	defaultEaseFactor = 2.5
+	minEaseFactor     = 1.3
+)
+
+// ReviewCard grades the card with id cardID for the user and reschedules it with the scheduler selected by the user.
+// If the user never reviewed the card before, a new review state is created. Every review is added to the review log.
+func (db *Database) ReviewCard(userID, cardID string, grade int, responseTimeMs int64) (*model.ReviewState, error) {
+	if len(userID) == 0 || len(cardID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	if grade < minGrade || grade > maxGrade {
+		db.logError("ReviewCard", ErrInvalidGrade.Error(), userID, cardID, grade)
+		return nil, ErrInvalidGrade
+	}
+	rs := model.ReviewState{}
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		u := model.User{}
+		if err := tx.Where("id = ?", userID).First(&u).Error; err != nil {
+			db.logError("ReviewCard", err.Error(), userID, cardID, grade)
+			return ErrUserNotFound
+		}
+		if err := tx.Where("user_id = ? and card_id = ?", userID, cardID).First(&rs).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				db.logError("ReviewCard", err.Error(), userID, cardID, grade)
+				return ErrGormGet
+			}
+			rs = model.ReviewState{UserID: userID, CardID: cardID, EaseFactor: defaultEaseFactor}
+		}
+
+		params := model.SchedulerParams{UserID: userID, StabilityScale: 1}
+		if u.Scheduler == model.SchedulerFSRS {
+			if err := tx.Where("user_id = ?", userID).FirstOrInit(&params).Error; err != nil {
+				db.logError("ReviewCard", err.Error(), userID, cardID, grade)
+				return ErrGormGet
+			}
+		}
+
+		rl := model.ReviewLog{
+			ID:             generator.CreateID(),
+			UserID:         userID,
+			CardID:         cardID,
+			Grade:          grade,
+			ResponseTimeMs: responseTimeMs,
+			IntervalBefore: rs.Interval,
+		}
+		newScheduler(&u, &params).Schedule(&rs, grade, time.Now())
+		if err := tx.Save(&rs).Error; err != nil {
+			db.logError("ReviewCard", err.Error(), userID, cardID, grade)
+			return ErrGormSave
+		}
+		rl.IntervalAfter = rs.Interval
+		if err := tx.Create(&rl).Error; err != nil {
+			db.logError("ReviewCard", err.Error(), userID, cardID, grade)
+			return ErrGormCreate
+		}
+		if u.Scheduler == model.SchedulerFSRS {
+			if err := tx.Save(&params).Error; err != nil {
+				db.logError("ReviewCard", err.Error(), userID, cardID, grade)
+				return ErrGormSave
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// GetDueCards returns the cards of every group of the user whose review is due, followed by the cards never reviewed.
+func (db *Database) GetDueCards(userID string, q DueQuery) (*response.StudyQueue, error) {
+	if len(userID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	now := time.Now()
+	var reviews []response.DueCard
+	if q.ReviewLimit > 0 {
+		tx := db.studyCards(userID, q).
+			Select("cards.id, cards.bundle_id, bundles.group_id, cards.question, cards.answer, review_states.due_at, review_states.interval, review_states.repetitions").
+			Joins("join review_states on review_states.card_id = cards.id and review_states.user_id = members.user_id").
+			Where("review_states.due_at <= ?", now).
+			Order("review_states.due_at").Limit(q.ReviewLimit)
+		if err := tx.Scan(&reviews).Error; err != nil {
+			db.logError("GetDueCards", err.Error(), userID, q)
+			return nil, ErrGormGet
+		}
+	}
+
+	var newCards []response.DueCard
+	if q.NewLimit > 0 {
+		tx := db.studyCards(userID, q).
+			Select("cards.id, cards.bundle_id, bundles.group_id, cards.question, cards.answer").
+			Joins("left join review_states on review_states.card_id = cards.id and review_states.user_id = members.user_id").
+			Where("review_states.card_id is null").
+			Order("cards.created_at, cards.id").Limit(q.NewLimit)
+		if err := tx.Scan(&newCards).Error; err != nil {
+			db.logError("GetDueCards", err.Error(), userID, q)
+			return nil, ErrGormGet
+		}
+		for i := range newCards {
+			newCards[i].IsNew = true
+		}
+	}
+
+	cards := append(reviews, newCards...)
+	if q.Interleave {
+		cards = interleaveByBundle(cards)
+	}
+	if cards == nil {
+		cards = []response.DueCard{}
+	}
+	return &response.StudyQueue{NewCount: len(newCards), ReviewCount: len(reviews), Cards: cards}, nil
+}
+
+// GetCardStats returns the statistics of the review log of the card for the user.
+// Lapses and retention only count the reviews of cards which were reviewed before.
+func (db *Database) GetCardStats(userID, cardID string) (*response.CardStats, error) {
+	if len(userID) == 0 || len(cardID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	var agg struct {
+		Reviews      int
+		Lapses       int
+		Recalls      int
+		Matured      int
+		AverageGrade float64
+		TimeSpentMs  int64
+	}
+	if err := db.db.Model(&model.ReviewLog{}).
+		Select("count(*) as reviews, "+
+			"coalesce(sum(case when interval_before > 0 and grade < 3 then 1 else 0 end), 0) as lapses, "+
+			"coalesce(sum(case when interval_before > 0 and grade >= 3 then 1 else 0 end), 0) as recalls, "+
+			"coalesce(sum(case when interval_before > 0 then 1 else 0 end), 0) as matured, "+
+			"coalesce(avg(grade), 0) as average_grade, "+
+			"coalesce(sum(response_time_ms), 0) as time_spent_ms").
+		Where("user_id = ? and card_id = ?", userID, cardID).
+		Scan(&agg).Error; err != nil {
+		db.logError("GetCardStats", err.Error(), userID, cardID)
+		return nil, ErrGormGet
+	}
+
+	stats := response.CardStats{
+		CardID:       cardID,
+		Reviews:      agg.Reviews,
+		Lapses:       agg.Lapses,
+		AverageGrade: agg.AverageGrade,
+		TimeSpentMs:  agg.TimeSpentMs,
+	}
+	if agg.Matured > 0 {
+		stats.Retention = float64(agg.Recalls) / float64(agg.Matured)
+	}
+
+	var states []model.ReviewState
+	if err := db.db.Where("user_id = ? and card_id = ?", userID, cardID).Limit(1).Find(&states).Error; err != nil {
+		db.logError("GetCardStats", err.Error(), userID, cardID)
+		return nil, ErrGormGet
+	}
+	if len(states) == 1 {
+		stats.Interval = states[0].Interval
+		stats.LastReviewedAt = &states[0].LastReviewedAt
+		stats.DueAt = &states[0].DueAt
+	}
+	return &stats, nil
+}
+
+// studyCards returns the base query of the cards in the groups of the user, filtered by the group and bundle of q.
+func (db *Database) studyCards(userID string, q DueQuery) *gorm.DB {
+	tx := db.db.Table("cards").
+		Joins("join bundles on bundles.id = cards.bundle_id").
+		Joins("join members on members.group_id = bundles.group_id").
+		Where("members.user_id = ? and cards.deleted_at is null and bundles.deleted_at is null", userID)
+	if len(q.GroupID) != 0 {
+		tx = tx.Where("bundles.group_id = ?", q.GroupID)
+	}
+	if len(q.BundleID) != 0 {
+		tx = tx.Where("bundles.id = ?", q.BundleID)
+	}
+	return tx
+}
+
+// interleaveByBundle reorders the cards so that no two consecutive cards are of the same bundle unless only one bundle is left,
+// keeping the order of the cards inside a bundle. The next card is of the bundle with the most cards left,
+// except the bundle of the previous card, ties go to the bundle which comes first.
+func interleaveByBundle(cards []response.DueCard) []response.DueCard {
+	var order []string
+	byBundle := make(map[string][]response.DueCard)
+	for _, c := range cards {
+		if _, ok := byBundle[c.BundleID]; !ok {
+			order = append(order, c.BundleID)
+		}
+		byBundle[c.BundleID] = append(byBundle[c.BundleID], c)
+	}
+	res := make([]response.DueCard, 0, len(cards))
+	prev := ""
+	for len(res) < len(cards) {
+		next := ""
+		for _, bundleID := range order {
+			if bundleID == prev || len(byBundle[bundleID]) == 0 {
+				continue
+			}
+			if next == "" || len(byBundle[bundleID]) > len(byBundle[next]) {
+				next = bundleID
+			}
+		}
+		if next == "" {
+			next = prev
+		}
+		res = append(res, byBundle[next][0])
+		byBundle[next] = byBundle[next][1:]
+		prev = next
+	}
+	return res
+}
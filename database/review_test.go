@@ -0,0 +1,261 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/config"
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+// newBundle inserts a user, a group of the user and a bundle of the group with n cards.
+func newBundle(t *testing.T, db *Database, n int) (*model.User, *model.Bundle, []*model.Card) {
+	t.Helper()
+	user, err := db.InsertUser(*generator.GetRandomUser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, err := db.InsertGroup(*generator.GetRandomGroup(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, cards := addBundle(t, db, group.ID, n)
+	return user, bundle, cards
+}
+
+// addBundle inserts a bundle of the group with n cards.
+func addBundle(t *testing.T, db *Database, groupID string, n int) (*model.Bundle, []*model.Card) {
+	t.Helper()
+	bundle, err := db.InsertBundle(*generator.GetRandomBundle(groupID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cards := make([]*model.Card, n)
+	for i := range cards {
+		if cards[i], err = db.InsertCard(*generator.GetRandomCard(bundle.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return bundle, cards
+}
+
+// review grades the card for the user and fails the test on an error.
+func review(t *testing.T, db *Database, userID, cardID string, grade int) *model.ReviewState {
+	t.Helper()
+	rs, err := db.ReviewCard(userID, cardID, grade, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs
+}
+
+func updateUser(t *testing.T, db *Database, userID string, updates map[string]interface{}) {
+	t.Helper()
+	if _, err := db.UpdateUser(userID, updates); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDesiredRetention(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) {})
+	user, bundle, cards := newBundle(t, db, 3)
+	other, err := db.InsertUser(*generator.GetRandomUser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.InsertMember(model.Member{GroupID: bundle.GroupID, UserID: other.ID, Role: model.RoleViewer}); err != nil {
+		t.Fatal(err)
+	}
+	updateUser(t, db, user.ID, map[string]interface{}{"scheduler": model.SchedulerFSRS})
+	updateUser(t, db, other.ID, map[string]interface{}{"scheduler": model.SchedulerFSRS, "desired_retention": 0.95})
+
+	// the first review with grade 4 has the stability 3.7145 days
+	tests := []struct {
+		name      string
+		userID    string
+		retention float64 // changed before the review if not zero
+		cardID    string
+		want      int
+	}{
+		{name: "default retention", userID: user.ID, cardID: cards[0].ID, want: 4},
+		{name: "lower retention of the user", userID: user.ID, retention: 0.8, cardID: cards[1].ID, want: 9},
+		{name: "higher retention of the other user", userID: other.ID, cardID: cards[0].ID, want: 2},
+		{name: "higher retention of the user", userID: user.ID, retention: 0.95, cardID: cards[2].ID, want: 2},
+	}
+	for _, tt := range tests {
+		if tt.retention != 0 {
+			updateUser(t, db, tt.userID, map[string]interface{}{"desired_retention": tt.retention})
+		}
+		if rs := review(t, db, tt.userID, tt.cardID, 4); rs.Interval != tt.want {
+			t.Errorf("%s: interval %d, want %d", tt.name, rs.Interval, tt.want)
+		}
+	}
+}
+
+func TestSwitchScheduler(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) {})
+	user, _, cards := newBundle(t, db, 1)
+	cardID := cards[0].ID
+
+	review(t, db, user.ID, cardID, 4)
+	rs := review(t, db, user.ID, cardID, 4)
+	if rs.Repetitions != 2 || rs.Interval != 6 || rs.Stability != 0 {
+		t.Fatalf("SM-2 state %+v, want 2 repetitions, 6 days and no stability", *rs)
+	}
+
+	// FSRS starts from the SM-2 interval and keeps the repetitions and the ease factor
+	updateUser(t, db, user.ID, map[string]interface{}{"scheduler": model.SchedulerFSRS})
+	rs = review(t, db, user.ID, cardID, 4)
+	if rs.Repetitions != 3 || rs.Interval != 6 || rs.EaseFactor != defaultEaseFactor || rs.Lapses != 0 {
+		t.Fatalf("FSRS state %+v, want 3 repetitions, 6 days and the ease factor %v", *rs, defaultEaseFactor)
+	}
+	if math.Abs(rs.Stability-6) > 1e-6 || math.Abs(rs.Difficulty-fsrsInitDifficulty(3)) > 1e-6 {
+		t.Fatalf("FSRS stability %v and difficulty %v, want 6 and %v", rs.Stability, rs.Difficulty, fsrsInitDifficulty(3))
+	}
+
+	// SM-2 continues from the interval and the ease factor
+	updateUser(t, db, user.ID, map[string]interface{}{"scheduler": model.SchedulerSM2})
+	rs = review(t, db, user.ID, cardID, 4)
+	if rs.Repetitions != 4 || rs.Interval != 15 || rs.EaseFactor != defaultEaseFactor || rs.Stability != 0 || rs.Difficulty != 0 {
+		t.Fatalf("SM-2 state %+v, want 4 repetitions, 15 days and no FSRS state", *rs)
+	}
+	rs = review(t, db, user.ID, cardID, 1)
+	if rs.Repetitions != 0 || rs.Interval != 1 || rs.Lapses != 1 {
+		t.Fatalf("SM-2 state after the lapse %+v, want no repetitions, 1 day and 1 lapse", *rs)
+	}
+
+	// FSRS starts again from the SM-2 interval after the lapse, not from its stale stability
+	updateUser(t, db, user.ID, map[string]interface{}{"scheduler": model.SchedulerFSRS})
+	rs = review(t, db, user.ID, cardID, 4)
+	if rs.Repetitions != 1 || rs.Lapses != 1 || math.Abs(rs.Stability-1) > 1e-6 {
+		t.Fatalf("FSRS state after the lapse %+v, want 1 repetition, 1 lapse and the stability 1", *rs)
+	}
+}
+
+func TestGetDueCards(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) {})
+	user, a, aCards := newBundle(t, db, 4)
+	b, bCards := addBundle(t, db, a.GroupID, 2)
+	addBundle(t, db, a.GroupID, 1)
+	for _, card := range []*model.Card{aCards[0], aCards[1], bCards[0]} {
+		review(t, db, user.ID, card.ID, 4)
+	}
+	if err := db.db.Model(&model.ReviewState{}).Where("user_id = ?", user.ID).
+		Update("due_at", time.Now().Add(-time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name              string
+		q                 DueQuery
+		reviews, newCards int
+	}{
+		{name: "both limited", q: DueQuery{NewLimit: 3, ReviewLimit: 2}, reviews: 2, newCards: 3},
+		{name: "no reviews", q: DueQuery{NewLimit: 10}, newCards: 4},
+		{name: "no new cards", q: DueQuery{ReviewLimit: 10}, reviews: 3},
+		{name: "bundle", q: DueQuery{BundleID: b.ID, NewLimit: 10, ReviewLimit: 10}, reviews: 1, newCards: 1},
+		{name: "all interleaved", q: DueQuery{NewLimit: 10, ReviewLimit: 10, Interleave: true}, reviews: 3, newCards: 4},
+	}
+	for _, tt := range tests {
+		queue, err := db.GetDueCards(user.ID, tt.q)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if queue.ReviewCount != tt.reviews || queue.NewCount != tt.newCards || len(queue.Cards) != tt.reviews+tt.newCards {
+			t.Errorf("%s: %d reviews, %d new cards and %d cards, want %d and %d", tt.name,
+				queue.ReviewCount, queue.NewCount, len(queue.Cards), tt.reviews, tt.newCards)
+			continue
+		}
+		seen := make(map[string]bool)
+		for i, c := range queue.Cards {
+			if seen[c.ID] {
+				t.Errorf("%s: card %s twice", tt.name, c.ID)
+			}
+			seen[c.ID] = true
+			if !tt.q.Interleave && c.IsNew != (i >= tt.reviews) {
+				t.Errorf("%s: card %d is new %v, want the reviews first", tt.name, i, c.IsNew)
+			}
+			if tt.q.Interleave && i > 0 && queue.Cards[i-1].BundleID == c.BundleID {
+				t.Errorf("%s: cards %d and %d are of the same bundle", tt.name, i-1, i)
+			}
+		}
+	}
+}
+
+func TestInterleaveByBundle(t *testing.T) {
+	tests := []struct {
+		name    string
+		bundles string // the bundle of every card
+		want    string
+	}{
+		{name: "empty"},
+		{name: "one bundle", bundles: "aaa", want: "aaa"},
+		{name: "even", bundles: "aabbcc", want: "abcabc"},
+		{name: "uneven", bundles: "aaabc", want: "abaca"},
+		{name: "largest later", bundles: "abccc", want: "cacbc"},
+		{name: "unavoidable", bundles: "aaaab", want: "abaaa"},
+		{name: "sorted by due date", bundles: "abbba", want: "babab"},
+	}
+	for _, tt := range tests {
+		cards := make([]response.DueCard, len(tt.bundles))
+		for i, bundleID := range tt.bundles {
+			cards[i] = response.DueCard{ID: fmt.Sprint(i), BundleID: string(bundleID)}
+		}
+		got := ""
+		last := make(map[string]int)
+		for _, c := range interleaveByBundle(cards) {
+			got += c.BundleID
+			var i int
+			fmt.Sscan(c.ID, &i)
+			if j, ok := last[c.BundleID]; ok && j > i {
+				t.Errorf("%s: card %d after card %d of the same bundle", tt.name, i, j)
+			}
+			last[c.BundleID] = i
+		}
+		if got != tt.want {
+			t.Errorf("%s: bundles %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetCardStats(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) {})
+	user, _, cards := newBundle(t, db, 3)
+
+	// the first review is not a lapse even with a failing grade, the later reviews of a card are
+	tests := []struct {
+		name   string
+		grades []int
+		want   response.CardStats
+	}{
+		{name: "no reviews", want: response.CardStats{CardID: cards[0].ID}},
+		{name: "failed first review", grades: []int{1},
+			want: response.CardStats{CardID: cards[1].ID, Reviews: 1, AverageGrade: 1, TimeSpentMs: 1000, Interval: 1}},
+		{name: "mixed grades", grades: []int{4, 1, 4, 5},
+			want: response.CardStats{CardID: cards[2].ID, Reviews: 4, Lapses: 1, AverageGrade: 3.5, Retention: 2.0 / 3, TimeSpentMs: 4000, Interval: 6}},
+	}
+	for i, tt := range tests {
+		for _, grade := range tt.grades {
+			review(t, db, user.ID, cards[i].ID, grade)
+		}
+		stats, err := db.GetCardStats(user.ID, cards[i].ID)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		reviewed := len(tt.grades) > 0
+		if (stats.LastReviewedAt != nil) != reviewed || (stats.DueAt != nil) != reviewed {
+			t.Errorf("%s: reviewed at %v and due at %v, want them if reviewed", tt.name, stats.LastReviewedAt, stats.DueAt)
+		}
+		stats.LastReviewedAt, stats.DueAt = nil, nil
+		if math.Abs(stats.Retention-tt.want.Retention) < 1e-9 {
+			stats.Retention = tt.want.Retention
+		}
+		if *stats != tt.want {
+			t.Errorf("%s: stats %+v, want %+v", tt.name, *stats, tt.want)
+		}
+	}
+}
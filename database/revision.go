@@ -0,0 +1,117 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/diff"
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+	"gorm.io/gorm"
+)
+
+// GetCardRevisions returns the revisions of the card with the word diffs of the question and the answer, the latest first.
+func (db *Database) GetCardRevisions(cardID string, page, limit int) ([]response.CardRevision, error) {
+	if len(cardID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	revisions := []response.CardRevision{}
+	if err := db.db.Table("card_revisions").
+		Select("card_revisions.*, users.username as editor_username").
+		Joins("left join users on users.id = card_revisions.editor_id").
+		Where("card_revisions.card_id = ?", cardID).
+		Order("card_revisions.created_at desc, card_revisions.id").
+		Limit(limit).Offset((page - 1) * limit).Scan(&revisions).Error; err != nil {
+		db.logError("GetCardRevisions", err.Error(), cardID, page, limit)
+		return nil, ErrGormGet
+	}
+	for i := range revisions {
+		revisions[i].QuestionDiff = diff.Words(revisions[i].OldQuestion, revisions[i].NewQuestion)
+		revisions[i].AnswerDiff = diff.Words(revisions[i].OldAnswer, revisions[i].NewAnswer)
+	}
+	return revisions, nil
+}
+
+// RevertCardRevision sets the question and the answer of the card back to the values before the revision.
+// The revert is recorded as a new revision by editorID.
+func (db *Database) RevertCardRevision(cardID, revisionID, editorID string) (*model.Card, error) {
+	if len(cardID) == 0 || len(revisionID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	var c *model.Card
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		rev := model.CardRevision{}
+		if err := tx.Where("id = ? and card_id = ?", revisionID, cardID).First(&rev).Error; err != nil {
+			db.logError("RevertCardRevision", err.Error(), cardID, revisionID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRevisionNotFound
+			}
+			return ErrGormGet
+		}
+		var err error
+		c, err = db.updateCard(tx, cardID, editorID, &rev.OldQuestion, &rev.OldAnswer, &rev.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// updateCard sets the non-nil question and answer of the card inside the transaction tx, and records the revision.
+// The question must stay unique in the bundle. Nothing is recorded if the card does not change.
+func (db *Database) updateCard(tx *gorm.DB, cardID, editorID string, question, answer, revertOf *string) (*model.Card, error) {
+	c := model.Card{}
+	if err := tx.Where("id = ?", cardID).First(&c).Error; err != nil {
+		db.logError("updateCard", err.Error(), cardID)
+		return nil, ErrGormGet
+	}
+	now := time.Now()
+	rev := model.CardRevision{
+		ID:          generator.CreateID(),
+		CardID:      cardID,
+		EditorID:    editorID,
+		OldQuestion: c.Question,
+		NewQuestion: c.Question,
+		OldAnswer:   c.Answer,
+		NewAnswer:   c.Answer,
+		RevertOf:    revertOf,
+		CreatedAt:   now,
+	}
+	if question != nil {
+		rev.NewQuestion = *question
+	}
+	if answer != nil {
+		rev.NewAnswer = *answer
+	}
+	if rev.NewQuestion == rev.OldQuestion && rev.NewAnswer == rev.OldAnswer {
+		return &c, nil
+	}
+
+	if rev.NewQuestion != rev.OldQuestion {
+		var count int64
+		if err := tx.Model(&model.Card{}).
+			Where("bundle_id = ? and question = ? and id <> ?", c.BundleID, rev.NewQuestion, cardID).
+			Count(&count).Error; err != nil {
+			db.logError("updateCard", err.Error(), cardID)
+			return nil, ErrGormGet
+		} else if count != 0 {
+			return nil, ErrQuestionExists
+		}
+	}
+	if err := tx.Model(&c).Updates(map[string]interface{}{
+		"question":   rev.NewQuestion,
+		"answer":     rev.NewAnswer,
+		"updated_at": now,
+	}).Error; err != nil {
+		db.logError("updateCard", err.Error(), cardID)
+		return nil, ErrGormUpdate
+	}
+	if err := tx.Create(&rev).Error; err != nil {
+		db.logError("updateCard", err.Error(), cardID)
+		return nil, ErrGormCreate
+	}
+	c.Question, c.Answer, c.UpdatedAt = rev.NewQuestion, rev.NewAnswer, now
+	return &c, nil
+}
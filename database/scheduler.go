@@ -0,0 +1,201 @@
+package database
+
+import (
+	"math"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+// Scheduler computes the next review state of a card from its current state and the grade (0-5) given by the user.
+type Scheduler interface {
+	Schedule(rs *model.ReviewState, grade int, now time.Time)
+}
+
+// newScheduler returns the scheduler selected by the user. params is only used by FSRS and updated in place.
+func newScheduler(u *model.User, params *model.SchedulerParams) Scheduler {
+	if u.Scheduler == model.SchedulerFSRS {
+		return &fsrsScheduler{retention: u.DesiredRetention, params: params}
+	}
+	return sm2Scheduler{}
+}
+
+// sm2Scheduler is the SuperMemo 2 algorithm. Grades below 3 reset the repetitions.
+type sm2Scheduler struct{}
+
+func (sm2Scheduler) Schedule(rs *model.ReviewState, grade int, now time.Time) {
+	if rs.EaseFactor == 0 {
+		rs.EaseFactor = defaultEaseFactor
+	}
+	if grade >= 3 {
+		switch rs.Repetitions {
+		case 0:
+			rs.Interval = 1
+		case 1:
+			rs.Interval = 6
+		default:
+			rs.Interval = int(math.Round(float64(rs.Interval) * rs.EaseFactor))
+		}
+		rs.Repetitions++
+	} else {
+		if rs.Repetitions > 0 {
+			rs.Lapses++
+		}
+		rs.Repetitions = 0
+		rs.Interval = 1
+	}
+
+	q := float64(maxGrade - grade)
+	rs.EaseFactor += 0.1 - q*(0.08+q*0.02)
+	if rs.EaseFactor < minEaseFactor {
+		rs.EaseFactor = minEaseFactor
+	}
+	// the FSRS memory state is stale now, FSRS approximates it again from the SM-2 state
+	rs.Stability, rs.Difficulty = 0, 0
+	rs.LastReviewedAt = now
+	rs.DueAt = now.AddDate(0, 0, rs.Interval)
+}
+
+// FSRS v4.5 constants. fsrsWeights are the default parameters published with the algorithm.
+const (
+	fsrsDecay       = -0.5
+	fsrsFactor      = 19.0 / 81.0
+	fsrsMaxInterval = 36500
+
+	// tuning of the per user stability scale
+	fsrsTuneEvery = 50
+	fsrsMinScale  = 0.5
+	fsrsMaxScale  = 2.0
+	fsrsMaxStep   = 1.25
+)
+
+var fsrsWeights = [17]float64{0.4872, 1.4003, 3.7145, 13.8206, 5.1618, 1.2298, 0.8975, 0.031, 1.6474, 0.1367, 1.0461, 2.1072, 0.0793, 0.3246, 1.587, 0.2272, 2.8755}
+
+// fsrsScheduler is the Free Spaced Repetition Scheduler. Intervals are chosen so that the predicted recall
+// probability at the due date equals the desired retention of the user.
+type fsrsScheduler struct {
+	retention float64
+	params    *model.SchedulerParams
+}
+
+func (f *fsrsScheduler) Schedule(rs *model.ReviewState, grade int, now time.Time) {
+	w := fsrsWeights
+	rating := fsrsRating(grade)
+	if rs.LastReviewedAt.IsZero() {
+		rs.Stability = w[rating-1]
+		rs.Difficulty = fsrsInitDifficulty(rating)
+	} else {
+		if rs.Stability == 0 {
+			// the card was scheduled with SM-2 before, approximate its memory state
+			rs.Stability = math.Max(float64(rs.Interval), w[0])
+			rs.Difficulty = fsrsClampDifficulty(w[4] + (defaultEaseFactor-rs.EaseFactor)*4)
+		}
+		elapsed := math.Max(now.Sub(rs.LastReviewedAt).Hours()/24, 0)
+		r := fsrsRetrievability(elapsed, rs.Stability*f.scale())
+		f.observe(r, rating > 1)
+
+		rs.Difficulty = fsrsNextDifficulty(rs.Difficulty, rating)
+		if rating == 1 {
+			rs.Stability = w[11] * math.Pow(rs.Difficulty, -w[12]) * (math.Pow(rs.Stability+1, w[13]) - 1) * math.Exp(w[14]*(1-r))
+		} else {
+			hardPenalty, easyBonus := 1.0, 1.0
+			if rating == 2 {
+				hardPenalty = w[15]
+			} else if rating == 4 {
+				easyBonus = w[16]
+			}
+			rs.Stability *= 1 + math.Exp(w[8])*(11-rs.Difficulty)*math.Pow(rs.Stability, -w[9])*(math.Exp(w[10]*(1-r))-1)*hardPenalty*easyBonus
+		}
+	}
+
+	if rating == 1 {
+		if rs.Repetitions > 0 {
+			rs.Lapses++
+		}
+		rs.Repetitions = 0
+		rs.Interval = 1
+	} else {
+		rs.Repetitions++
+		rs.Interval = f.interval(rs.Stability)
+	}
+	rs.LastReviewedAt = now
+	rs.DueAt = now.AddDate(0, 0, rs.Interval)
+}
+
+// interval returns the days until the predicted recall probability drops to the desired retention.
+func (f *fsrsScheduler) interval(stability float64) int {
+	retention := f.retention
+	if retention <= 0 || retention >= 1 {
+		retention = model.DefaultRetention
+	}
+	days := stability * f.scale() / fsrsFactor * (math.Pow(retention, 1/fsrsDecay) - 1)
+	return int(math.Min(math.Max(math.Round(days), 1), fsrsMaxInterval))
+}
+
+func (f *fsrsScheduler) scale() float64 {
+	if f.params == nil || f.params.StabilityScale == 0 {
+		return 1
+	}
+	return f.params.StabilityScale
+}
+
+// observe records the predicted and the actual recall of a review. After every fsrsTuneEvery reviews the
+// stability scale of the user is adjusted so that the predictions match the review history of the user.
+func (f *fsrsScheduler) observe(predicted float64, recalled bool) {
+	p := f.params
+	if p == nil {
+		return
+	}
+	p.Reviews++
+	p.PredictedRecall += predicted
+	if recalled {
+		p.Recalls++
+	}
+	if p.Reviews < fsrsTuneEvery {
+		return
+	}
+	meanPredicted := p.PredictedRecall / float64(p.Reviews)
+	meanActual := float64(p.Recalls) / float64(p.Reviews)
+	if meanActual > 0 && meanActual < 1 && meanPredicted > 0 && meanPredicted < 1 {
+		// with R = (1 + factor*t/S)^decay and decay = -0.5, the stability which explains the actual
+		// recall rate is inversely proportional to (1/R^2 - 1)
+		step := (math.Pow(meanPredicted, -2) - 1) / (math.Pow(meanActual, -2) - 1)
+		step = math.Min(math.Max(step, 1/fsrsMaxStep), fsrsMaxStep)
+		p.StabilityScale = math.Min(math.Max(f.scale()*step, fsrsMinScale), fsrsMaxScale)
+		now := time.Now()
+		p.TunedAt = &now
+	}
+	p.Reviews, p.Recalls, p.PredictedRecall = 0, 0, 0
+}
+
+// fsrsRating maps the 0-5 grade to the FSRS ratings again (1), hard (2), good (3) and easy (4).
+func fsrsRating(grade int) int {
+	switch {
+	case grade < 3:
+		return 1
+	case grade == 3:
+		return 2
+	case grade == 4:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func fsrsRetrievability(elapsedDays, stability float64) float64 {
+	return math.Pow(1+fsrsFactor*elapsedDays/stability, fsrsDecay)
+}
+
+func fsrsInitDifficulty(rating int) float64 {
+	return fsrsClampDifficulty(fsrsWeights[4] - float64(rating-3)*fsrsWeights[5])
+}
+
+func fsrsNextDifficulty(d float64, rating int) float64 {
+	next := d - fsrsWeights[6]*float64(rating-3)
+	// mean reversion to the initial difficulty of a good rating
+	return fsrsClampDifficulty(fsrsWeights[7]*fsrsInitDifficulty(3) + (1-fsrsWeights[7])*next)
+}
+
+func fsrsClampDifficulty(d float64) float64 {
+	return math.Min(math.Max(d, 1), 10)
+}
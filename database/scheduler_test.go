@@ -0,0 +1,105 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+// schedulerTest is a review of a card in the state before, elapsed days after its last review.
+type schedulerTest struct {
+	name    string
+	before  model.ReviewState
+	elapsed int // days since the last review, the card is new if before has no review
+	grade   int
+	want    model.ReviewState // the interval, repetitions, lapses, ease factor, stability and difficulty
+}
+
+func runSchedulerTests(t *testing.T, s Scheduler, tests []schedulerTest) {
+	t.Helper()
+	now := time.Date(2021, 8, 1, 12, 0, 0, 0, time.UTC)
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-4 }
+	for _, tt := range tests {
+		rs := tt.before
+		if rs.Repetitions != 0 || rs.Interval != 0 {
+			rs.LastReviewedAt = now.AddDate(0, 0, -tt.elapsed)
+		}
+		s.Schedule(&rs, tt.grade, now)
+		if rs.Interval != tt.want.Interval || rs.Repetitions != tt.want.Repetitions || rs.Lapses != tt.want.Lapses {
+			t.Errorf("%s: interval %d, repetitions %d, lapses %d, want %d, %d, %d", tt.name,
+				rs.Interval, rs.Repetitions, rs.Lapses, tt.want.Interval, tt.want.Repetitions, tt.want.Lapses)
+		}
+		if !near(rs.EaseFactor, tt.want.EaseFactor) || !near(rs.Stability, tt.want.Stability) || !near(rs.Difficulty, tt.want.Difficulty) {
+			t.Errorf("%s: ease factor %.4f, stability %.4f, difficulty %.4f, want %.4f, %.4f, %.4f", tt.name,
+				rs.EaseFactor, rs.Stability, rs.Difficulty, tt.want.EaseFactor, tt.want.Stability, tt.want.Difficulty)
+		}
+		if !rs.LastReviewedAt.Equal(now) || !rs.DueAt.Equal(now.AddDate(0, 0, rs.Interval)) {
+			t.Errorf("%s: reviewed at %v and due at %v, want %v and %d days later", tt.name, rs.LastReviewedAt, rs.DueAt, now, rs.Interval)
+		}
+	}
+}
+
+func TestSM2Scheduler(t *testing.T) {
+	learned := model.ReviewState{EaseFactor: 2.5, Interval: 15, Repetitions: 3}
+	runSchedulerTests(t, sm2Scheduler{}, []schedulerTest{
+		{name: "first review grade 5", grade: 5, want: model.ReviewState{Interval: 1, Repetitions: 1, EaseFactor: 2.6}},
+		{name: "first review grade 4", grade: 4, want: model.ReviewState{Interval: 1, Repetitions: 1, EaseFactor: 2.5}},
+		{name: "first review grade 3", grade: 3, want: model.ReviewState{Interval: 1, Repetitions: 1, EaseFactor: 2.36}},
+		{name: "first review grade 2", grade: 2, want: model.ReviewState{Interval: 1, EaseFactor: 2.18}},
+		{name: "first review grade 1", grade: 1, want: model.ReviewState{Interval: 1, EaseFactor: 1.96}},
+		{name: "first review grade 0", grade: 0, want: model.ReviewState{Interval: 1, EaseFactor: 1.7}},
+		{name: "second review", before: model.ReviewState{EaseFactor: 2.5, Interval: 1, Repetitions: 1}, elapsed: 1, grade: 4,
+			want: model.ReviewState{Interval: 6, Repetitions: 2, EaseFactor: 2.5}},
+		{name: "third review", before: model.ReviewState{EaseFactor: 2.5, Interval: 6, Repetitions: 2}, elapsed: 6, grade: 5,
+			want: model.ReviewState{Interval: 15, Repetitions: 3, EaseFactor: 2.6}},
+		{name: "lapse grade 2", before: learned, elapsed: 15, grade: 2, want: model.ReviewState{Interval: 1, Lapses: 1, EaseFactor: 2.18}},
+		{name: "lapse grade 0", before: learned, elapsed: 15, grade: 0, want: model.ReviewState{Interval: 1, Lapses: 1, EaseFactor: 1.7}},
+		{name: "ease floor on success", before: model.ReviewState{EaseFactor: 1.4, Interval: 6, Repetitions: 2}, elapsed: 6, grade: 3,
+			want: model.ReviewState{Interval: 8, Repetitions: 3, EaseFactor: minEaseFactor}},
+		{name: "ease floor on lapse", before: model.ReviewState{EaseFactor: 1.5, Interval: 6, Repetitions: 2, Lapses: 2}, elapsed: 6, grade: 0,
+			want: model.ReviewState{Interval: 1, Lapses: 3, EaseFactor: minEaseFactor}},
+	})
+}
+
+func TestFSRSScheduler(t *testing.T) {
+	// reviewed once with grade 4 four days ago
+	reviewed := model.ReviewState{Interval: 4, Repetitions: 1, Stability: 3.7145, Difficulty: 5.1618}
+	tests := []struct {
+		retention float64
+		tests     []schedulerTest
+	}{
+		{retention: 0.9, tests: []schedulerTest{
+			{name: "first review grade 5", grade: 5, want: model.ReviewState{Interval: 14, Repetitions: 1, Stability: 13.8206, Difficulty: 3.932}},
+			{name: "first review grade 4", grade: 4, want: model.ReviewState{Interval: 4, Repetitions: 1, Stability: 3.7145, Difficulty: 5.1618}},
+			{name: "first review grade 3", grade: 3, want: model.ReviewState{Interval: 1, Repetitions: 1, Stability: 1.4003, Difficulty: 6.3916}},
+			{name: "first review grade 1", grade: 1, want: model.ReviewState{Interval: 1, Stability: 0.4872, Difficulty: 7.6214}},
+			{name: "second review grade 5", before: reviewed, elapsed: 4, grade: 5,
+				want: model.ReviewState{Interval: 40, Repetitions: 2, Stability: 40.3660, Difficulty: 4.2921}},
+			{name: "second review grade 4", before: reviewed, elapsed: 4, grade: 4,
+				want: model.ReviewState{Interval: 15, Repetitions: 2, Stability: 14.8081, Difficulty: 5.1618}},
+			{name: "second review grade 3", before: reviewed, elapsed: 4, grade: 3,
+				want: model.ReviewState{Interval: 6, Repetitions: 2, Stability: 5.8595, Difficulty: 6.0315}},
+			{name: "lapse", before: reviewed, elapsed: 4, grade: 1,
+				want: model.ReviewState{Interval: 1, Lapses: 1, Stability: 1.4006, Difficulty: 6.9012}},
+		}},
+		{retention: 0.8, tests: []schedulerTest{
+			{name: "first review grade 5", grade: 5, want: model.ReviewState{Interval: 33, Repetitions: 1, Stability: 13.8206, Difficulty: 3.932}},
+			{name: "first review grade 4", grade: 4, want: model.ReviewState{Interval: 9, Repetitions: 1, Stability: 3.7145, Difficulty: 5.1618}},
+			{name: "second review grade 4", before: reviewed, elapsed: 4, grade: 4,
+				want: model.ReviewState{Interval: 36, Repetitions: 2, Stability: 14.8081, Difficulty: 5.1618}},
+		}},
+		{retention: 0.95, tests: []schedulerTest{
+			{name: "first review grade 5", grade: 5, want: model.ReviewState{Interval: 6, Repetitions: 1, Stability: 13.8206, Difficulty: 3.932}},
+			{name: "first review grade 4", grade: 4, want: model.ReviewState{Interval: 2, Repetitions: 1, Stability: 3.7145, Difficulty: 5.1618}},
+		}},
+	}
+	for _, rt := range tests {
+		rt := rt
+		t.Run(fmt.Sprint(rt.retention), func(t *testing.T) {
+			runSchedulerTests(t, &fsrsScheduler{retention: rt.retention}, rt.tests)
+		})
+	}
+}
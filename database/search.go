@@ -0,0 +1,117 @@
+package database
+
+import (
+	"strings"
+
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+)
+
+// searchDocument is the text search vector of a card. The expression must be the same with the one of the
+// search index ix_card_search of the migrations to be able to use the index.
+const searchDocument = "to_tsvector('simple', coalesce(cards.question, '') || ' ' || coalesce(cards.answer, ''))"
+
+// searchFrom joins the cards with the bundles and the groups the user can see.
+const searchFrom = "from members " +
+	"join bundles on members.group_id = bundles.group_id " +
+	"join groups on groups.id = bundles.group_id " +
+	"join cards on cards.bundle_id = bundles.id "
+
+// SearchCards searches the questions and answers of the cards in the bundles the user can see, ordered by rank.
+// Postgres uses the full text search, SQLite finds the cards which contain every word of the query.
+func (db *Database) SearchCards(userID, query string, page, limit int) ([]response.SearchHit, error) {
+	if len(userID) == 0 || len(strings.TrimSpace(query)) == 0 {
+		return nil, ErrParamNotFound
+	}
+	if db.db.Dialector.Name() != "postgres" {
+		return db.searchCardsLike(userID, query, page, limit)
+	}
+	hits := []response.SearchHit{}
+	if err := db.db.Raw("select cards.id as card_id, cards.bundle_id, bundles.title as bundle_title, "+
+		"bundles.group_id, groups.name as group_name, "+
+		"ts_headline('simple', cards.question, q, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') as question_snippet, "+
+		"ts_headline('simple', coalesce(cards.answer, ''), q, 'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15') as answer_snippet, "+
+		"ts_rank("+searchDocument+", q) as rank "+
+		searchFrom+
+		"cross join plainto_tsquery('simple', ?) as q "+
+		"where members.user_id = ? and bundles.deleted_at is null and cards.deleted_at is null and "+searchDocument+" @@ q "+
+		"order by rank desc, cards.id limit ? offset ?", query, userID, limit, (page-1)*limit).
+		Scan(&hits).Error; err != nil {
+		db.logError("SearchCards", err.Error(), userID, query, page, limit)
+		return nil, ErrGormGet
+	}
+	return hits, nil
+}
+
+// searchCardsLike searches the cards with LIKE. A card matches if its question or answer contains every word
+// of the query, ignoring the case. Words in the question rank higher than the ones in the answer.
+func (db *Database) searchCardsLike(userID, query string, page, limit int) ([]response.SearchHit, error) {
+	words := strings.Fields(strings.ToLower(query))
+	var rank, match []string
+	var rankArgs, matchArgs []interface{}
+	for _, w := range words {
+		pattern := "%" + likeEscaper.Replace(w) + "%"
+		rank = append(rank, "(case when lower(cards.question) like ? escape '\\' then 2 else 0 end) + "+
+			"(case when lower(coalesce(cards.answer, '')) like ? escape '\\' then 1 else 0 end)")
+		rankArgs = append(rankArgs, pattern, pattern)
+		match = append(match, "lower(cards.question || ' ' || coalesce(cards.answer, '')) like ? escape '\\'")
+		matchArgs = append(matchArgs, pattern)
+	}
+	args := append(rankArgs, userID)
+	args = append(args, matchArgs...)
+	args = append(args, limit, (page-1)*limit)
+
+	hits := []response.SearchHit{}
+	if err := db.db.Raw("select cards.id as card_id, cards.bundle_id, bundles.title as bundle_title, "+
+		"bundles.group_id, groups.name as group_name, "+
+		"cards.question as question_snippet, coalesce(cards.answer, '') as answer_snippet, "+
+		strings.Join(rank, " + ")+" as rank "+
+		searchFrom+
+		"where members.user_id = ? and bundles.deleted_at is null and cards.deleted_at is null and "+
+		strings.Join(match, " and ")+" "+
+		"order by rank desc, cards.id limit ? offset ?", args...).
+		Scan(&hits).Error; err != nil {
+		db.logError("SearchCards", err.Error(), userID, query, page, limit)
+		return nil, ErrGormGet
+	}
+	for i := range hits {
+		hits[i].QuestionSnippet = highlight(hits[i].QuestionSnippet, words)
+		hits[i].AnswerSnippet = highlight(hits[i].AnswerSnippet, words)
+	}
+	return hits, nil
+}
+
+// likeEscaper escapes the wildcards of LIKE with a backslash.
+var likeEscaper = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
+// highlight wraps the words in the text in <mark></mark>, like the headlines of the full text search.
+// The words must be lower case.
+func highlight(text string, words []string) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		return text // the case mapping changed the length, the positions would not match
+	}
+	marked := make([]bool, len(text))
+	for _, w := range words {
+		for i := 0; ; {
+			j := strings.Index(lower[i:], w)
+			if j < 0 {
+				break
+			}
+			for k := i + j; k < i+j+len(w); k++ {
+				marked[k] = true
+			}
+			i += j + len(w)
+		}
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString("<mark>")
+		}
+		b.WriteByte(text[i])
+		if marked[i] && (i == len(text)-1 || !marked[i+1]) {
+			b.WriteString("</mark>")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+// The repositories are the data access of the handlers and the authenticator. Database implements them with
+// Postgres or SQLite, Memory keeps the data in memory for the tests.
+
+// UserRepository stores the users.
+type UserRepository interface {
+	InsertUser(u model.User) (*model.User, error)
+	GetUser(query map[string]interface{}) (*model.User, error)
+	UpdateUser(userID string, updates map[string]interface{}) (*model.User, error)
+	GetIDFromUsername(username string) (string, error)
+}
+
+// GroupRepository stores the groups.
+type GroupRepository interface {
+	InsertGroup(g model.Group, userID string) (*model.Group, error)
+	GetGroup(query map[string]interface{}) (*model.Group, error)
+	GetUserGroups(userID string, page, limit int) ([]response.UserGroup, error)
+	UpdateGroup(groupID string, updates map[string]interface{}) (*model.Group, error)
+	DeleteGroup(groupID string) error
+	DeleteGroupCascade(groupID string) (*response.GroupDeletion, error)
+}
+
+// MemberRepository stores the memberships and the roles of the users in the groups.
+type MemberRepository interface {
+	InsertMember(member model.Member) (*model.Member, error)
+	GetGroupMembers(groupID string, page, limit int) ([]response.GroupMember, error)
+	GetGroupUsers(groupID string, page, limit int) ([]model.User, error)
+	UpdateMember(groupID, userID string, updates map[string]interface{}) (*model.Member, error)
+	TransferOwnership(groupID, ownerID, newOwnerID string) (*model.Member, error)
+	DeleteMember(groupID, userID string) error
+	IsGroupMember(groupID, userID string) (bool, error)
+	GetMemberRole(groupID, userID string) (string, error)
+}
+
+// BundleRepository stores the bundles of the groups.
+type BundleRepository interface {
+	InsertBundle(bundle model.Bundle) (*model.Bundle, error)
+	GetBundle(bundleID string) (*response.GroupBundle, error)
+	GetGroupBundles(groupID string, page, limit int) ([]response.GroupBundle, error)
+	UpdateBundle(bundleID string, updates map[string]interface{}) (*model.Bundle, error)
+	DeleteBundle(bundleID string) error
+	CanSeeBundle(bundleID, userID string) (bool, error)
+	GetBundleRole(bundleID, userID string) (string, error)
+}
+
+// CardRepository stores the cards of the bundles.
+type CardRepository interface {
+	InsertCard(card model.Card) (*model.Card, error)
+	ImportCards(bundleID, editorID string, rows []CardRow, onConflict string) (*response.CardImport, error)
+	GetBundleCards(bundleID string, page, limit int) ([]model.Card, error)
+	GetAllBundleCards(bundleID string) ([]model.Card, error)
+	UpdateCard(cardID, editorID string, updates map[string]interface{}) (*model.Card, error)
+	DeleteCard(cardID string) error
+	DeleteBundleCards(bundleID string) error
+	GetCardRole(cardID, userID string) (string, error)
+}
+
+// TokenRepository stores the verified ID tokens and the personal access tokens.
+type TokenRepository interface {
+	AuthToken(token string) (*model.Token, error)
+	InsertToken(token, userID string, issuedAt, expires int64) (*model.Token, error)
+	MarkTokenChecked(token string) error
+	RevokeToken(token string) error
+	RevokeUserTokens(userID string) error
+	InsertAccessToken(token model.AccessToken) (*response.AccessToken, error)
+	GetAccessTokens(userID string) ([]response.AccessToken, error)
+	AuthAccessToken(token string) (*model.AccessToken, error)
+	RevokeAccessToken(userID, tokenID string) error
+}
+
+// InviteRepository stores the invites of the groups.
+type InviteRepository interface {
+	InsertInvite(invite model.Invite) (*model.Invite, error)
+	GetGroupInvites(groupID string, page, limit int) ([]model.Invite, error)
+	RevokeInvite(groupID, inviteID string) error
+	AcceptInvite(code, userID string) (*model.Member, error)
+}
+
+// JoinRequestRepository stores the join requests of the discoverable groups.
+type JoinRequestRepository interface {
+	GetDiscoverableGroups(query string, page, limit int) ([]response.GroupListing, error)
+	InsertJoinRequest(groupID, userID string) (*model.JoinRequest, error)
+	GetGroupJoinRequests(groupID, status string, page, limit int) ([]model.JoinRequest, error)
+	DecideJoinRequest(groupID, requestID, deciderID string, approve bool) (*model.JoinRequest, bool, error)
+}
+
+// ReviewRepository stores the review schedules and history of the users.
+type ReviewRepository interface {
+	ReviewCard(userID, cardID string, grade int, responseTimeMs int64) (*model.ReviewState, error)
+	GetDueCards(userID string, q DueQuery) (*response.StudyQueue, error)
+	GetCardStats(userID, cardID string) (*response.CardStats, error)
+	SearchCards(userID, query string, page, limit int) ([]response.SearchHit, error)
+}
+
+// HistoryRepository stores the trash, the card revisions and the audit log.
+type HistoryRepository interface {
+	GetGroupTrash(groupID string, page, limit int) ([]response.TrashItem, error)
+	RestoreTrashItem(itemID string) (*response.TrashItem, error)
+	GetTrashItemRole(itemID, userID string) (string, error)
+	GetCardRevisions(cardID string, page, limit int) ([]response.CardRevision, error)
+	RevertCardRevision(cardID, revisionID, editorID string) (*model.Card, error)
+	InsertAuditEntry(entry model.AuditEntry) error
+	GetAuditEntries(groupID string, q AuditQuery, page, limit int) ([]response.AuditEntry, error)
+}
+
+// Store is every repository.
+type Store interface {
+	UserRepository
+	GroupRepository
+	MemberRepository
+	BundleRepository
+	CardRepository
+	TokenRepository
+	InviteRepository
+	JoinRequestRepository
+	ReviewRepository
+	HistoryRepository
+
+	// Transaction runs fn with a store whose writes are committed together when fn returns nil, and rolled back
+	// when it returns an error.
+	Transaction(fn func(s Store) error) error
+}
+
+var _ Store = (*Database)(nil)
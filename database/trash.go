@@ -0,0 +1,207 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+	"gorm.io/gorm"
+)
+
+// trashPurgeInterval is the period of the background purge of the trash.
+const trashPurgeInterval = time.Hour
+
+// purgedCards selects the ids of the cards deleted before the time, with the cards of the bundles deleted before it.
+const purgedCards = "select id from cards where deleted_at < ? or bundle_id in (select id from bundles where deleted_at < ?)"
+
+// GetGroupTrash returns the deleted bundles and cards of the group, the latest deleted first.
+// Cards deleted with their bundle are not listed one by one.
+func (db *Database) GetGroupTrash(groupID string, page, limit int) ([]response.TrashItem, error) {
+	if len(groupID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	if page < 1 {
+		page = 1
+	}
+	var bundles []model.Bundle
+	if err := db.db.Unscoped().Where("group_id = ? and deleted_at is not null", groupID).
+		Order("deleted_at desc, id").Limit(page * limit).Find(&bundles).Error; err != nil {
+		db.logError("GetGroupTrash", err.Error(), groupID, page, limit)
+		return nil, ErrGormGet
+	}
+	var counts []struct {
+		BundleID  string
+		CardCount int
+	}
+	if err := db.db.Table("cards").Select("cards.bundle_id, count(*) as card_count").
+		Joins("join bundles on bundles.id = cards.bundle_id and bundles.deleted_at = cards.deleted_at").
+		Where("bundles.group_id = ?", groupID).Group("cards.bundle_id").Scan(&counts).Error; err != nil {
+		db.logError("GetGroupTrash", err.Error(), groupID, page, limit)
+		return nil, ErrGormGet
+	}
+	var cards []model.Card
+	if err := db.db.Unscoped().Joins("join bundles on bundles.id = cards.bundle_id").
+		Where("bundles.group_id = ? and cards.deleted_at is not null and bundles.deleted_at is null", groupID).
+		Order("cards.deleted_at desc, cards.id").Limit(page * limit).Find(&cards).Error; err != nil {
+		db.logError("GetGroupTrash", err.Error(), groupID, page, limit)
+		return nil, ErrGormGet
+	}
+
+	cardCounts := make(map[string]int)
+	for _, c := range counts {
+		cardCounts[c.BundleID] = c.CardCount
+	}
+	items := make([]response.TrashItem, 0, len(bundles)+len(cards))
+	for _, b := range bundles {
+		items = append(items, db.trashItem(response.TrashItem{
+			ID: b.ID, Kind: response.TrashBundle, Title: b.Title, CardCount: cardCounts[b.ID], DeletedAt: b.DeletedAt.Time,
+		}))
+	}
+	for _, c := range cards {
+		items = append(items, db.trashItem(response.TrashItem{
+			ID: c.ID, Kind: response.TrashCard, Title: c.Question, BundleID: c.BundleID, CardCount: 1, DeletedAt: c.DeletedAt.Time,
+		}))
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].DeletedAt.Equal(items[j].DeletedAt) {
+			return items[i].DeletedAt.After(items[j].DeletedAt)
+		}
+		return items[i].ID < items[j].ID
+	})
+
+	start := (page - 1) * limit
+	if start >= len(items) {
+		return []response.TrashItem{}, nil
+	}
+	if end := start + limit; end < len(items) {
+		items = items[:end]
+	}
+	return items[start:], nil
+}
+
+// RestoreTrashItem restores the deleted bundle or card with the id. A bundle is restored with the cards deleted with it.
+// A card cannot be restored while its bundle is in the trash or another card of the bundle has the same question.
+func (db *Database) RestoreTrashItem(itemID string) (*response.TrashItem, error) {
+	if len(itemID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	item := response.TrashItem{ID: itemID}
+	defer db.forgetAllRoles()
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		var bundles []model.Bundle
+		if err := tx.Unscoped().Where("id = ? and deleted_at is not null", itemID).Limit(1).Find(&bundles).Error; err != nil {
+			db.logError("RestoreTrashItem", err.Error(), itemID)
+			return ErrGormGet
+		}
+		if len(bundles) != 0 {
+			res := tx.Unscoped().Model(&model.Card{}).
+				Where("bundle_id = ? and deleted_at = (select deleted_at from bundles where id = ?)", itemID, itemID).
+				UpdateColumn("deleted_at", nil)
+			if res.Error != nil {
+				db.logError("RestoreTrashItem", res.Error.Error(), itemID)
+				return ErrGormUpdate
+			}
+			if err := tx.Unscoped().Model(&model.Bundle{}).Where("id = ?", itemID).UpdateColumn("deleted_at", nil).Error; err != nil {
+				db.logError("RestoreTrashItem", err.Error(), itemID)
+				return ErrGormUpdate
+			}
+			item.Kind, item.Title, item.CardCount, item.DeletedAt = response.TrashBundle, bundles[0].Title, int(res.RowsAffected), bundles[0].DeletedAt.Time
+			return nil
+		}
+
+		c := model.Card{}
+		if err := tx.Unscoped().Where("id = ? and deleted_at is not null", itemID).First(&c).Error; err != nil {
+			db.logError("RestoreTrashItem", err.Error(), itemID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTrashItemNotFound
+			}
+			return ErrGormGet
+		}
+		var count int64
+		if err := tx.Unscoped().Model(&model.Bundle{}).Where("id = ? and deleted_at is not null", c.BundleID).Count(&count).Error; err != nil {
+			db.logError("RestoreTrashItem", err.Error(), itemID)
+			return ErrGormGet
+		} else if count != 0 {
+			return ErrBundleInTrash
+		}
+		if err := tx.Model(&model.Card{}).Where("bundle_id = ? and question = ?", c.BundleID, c.Question).Count(&count).Error; err != nil {
+			db.logError("RestoreTrashItem", err.Error(), itemID)
+			return ErrGormGet
+		} else if count != 0 {
+			return ErrQuestionExists
+		}
+		if err := tx.Unscoped().Model(&c).UpdateColumn("deleted_at", nil).Error; err != nil {
+			db.logError("RestoreTrashItem", err.Error(), itemID)
+			return ErrGormUpdate
+		}
+		item.Kind, item.Title, item.BundleID, item.CardCount, item.DeletedAt = response.TrashCard, c.Question, c.BundleID, 1, c.DeletedAt.Time
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// PurgeTrash deletes the bundles and cards which were moved to the trash before the time, with the review history
+// and the revisions of the cards. Returns the number of the deleted bundles and cards.
+func (db *Database) PurgeTrash(before time.Time) (bundles, cards int64, err error) {
+	err = db.db.Transaction(func(tx *gorm.DB) error {
+		for _, value := range []interface{}{&model.ReviewLog{}, &model.ReviewState{}, &model.CardRevision{}} {
+			if err := tx.Where("card_id in ("+purgedCards+")", before, before).Delete(value).Error; err != nil {
+				db.logError("PurgeTrash", err.Error(), before)
+				return ErrGormDelete
+			}
+		}
+		res := tx.Unscoped().Where("id in ("+purgedCards+")", before, before).Delete(&model.Card{})
+		if res.Error != nil {
+			db.logError("PurgeTrash", res.Error.Error(), before)
+			return ErrGormDelete
+		}
+		cards = res.RowsAffected
+		res = tx.Unscoped().Where("deleted_at < ?", before).Delete(&model.Bundle{})
+		if res.Error != nil {
+			db.logError("PurgeTrash", res.Error.Error(), before)
+			return ErrGormDelete
+		}
+		bundles = res.RowsAffected
+		return nil
+	})
+	if bundles != 0 || cards != 0 {
+		db.forgetAllRoles()
+	}
+	return bundles, cards, err
+}
+
+// StartTrashPurge keeps the deleted bundles and cards in the trash for the retention period, then deletes them
+// in the background. A retention of 0 keeps the trash forever.
+func (db *Database) StartTrashPurge(retention time.Duration) {
+	db.trashRetention = retention
+	if retention <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for {
+			bundles, cards, err := db.PurgeTrash(time.Now().Add(-retention))
+			if err != nil {
+				db.l.Printf("[PurgeTrash] Error: %s", err.Error())
+			} else if bundles != 0 || cards != 0 {
+				db.l.Printf("[PurgeTrash] %d bundles and %d cards deleted", bundles, cards)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// trashItem sets the purge time of the item.
+func (db *Database) trashItem(item response.TrashItem) response.TrashItem {
+	if db.trashRetention > 0 {
+		purgeAt := item.DeletedAt.Add(db.trashRetention)
+		item.PurgeAt = &purgeAt
+	}
+	return item
+}
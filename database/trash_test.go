@@ -0,0 +1,139 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/config"
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+// isDeleted reports whether the card is in the trash.
+func isDeleted(t *testing.T, db *Database, cardID string) bool {
+	t.Helper()
+	var c model.Card
+	if err := db.db.Unscoped().Where("id = ?", cardID).First(&c).Error; err != nil {
+		t.Fatal(err)
+	}
+	return c.DeletedAt.Valid
+}
+
+func TestRestoreTrashItem(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) {})
+	_, bundle, cards := newBundle(t, db, 3)
+
+	// the question of the deleted card is taken by a new card
+	if err := db.DeleteCard(cards[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.InsertCard(model.Card{BundleID: bundle.ID, Question: cards[0].Question, Answer: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.RestoreTrashItem(cards[0].ID); !errors.Is(err, ErrQuestionExists) {
+		t.Fatalf("restoring the card with a taken question returned %v, want %v", err, ErrQuestionExists)
+	}
+	if !isDeleted(t, db, cards[0].ID) {
+		t.Fatal("card with a taken question is restored")
+	}
+
+	// the card is deleted before its bundle, so it stays in the trash when the bundle is restored
+	if err := db.DeleteCard(cards[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := db.DeleteBundle(bundle.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.RestoreTrashItem(cards[1].ID); !errors.Is(err, ErrBundleInTrash) {
+		t.Fatalf("restoring the card of a deleted bundle returned %v, want %v", err, ErrBundleInTrash)
+	}
+	item, err := db.RestoreTrashItem(bundle.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.CardCount != 2 {
+		t.Fatalf("restored %d cards with the bundle, want the 2 cards deleted with it", item.CardCount)
+	}
+	if !isDeleted(t, db, cards[1].ID) || isDeleted(t, db, cards[2].ID) {
+		t.Fatal("cards restored with the bundle, want only the one deleted with it")
+	}
+	if item, err = db.RestoreTrashItem(cards[1].ID); err != nil || item.BundleID != bundle.ID {
+		t.Fatalf("restoring the card after its bundle returned %+v, %v", item, err)
+	}
+	if isDeleted(t, db, cards[1].ID) {
+		t.Fatal("card is in the trash after the restore")
+	}
+
+	if _, err := db.RestoreTrashItem(cards[2].ID); !errors.Is(err, ErrTrashItemNotFound) {
+		t.Fatalf("restoring a card out of the trash returned %v, want %v", err, ErrTrashItemNotFound)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	db := newSQLite(t, func(cfg *config.Database) {})
+	user, old, oldCards := newBundle(t, db, 2)
+	kept, keptCards := addBundle(t, db, old.GroupID, 3)
+	for _, c := range append(oldCards, keptCards...) {
+		review(t, db, user.ID, c.ID, 4)
+		if _, err := db.UpdateCard(c.ID, user.ID, map[string]interface{}{"answer": "changed"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the old bundle and the first kept card are deleted two hours ago, the second kept card now
+	if err := db.DeleteBundle(old.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{keptCards[0].ID, keptCards[1].ID} {
+		if err := db.DeleteCard(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	past := time.Now().Add(-2 * time.Hour)
+	if err := db.db.Unscoped().Model(&model.Bundle{}).Where("id = ?", old.ID).UpdateColumn("deleted_at", past).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.db.Unscoped().Model(&model.Card{}).Where("bundle_id = ? or id = ?", old.ID, keptCards[0].ID).
+		UpdateColumn("deleted_at", past).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	bundles, cards, err := db.PurgeTrash(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundles != 1 || cards != 3 {
+		t.Fatalf("purged %d bundles and %d cards, want 1 and 3", bundles, cards)
+	}
+
+	purged := []string{oldCards[0].ID, oldCards[1].ID, keptCards[0].ID}
+	remaining := []string{keptCards[1].ID, keptCards[2].ID}
+	for _, value := range []interface{}{&model.Card{}, &model.ReviewLog{}, &model.ReviewState{}, &model.CardRevision{}} {
+		column := "card_id"
+		if _, ok := value.(*model.Card); ok {
+			column = "id"
+		}
+		for _, rows := range []struct {
+			ids  []string
+			want int64
+		}{{purged, 0}, {remaining, 2}} {
+			var count int64
+			if err := db.db.Unscoped().Model(value).Where(column+" in ?", rows.ids).Count(&count).Error; err != nil {
+				t.Fatal(err)
+			}
+			if count != rows.want {
+				t.Errorf("%T: %d rows of %v, want %d", value, count, rows.ids, rows.want)
+			}
+		}
+	}
+	if err := db.db.Unscoped().First(&model.Bundle{}, "id = ?", kept.ID).Error; err != nil {
+		t.Errorf("bundle of the remaining cards: %v", err)
+	}
+	if _, err := db.RestoreTrashItem(old.ID); !errors.Is(err, ErrTrashItemNotFound) {
+		t.Errorf("restoring the purged bundle returned %v, want %v", err, ErrTrashItemNotFound)
+	}
+	if _, err := db.RestoreTrashItem(keptCards[1].ID); err != nil {
+		t.Errorf("restoring the remaining card: %v", err)
+	}
+}
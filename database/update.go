@@ -1,9 +1,11 @@
 package database
 
 import (
+	"errors"
 	"time"
 
 	"github.com/ironstone95/FlashQudoV2/model"
+	"gorm.io/gorm"
 )
 
 // UpdateUser TODO Auth required
@@ -12,9 +14,21 @@ func (db *Database) UpdateUser(userID string, updates map[string]interface{}) (*
 		return nil, ErrParamNotFound
 	}
 	uv := make(map[string]interface{})
+	canUpdate := false
 	if imageURL, ok := updates["image_url"]; ok {
 		uv["image_url"] = imageURL
-	} else {
+		canUpdate = true
+	}
+	if scheduler, ok := updates["scheduler"]; ok {
+		uv["scheduler"] = scheduler
+		canUpdate = true
+	}
+	if retention, ok := updates["desired_retention"]; ok {
+		uv["desired_retention"] = retention
+		canUpdate = true
+	}
+
+	if !canUpdate {
 		db.logError("UpdateUser", ErrUpdateValueNotFound.Error(), userID, updates)
 		return nil, ErrUpdateValueNotFound
 	}
@@ -65,39 +79,33 @@ func (db *Database) UpdateBundle(bundleID string, updates map[string]interface{}
 	return &bDB, nil
 }
 
-func (db *Database) UpdateCard(cardID string, updates map[string]interface{}) (*model.Card, error) {
+// UpdateCard updates the question and/or the answer of the card, and records the change as a revision by editorID.
+func (db *Database) UpdateCard(cardID, editorID string, updates map[string]interface{}) (*model.Card, error) {
 	if len(cardID) == 0 {
 		return nil, ErrParamNotFound
 	}
-	canUpdate := false
-	uv := make(map[string]interface{})
-	if question, ok := updates["question"]; ok {
-		uv["question"] = question
-		canUpdate = true
+	var question, answer *string
+	if q, ok := updates["question"].(string); ok {
+		question = &q
 	}
-
-	if answer, ok := updates["answer"]; ok {
-		uv["answer"] = answer
-		canUpdate = true
+	if a, ok := updates["answer"].(string); ok {
+		answer = &a
 	}
-
-	if !canUpdate {
+	if question == nil && answer == nil {
 		db.logError("UpdateCard", ErrUpdateValueNotFound.Error(), cardID, updates)
 		return nil, ErrUpdateValueNotFound
 	}
 
-	uv["updated_at"] = time.Now()
-
-	c := model.Card{ID: cardID}
-	if err := db.db.Model(&c).Updates(updates).Error; err != nil {
-		db.logError("UpdateCard", err.Error(), cardID, updates)
-		return nil, ErrGormUpdate
-	}
-	if err := db.db.Where("id = ?", cardID).First(&c).Error; err != nil {
-		db.logError("UpdateCard", err.Error(), cardID, updates)
-		return nil, ErrGormGet
+	var c *model.Card
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		c, err = db.updateCard(tx, cardID, editorID, question, answer, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	return &c, nil
+	return c, nil
 }
 
 func (db *Database) UpdateMember(groupID, userID string, updates map[string]interface{}) (*model.Member, error) {
@@ -105,14 +113,16 @@ func (db *Database) UpdateMember(groupID, userID string, updates map[string]inte
 		return nil, ErrParamNotFound
 	}
 	uv := make(map[string]interface{})
-	if isAdmin, ok := updates["is_admin"]; !ok {
+	if role, ok := updates["role"]; !ok {
 		db.logError("UpdateMember", ErrUpdateValueNotFound.Error(), groupID, userID, updates)
 		return nil, ErrUpdateValueNotFound
 	} else {
-		uv["is_admin"] = isAdmin
+		uv["role"] = role
 	}
 	m := model.Member{GroupID: groupID, UserID: userID}
-	if err := db.db.Model(&m).Updates(uv).Error; err != nil {
+	err := db.db.Model(&m).Updates(uv).Error
+	db.forgetRoles(userID)
+	if err != nil {
 		db.logError("UpdateMember", err.Error(), groupID, userID, updates)
 		return nil, err
 	}
@@ -123,16 +133,63 @@ func (db *Database) UpdateMember(groupID, userID string, updates map[string]inte
 	return &m, nil
 }
 
+// TransferOwnership makes the member newOwnerID the owner of the group, the current owner ownerID becomes an admin.
+func (db *Database) TransferOwnership(groupID, ownerID, newOwnerID string) (*model.Member, error) {
+	if len(groupID) == 0 || len(ownerID) == 0 || len(newOwnerID) == 0 {
+		return nil, ErrParamNotFound
+	}
+	m := model.Member{}
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ? and user_id = ?", groupID, newOwnerID).First(&m).Error; err != nil {
+			db.logError("TransferOwnership", err.Error(), groupID, ownerID, newOwnerID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMemberNotFound
+			}
+			return ErrGormGet
+		}
+		res := tx.Model(&model.Member{}).
+			Where("group_id = ? and user_id = ? and role = ?", groupID, ownerID, model.RoleOwner).
+			Update("role", model.RoleAdmin)
+		if res.Error != nil {
+			db.logError("TransferOwnership", res.Error.Error(), groupID, ownerID, newOwnerID)
+			return ErrGormUpdate
+		}
+		// the owner may have changed since the role of the requester was checked
+		if res.RowsAffected != 1 {
+			db.logError("TransferOwnership", ErrNotOwner.Error(), groupID, ownerID, newOwnerID)
+			return ErrNotOwner
+		}
+		if err := tx.Model(&m).Update("role", model.RoleOwner).Error; err != nil {
+			db.logError("TransferOwnership", err.Error(), groupID, ownerID, newOwnerID)
+			return ErrGormUpdate
+		}
+		return nil
+	})
+	db.forgetRoles(ownerID, newOwnerID)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
 func (db *Database) UpdateGroup(groupID string, updates map[string]interface{}) (*model.Group, error) {
 	if len(groupID) == 0 {
 		return nil, ErrParamNotFound
 	}
 	uv := make(map[string]interface{})
-	if name, ok := updates["name"]; !ok {
+	canUpdate := false
+	if name, ok := updates["name"]; ok {
+		uv["name"] = name
+		canUpdate = true
+	}
+	if visibility, ok := updates["visibility"]; ok {
+		uv["visibility"] = visibility
+		canUpdate = true
+	}
+
+	if !canUpdate {
 		db.logError("UpdateGroup", ErrUpdateValueNotFound.Error(), groupID, updates)
 		return nil, ErrUpdateValueNotFound
-	} else {
-		uv["name"] = name
 	}
 
 	g := model.Group{ID: groupID}
@@ -0,0 +1,69 @@
+package diff
+
+import "strings"
+
+// kinds of the diff operations
+const (
+	Equal  = "equal"
+	Insert = "insert"
+	Delete = "delete"
+)
+
+// Op is a run of words which are kept, inserted or deleted.
+type Op struct {
+	Kind string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Words compares the whitespace separated words of a and b, and returns the operations which turn a into b.
+// Consecutive words of the same kind are joined with a space.
+func Words(a, b string) []Op {
+	aw, bw := strings.Fields(a), strings.Fields(b)
+	// lcs[i][j] is the length of the longest common subsequence of aw[i:] and bw[j:]
+	lcs := make([][]int, len(aw)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bw)+1)
+	}
+	for i := len(aw) - 1; i >= 0; i-- {
+		for j := len(bw) - 1; j >= 0; j-- {
+			if aw[i] == bw[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := []Op{}
+	add := func(kind, word string) {
+		if n := len(ops); n != 0 && ops[n-1].Kind == kind {
+			ops[n-1].Text += " " + word
+			return
+		}
+		ops = append(ops, Op{Kind: kind, Text: word})
+	}
+	i, j := 0, 0
+	for i < len(aw) && j < len(bw) {
+		switch {
+		case aw[i] == bw[j]:
+			add(Equal, aw[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(Delete, aw[i])
+			i++
+		default:
+			add(Insert, bw[j])
+			j++
+		}
+	}
+	for ; i < len(aw); i++ {
+		add(Delete, aw[i])
+	}
+	for ; j < len(bw); j++ {
+		add(Insert, bw[j])
+	}
+	return ops
+}
@@ -0,0 +1,31 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Op
+	}{
+		{name: "both empty", want: []Op{}},
+		{name: "identical", a: "the quick fox", b: "the quick fox", want: []Op{{Equal, "the quick fox"}}},
+		{name: "all inserted", b: "the quick fox", want: []Op{{Insert, "the quick fox"}}},
+		{name: "all deleted", a: "the quick fox", want: []Op{{Delete, "the quick fox"}}},
+		{name: "replaced", a: "red fox", b: "blue cat", want: []Op{{Delete, "red fox"}, {Insert, "blue cat"}}},
+		{name: "word inserted", a: "the fox", b: "the quick fox", want: []Op{{Equal, "the"}, {Insert, "quick"}, {Equal, "fox"}}},
+		{name: "word deleted", a: "the quick fox", b: "the fox", want: []Op{{Equal, "the"}, {Delete, "quick"}, {Equal, "fox"}}},
+		{name: "consecutive ops merged", a: "a b c d e", b: "a x y e",
+			want: []Op{{Equal, "a"}, {Delete, "b c d"}, {Insert, "x y"}, {Equal, "e"}}},
+		{name: "whitespace only", a: "the  quick\tfox\n", b: " the quick fox", want: []Op{{Equal, "the quick fox"}}},
+		{name: "whitespace only text", a: " \t\n", b: "  ", want: []Op{}},
+	}
+	for _, tt := range tests {
+		if got := Words(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Words(%q, %q) = %v, want %v", tt.name, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package generator
 
 import (
+	"crypto/rand"
+	"encoding/base32"
 	"log"
 	"strings"
 
@@ -56,3 +58,21 @@ func CreateID() string {
 	id = strings.ReplaceAll(id, "-", "")
 	return id
 }
+
+// CreateCode returns a random code which is hard to guess, to be shared with other users.
+func CreateCode() string {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal(err)
+	}
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+}
+
+// CreateAccessToken returns a random personal access token with the prefix.
+func CreateAccessToken(prefix string) string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal(err)
+	}
+	return prefix + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+}
@@ -14,6 +14,8 @@ require (
 	github.com/google/uuid v1.3.0
 	github.com/googleapis/gax-go v1.0.3 // indirect
 	github.com/gorilla/mux v1.8.0
+	github.com/jackc/pgconn v1.8.1
+	github.com/mattn/go-sqlite3 v1.14.8
 	golang.org/x/exp v0.0.0-20210729172720-737cce5152fc // indirect
 	golang.org/x/net v0.0.0-20210805182204-aaa1db679c0d // indirect
 	golang.org/x/oauth2 v0.0.0-20210810183815-faf39c7919d5 // indirect
@@ -22,6 +24,7 @@ require (
 	google.golang.org/api v0.52.0 // indirect
 	google.golang.org/genproto v0.0.0-20210811021853-ddbe55d93216 // indirect
 	gorm.io/driver/postgres v1.1.0
+	gorm.io/driver/sqlite v1.1.4
 	gorm.io/gorm v1.21.9
 	honnef.co/go/tools v0.2.0 // indirect
 )
@@ -1,7 +1,7 @@
 package handler
 
 import (
-	"errors"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,15 +9,18 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/ironstone95/FlashQudoV2/database"
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+	"github.com/ironstone95/FlashQudoV2/principal"
 )
 
 type DeleteHandler struct {
 	l        *log.Logger
-	db       *database.Database
+	db       database.Store
 	debugLog *log.Logger
 }
 
-func NewDeleteHandler(l *log.Logger, db *database.Database, fullLog bool) *DeleteHandler {
+func NewDeleteHandler(l *log.Logger, db database.Store, fullLog bool) *DeleteHandler {
 	dh := new(DeleteHandler)
 	dh.l = l
 	dh.db = db
@@ -27,21 +30,26 @@ func NewDeleteHandler(l *log.Logger, db *database.Database, fullLog bool) *Delet
 	return dh
 }
 
+// DeleteGroup deletes an empty group. With ?cascade=true&confirm=<group name> deletes the group with everything in it.
 func (dh *DeleteHandler) DeleteGroup(rw http.ResponseWriter, r *http.Request) {
 	groupID := mux.Vars(r)["groupID"]
-	if err := dh.db.DeleteGroup(groupID); err != nil {
-		dh.log("DeleteGroup delete", err.Error())
-		if errors.Is(err, database.ErrMembersExists) {
-			dh.log("DeleteGroup database delete", err.Error())
-			SendError(rw, "cannot delete group with members", http.StatusBadRequest)
-
-		} else {
-			SendError(rw, "bad request", http.StatusBadRequest)
+	if r.URL.Query().Get("cascade") == "true" {
+		dh.deleteGroupCascade(rw, r, groupID)
+		return
+	}
+	err := dh.db.Transaction(func(s database.Store) error {
+		if err := s.DeleteGroup(groupID); err != nil {
+			return err
 		}
+		return audit(s, r, model.AuditEntry{GroupID: groupID, Action: model.AuditGroupDelete, TargetType: model.TargetGroup, TargetID: groupID})
+	})
+	if err != nil {
+		dh.log("DeleteGroup delete", err.Error())
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
 
-	_, err := fmt.Fprint(rw, "group deleted")
+	_, err = fmt.Fprint(rw, "group deleted")
 	if err != nil {
 		dh.log("DeleteGroup response", err.Error())
 		SendError(rw, "server error", http.StatusInternalServerError)
@@ -49,15 +57,58 @@ func (dh *DeleteHandler) DeleteGroup(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// deleteGroupCascade deletes the group with its content if the confirm param is the name of the group.
+func (dh *DeleteHandler) deleteGroupCascade(rw http.ResponseWriter, r *http.Request, groupID string) {
+	group, err := dh.db.GetGroup(map[string]interface{}{"id": groupID})
+	if err != nil {
+		dh.log("DeleteGroup getGroup", err.Error())
+		SendErrorOf(rw, err, "server error", http.StatusInternalServerError)
+		return
+	}
+	if r.URL.Query().Get("confirm") != group.Name {
+		dh.log("DeleteGroup confirm", "confirmation does not match the group name")
+		msg := "confirm must be the name of the group"
+		sendErrorCode(rw, http.StatusUnprocessableEntity, CodeInvalidField, msg, response.FieldError{Field: "confirm", Code: CodeInvalidField, Message: msg})
+		return
+	}
+
+	var gd *response.GroupDeletion
+	err = dh.db.Transaction(func(s database.Store) error {
+		if gd, err = s.DeleteGroupCascade(groupID); err != nil {
+			return err
+		}
+		return audit(s, r, model.AuditEntry{GroupID: groupID, Action: model.AuditGroupDelete, TargetType: model.TargetGroup, TargetID: groupID, Before: group.Name})
+	})
+	if err != nil {
+		dh.log("DeleteGroup cascade", err.Error())
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(gd); err != nil {
+		dh.log("DeleteGroup encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+	dh.log("DeleteGroup", "SUCCESS")
+}
+
 func (dh *DeleteHandler) DeleteBundle(rw http.ResponseWriter, r *http.Request) {
 	bundleID := mux.Vars(r)["bundleID"]
-	if err := dh.db.DeleteBundle(bundleID); err != nil {
+	err := dh.db.Transaction(func(s database.Store) error {
+		if err := s.DeleteBundle(bundleID); err != nil {
+			return err
+		}
+		return audit(s, r, model.AuditEntry{Action: model.AuditBundleDelete, TargetType: model.TargetBundle, TargetID: bundleID})
+	})
+	if err != nil {
 		dh.log("DeleteBundle delete", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
 
-	_, err := fmt.Fprint(rw, "bundle delete")
+	_, err = fmt.Fprint(rw, "bundle delete")
 	if err != nil {
 		dh.log("DeleteBundle response", err.Error())
 		SendError(rw, "server error", http.StatusInternalServerError)
@@ -67,13 +118,19 @@ func (dh *DeleteHandler) DeleteBundle(rw http.ResponseWriter, r *http.Request) {
 
 func (dh *DeleteHandler) DeleteBundleCards(rw http.ResponseWriter, r *http.Request) {
 	bundleID := mux.Vars(r)["bundleID"]
-	if err := dh.db.DeleteBundleCards(bundleID); err != nil {
+	err := dh.db.Transaction(func(s database.Store) error {
+		if err := s.DeleteBundleCards(bundleID); err != nil {
+			return err
+		}
+		return audit(s, r, model.AuditEntry{Action: model.AuditBundleClear, TargetType: model.TargetBundle, TargetID: bundleID})
+	})
+	if err != nil {
 		dh.log("DeleteBundleCards delete", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
 
-	_, err := fmt.Fprint(rw, "bundle cards delete")
+	_, err = fmt.Fprint(rw, "bundle cards delete")
 	if err != nil {
 		dh.log("DeleteBundleCards response", err.Error())
 		SendError(rw, "server error", http.StatusInternalServerError)
@@ -83,13 +140,19 @@ func (dh *DeleteHandler) DeleteBundleCards(rw http.ResponseWriter, r *http.Reque
 
 func (dh *DeleteHandler) DeleteCard(rw http.ResponseWriter, r *http.Request) {
 	cardID := mux.Vars(r)["cardID"]
-	if err := dh.db.DeleteCard(cardID); err != nil {
+	err := dh.db.Transaction(func(s database.Store) error {
+		if err := s.DeleteCard(cardID); err != nil {
+			return err
+		}
+		return audit(s, r, model.AuditEntry{Action: model.AuditCardDelete, TargetType: model.TargetCard, TargetID: cardID})
+	})
+	if err != nil {
 		dh.log("DeleteCard delete", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
 
-	_, err := fmt.Fprint(rw, "card delete")
+	_, err = fmt.Fprint(rw, "card delete")
 	if err != nil {
 		dh.log("DeleteCard response", err.Error())
 		SendError(rw, "server error", http.StatusInternalServerError)
@@ -99,31 +162,80 @@ func (dh *DeleteHandler) DeleteCard(rw http.ResponseWriter, r *http.Request) {
 
 func (dh *DeleteHandler) DeleteMember(rw http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	if isAdmin, err := dh.db.IsAdmin(vars["groupID"], vars["userID"]); err != nil {
-		dh.log("DeleteMember admin check", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
-		return
-	} else if isAdmin {
-		requesterID, _ := dh.db.GetIDFromToken(r.Header.Get("X-Auth-Token"))
-		if requesterID != vars["userID"] {
-			dh.log("DeleteMember adminCheck", "admin is not deleted member")
-			SendError(rw, "an admin cannot change other admins status", http.StatusForbidden)
-			return
-		}
+	requesterID, err := principal.UserID(r.Context())
+	if err != nil {
+		dh.log("DeleteMember Auth", err.Error())
+		SendError(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+	requesterRole, targetRole, err := memberRoles(dh.db, vars["groupID"], requesterID, vars["userID"])
+	if err != nil {
+		dh.log("DeleteMember memberRoles", err.Error())
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
+		return
+	} else if len(targetRole) == 0 {
+		dh.log("DeleteMember memberRoles", "user is not a member")
+		SendErrorOf(rw, database.ErrMemberNotFound, "cannot find", http.StatusNotFound)
+		return
+	}
+	if err := checkMemberChange(requesterID, vars["userID"], requesterRole, targetRole); err != nil {
+		dh.log("DeleteMember requesterCheck", err.Error())
+		SendErrorOf(rw, err, err.Error(), http.StatusForbidden)
+		return
 	}
 
-	if err := dh.db.DeleteMember(vars["groupID"], vars["userID"]); err != nil {
+	err = dh.db.Transaction(func(s database.Store) error {
+		if err := s.DeleteMember(vars["groupID"], vars["userID"]); err != nil {
+			return err
+		}
+		return audit(s, r, model.AuditEntry{GroupID: vars["groupID"], ActorID: requesterID, Action: model.AuditMemberRemove, TargetType: model.TargetUser, TargetID: vars["userID"], Before: targetRole})
+	})
+	if err != nil {
 		dh.log("DeleteMember dbDelete", err.Error())
-		SendError(rw, "server error", http.StatusInternalServerError)
+		SendErrorOf(rw, err, "server error", http.StatusInternalServerError)
 		return
 	}
 
-	_, err := fmt.Fprint(rw, "Member deleted")
+	_, err = fmt.Fprint(rw, "Member deleted")
 	if err != nil {
 		dh.log("Delete Member Response", err.Error())
 	}
 }
 
+func (dh *DeleteHandler) DeleteInvite(rw http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := dh.db.RevokeInvite(vars["groupID"], vars["inviteID"]); err != nil {
+		dh.log("DeleteInvite revoke", err.Error())
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	_, err := fmt.Fprint(rw, "invite revoked")
+	if err != nil {
+		dh.log("DeleteInvite response", err.Error())
+	}
+}
+
+// DeleteAccessToken revokes a personal access token of the user. Access tokens cannot revoke tokens.
+func (dh *DeleteHandler) DeleteAccessToken(rw http.ResponseWriter, r *http.Request) {
+	p, ok := principal.FromContext(r.Context())
+	if !ok || p.IsAccessToken() {
+		dh.log("DeleteAccessToken", "requested with an access token")
+		SendError(rw, "access tokens cannot manage access tokens", http.StatusForbidden)
+		return
+	}
+	if err := dh.db.RevokeAccessToken(p.UserID, mux.Vars(r)["tokenID"]); err != nil {
+		dh.log("DeleteAccessToken revoke", err.Error())
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	_, err := fmt.Fprint(rw, "access token revoked")
+	if err != nil {
+		dh.log("DeleteAccessToken response", err.Error())
+	}
+}
+
 func (dh *DeleteHandler) log(prefix, msg string) {
 	if dh.debugLog != nil {
 		dh.debugLog.Printf("[%s] %s\n", prefix, msg)
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ironstone95/FlashQudoV2/database"
+	"github.com/ironstone95/FlashQudoV2/handler/request"
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+)
+
+// codes of the error responses. Codes are part of the API, they must not be changed once released.
+// Unknown groups, bundles and cards of the routes behind the authorization middlewares are forbidden with
+// CodeForbidden like the ones of other groups, so that the response does not tell whether they exist. The
+// not found codes are for the resources which are not authorized by their group, and for the ones deleted
+// after the authorization.
+const (
+	CodeBadRequest    = "request.invalid"
+	CodeMissingField  = "request.missing_field"
+	CodeMissingParam  = "request.missing_param"
+	CodeInvalidField  = "request.invalid_field"
+	CodeForbidden     = "auth.forbidden"
+	CodeNotFound      = "resource.not_found"
+	CodeConflict      = "resource.conflict"
+	CodeUnprocessable = "request.unprocessable"
+	CodeTooLarge      = "request.too_large"
+	CodeBlankField    = "request.blank_field"
+	CodeTooShort      = "request.too_short"
+	CodeTooLong       = "request.too_long"
+	CodeInvalidFormat = "request.invalid_format"
+	CodeInvalidURL    = "request.invalid_url"
+	CodeServerError   = "server.error"
+	CodeNotSupported  = "server.not_supported"
+
+	CodeUserNotFound        = "user.not_found"
+	CodeUserExists          = "user.exists"
+	CodeUsernameTaken       = "user.username_taken"
+	CodeGroupNotFound       = "group.not_found"
+	CodeGroupNotEmpty       = "group.not_empty"
+	CodeGroupNotJoinable    = "group.not_joinable"
+	CodeMemberNotFound      = "member.not_found"
+	CodeMemberExists        = "member.exists"
+	CodeMemberIsOwner       = "member.is_owner"
+	CodeMemberNotOwner      = "member.not_owner"
+	CodeRoleTooLow          = "member.role_too_low"
+	CodeBundleNotFound      = "bundle.not_found"
+	CodeBundleInTrash       = "bundle.in_trash"
+	CodeCardNotFound        = "card.not_found"
+	CodeQuestionDuplicate   = "card.question_duplicate"
+	CodeRevisionNotFound    = "revision.not_found"
+	CodeTrashItemNotFound   = "trash.item_not_found"
+	CodeInviteNotFound      = "invite.not_found"
+	CodeInviteInvalid       = "invite.invalid"
+	CodeJoinRequestNotFound = "join_request.not_found"
+	CodeJoinRequestPending  = "join_request.pending"
+	CodeJoinRequestDecided  = "join_request.decided"
+	CodeAccessTokenNotFound = "access_token.not_found"
+	CodeInvalidPackage      = "import.invalid_package"
+	CodeImportConflict      = "import.conflict"
+)
+
+// errors of the member checks of the handlers
+var ErrOwnerUnchangeable = errors.New("the owner cannot be changed, ownership must be transferred first")
+var ErrMemberRoleTooLow = errors.New("a member cannot change members with the same or a higher role")
+var ErrGrantedRoleTooHigh = errors.New("a member cannot grant a role higher than his/her own")
+
+// ErrBodyTooLarge is the error of a request body larger than maxBodySize.
+var ErrBodyTooLarge = errors.New("request body is too large")
+
+// errorCode is the response of an error. Errors of a field are reported in the details too.
+type errorCode struct {
+	err    error
+	status int
+	code   string
+	field  string
+}
+
+// errorCodes are the responses of the known database, request and handler errors.
+var errorCodes = []errorCode{
+	{err: database.ErrUserNotFound, status: http.StatusNotFound, code: CodeUserNotFound},
+	{err: database.ErrUserExists, status: http.StatusConflict, code: CodeUserExists},
+	{err: database.ErrUsernameExists, status: http.StatusConflict, code: CodeUsernameTaken, field: "username"},
+	{err: database.ErrGroupNotFound, status: http.StatusNotFound, code: CodeGroupNotFound},
+	{err: database.ErrMembersExists, status: http.StatusConflict, code: CodeGroupNotEmpty},
+	{err: database.ErrGroupNotJoinable, status: http.StatusForbidden, code: CodeGroupNotJoinable},
+	{err: database.ErrMemberNotFound, status: http.StatusNotFound, code: CodeMemberNotFound},
+	{err: database.ErrNotOwner, status: http.StatusConflict, code: CodeMemberNotOwner},
+	{err: database.ErrAlreadyMember, status: http.StatusConflict, code: CodeMemberExists},
+	{err: database.ErrBundleInTrash, status: http.StatusConflict, code: CodeBundleInTrash},
+	{err: database.ErrQuestionExists, status: http.StatusConflict, code: CodeQuestionDuplicate, field: "question"},
+	{err: database.ErrRevisionNotFound, status: http.StatusNotFound, code: CodeRevisionNotFound},
+	{err: database.ErrTrashItemNotFound, status: http.StatusNotFound, code: CodeTrashItemNotFound},
+	{err: database.ErrInviteNotFound, status: http.StatusNotFound, code: CodeInviteNotFound},
+	{err: database.ErrInviteInvalid, status: http.StatusUnprocessableEntity, code: CodeInviteInvalid},
+	{err: database.ErrJoinRequestNotFound, status: http.StatusNotFound, code: CodeJoinRequestNotFound},
+	{err: database.ErrJoinRequestExists, status: http.StatusConflict, code: CodeJoinRequestPending},
+	{err: database.ErrJoinRequestDecided, status: http.StatusConflict, code: CodeJoinRequestDecided},
+	{err: database.ErrAccessTokenNotFound, status: http.StatusNotFound, code: CodeAccessTokenNotFound},
+	{err: database.ErrInvalidConflictMode, status: http.StatusUnprocessableEntity, code: CodeInvalidField, field: "onConflict"},
+	{err: database.ErrInvalidGrade, status: http.StatusUnprocessableEntity, code: CodeInvalidField, field: "grade"},
+	{err: database.ErrParamNotFound, status: http.StatusBadRequest, code: CodeMissingParam},
+	{err: database.ErrNotSupported, status: http.StatusNotImplemented, code: CodeNotSupported},
+
+	{err: request.ErrMissingField, status: http.StatusUnprocessableEntity, code: CodeMissingField},
+	{err: request.ErrBlankField, status: http.StatusUnprocessableEntity, code: CodeBlankField},
+	{err: request.ErrTooShort, status: http.StatusUnprocessableEntity, code: CodeTooShort},
+	{err: request.ErrTooLong, status: http.StatusUnprocessableEntity, code: CodeTooLong},
+	{err: request.ErrInvalidFormat, status: http.StatusUnprocessableEntity, code: CodeInvalidFormat},
+	{err: request.ErrInvalidURL, status: http.StatusUnprocessableEntity, code: CodeInvalidURL},
+	{err: request.ErrInvalidGrade, status: http.StatusUnprocessableEntity, code: CodeInvalidField, field: "grade"},
+	{err: request.ErrInvalidScheduler, status: http.StatusUnprocessableEntity, code: CodeInvalidField, field: "scheduler"},
+	{err: request.ErrInvalidRetention, status: http.StatusUnprocessableEntity, code: CodeInvalidField, field: "desiredRetention"},
+	{err: request.ErrInvalidResponseTime, status: http.StatusUnprocessableEntity, code: CodeInvalidField, field: "responseTime"},
+	{err: request.ErrInvalidExpiration, status: http.StatusUnprocessableEntity, code: CodeInvalidField, field: "expiresAt"},
+	{err: request.ErrInvalidMaxUses, status: http.StatusUnprocessableEntity, code: CodeInvalidField, field: "maxUses"},
+	{err: request.ErrInvalidVisibility, status: http.StatusUnprocessableEntity, code: CodeInvalidField, field: "visibility"},
+	{err: request.ErrInvalidScope, status: http.StatusUnprocessableEntity, code: CodeInvalidField, field: "scopes"},
+	{err: request.ErrInvalidRole, status: http.StatusUnprocessableEntity, code: CodeInvalidField, field: "role"},
+	{err: request.ErrGroupMismatch, status: http.StatusUnprocessableEntity, code: CodeInvalidField, field: "groupID"},
+
+	{err: ErrOwnerUnchangeable, status: http.StatusForbidden, code: CodeMemberIsOwner},
+	{err: ErrMemberRoleTooLow, status: http.StatusForbidden, code: CodeRoleTooLow},
+	{err: ErrGrantedRoleTooHigh, status: http.StatusForbidden, code: CodeRoleTooLow, field: "role"},
+	{err: ErrBodyTooLarge, status: http.StatusRequestEntityTooLarge, code: CodeTooLarge},
+}
+
+// statusCodes are the codes of the errors which are not known, by status.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodeTooLarge,
+	http.StatusUnprocessableEntity:   CodeUnprocessable,
+	http.StatusInternalServerError:   CodeServerError,
+	http.StatusNotImplemented:        CodeNotSupported,
+}
+
+// SendError sends an error response with the generic code of the status.
+func SendError(rw http.ResponseWriter, message string, status int) {
+	code, ok := statusCodes[status]
+	if !ok {
+		code = CodeBadRequest
+		if status >= http.StatusInternalServerError {
+			code = CodeServerError
+		}
+	}
+	sendErrorCode(rw, status, code, message)
+}
+
+// SendErrorOf sends the response of a known error with its own status and code, other errors are sent with
+// message and status. Validation errors are sent with the errors of the fields as details, the code of the
+// response is the code of the first field.
+func SendErrorOf(rw http.ResponseWriter, err error, message string, status int) {
+	var ve *request.ValidationError
+	if errors.As(err, &ve) {
+		details := make([]response.FieldError, 0, len(ve.Fields))
+		for _, f := range ve.Fields {
+			code := CodeInvalidField
+			if ec, ok := findErrorCode(f.Err); ok {
+				code = ec.code
+			}
+			details = append(details, response.FieldError{Field: f.Field, Code: code, Message: f.Message})
+		}
+		sendErrorCode(rw, http.StatusUnprocessableEntity, details[0].Code, ve.Error(), details...)
+		return
+	}
+	if ec, ok := findErrorCode(err); ok {
+		var details []response.FieldError
+		if len(ec.field) != 0 {
+			details = []response.FieldError{{Field: ec.field, Code: ec.code, Message: ec.err.Error()}}
+		}
+		sendErrorCode(rw, ec.status, ec.code, ec.err.Error(), details...)
+		return
+	}
+	SendError(rw, message, status)
+}
+
+// findErrorCode returns the response of a known error.
+func findErrorCode(err error) (errorCode, bool) {
+	for _, ec := range errorCodes {
+		if errors.Is(err, ec.err) {
+			return ec, true
+		}
+	}
+	return errorCode{}, false
+}
+
+// sendErrorCode sends an error response with the code and the details of the fields.
+func sendErrorCode(rw http.ResponseWriter, status int, code, message string, details ...response.FieldError) {
+	err := response.Error{Status: status, Code: code, Message: message, Details: details}
+	enc := json.NewEncoder(rw)
+	rw.WriteHeader(err.Status)
+	_ = enc.Encode(err)
+}
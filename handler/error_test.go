@@ -0,0 +1,17 @@
+package handler
+
+import "testing"
+
+// TestErrorCodeStatus checks every code is sent with a single status.
+func TestErrorCodeStatus(t *testing.T) {
+	statuses := make(map[string]int)
+	for status, code := range statusCodes {
+		statuses[code] = status
+	}
+	for _, ec := range errorCodes {
+		if status, ok := statuses[ec.code]; ok && status != ec.status {
+			t.Errorf("%s is sent with %d for %q and with %d", ec.code, ec.status, ec.err, status)
+		}
+		statuses[ec.code] = ec.status
+	}
+}
@@ -1,21 +1,25 @@
 package handler
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/ironstone95/FlashQudoV2/database"
+	"github.com/ironstone95/FlashQudoV2/principal"
 )
 
 type GetHandler struct {
 	l        *log.Logger
-	db       *database.Database
+	db       database.Store
 	debugLog *log.Logger
 }
 
-func NewGetHandler(l *log.Logger, db *database.Database, fullLog bool) *GetHandler {
+func NewGetHandler(l *log.Logger, db database.Store, fullLog bool) *GetHandler {
 	gh := new(GetHandler)
 	gh.l = l
 	gh.db = db
@@ -37,7 +41,7 @@ func (gh *GetHandler) GetUser(rw http.ResponseWriter, r *http.Request) {
 	user, err := gh.db.GetUser(map[string]interface{}{"username": username})
 	if err != nil {
 		gh.log("GetUser", err.Error())
-		SendError(rw, "cannot find", http.StatusNotFound)
+		SendErrorOf(rw, err, "server error", http.StatusInternalServerError)
 		return
 	}
 	enc := json.NewEncoder(rw)
@@ -62,7 +66,7 @@ func (gh *GetHandler) GetGroup(rw http.ResponseWriter, r *http.Request) {
 	group, err := gh.db.GetGroup(map[string]interface{}{"id": groupID})
 	if err != nil {
 		gh.log("GetGroup", err.Error())
-		SendError(rw, "cannot find", http.StatusNotFound)
+		SendErrorOf(rw, err, "server error", http.StatusInternalServerError)
 		return
 	}
 
@@ -76,13 +80,33 @@ func (gh *GetHandler) GetGroup(rw http.ResponseWriter, r *http.Request) {
 	gh.log("GetGroup", "SUCCESS")
 }
 
+// GetGroups lists the discoverable and open groups whose name contains the query parameter query.
+func (gh *GetHandler) GetGroups(rw http.ResponseWriter, r *http.Request) {
+	p := newPaging(r)
+	groups, err := gh.db.GetDiscoverableGroups(r.URL.Query().Get("query"), p.page, p.limit)
+	if err != nil {
+		gh.log("GetGroups dbGet", err.Error())
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(groups); err != nil {
+		gh.log("GetGroups encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	gh.log("GetGroups", "SUCCESS")
+}
+
 func (gh *GetHandler) GetUserGroups(rw http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("id").(string)
+	userID, _ := principal.UserID(r.Context())
 	p := newPaging(r)
 	groups, err := gh.db.GetUserGroups(userID, p.page, p.limit)
 	if err != nil {
 		gh.log("GetUserGroups dbGet", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
 
@@ -94,6 +118,45 @@ func (gh *GetHandler) GetUserGroups(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetAccessTokens lists the personal access tokens of the user, without the tokens themselves.
+func (gh *GetHandler) GetAccessTokens(rw http.ResponseWriter, r *http.Request) {
+	userID, _ := principal.UserID(r.Context())
+	tokens, err := gh.db.GetAccessTokens(userID)
+	if err != nil {
+		gh.log("GetAccessTokens dbGet", err.Error())
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(tokens); err != nil {
+		gh.log("GetAccessTokens encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	gh.log("GetAccessTokens", "SUCCESS")
+}
+
+func (gh *GetHandler) GetDueCards(rw http.ResponseWriter, r *http.Request) {
+	userID, _ := principal.UserID(r.Context())
+	queue, err := gh.db.GetDueCards(userID, newDueQuery(r))
+	if err != nil {
+		gh.log("GetDueCards dbGet", err.Error())
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(queue); err != nil {
+		gh.log("GetDueCards encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	gh.log("GetDueCards", "SUCCESS")
+}
+
 func (gh *GetHandler) GetGroupUsers(rw http.ResponseWriter, r *http.Request) {
 	groupID, err := getParam("groupID", r)
 	if err != nil {
@@ -105,7 +168,7 @@ func (gh *GetHandler) GetGroupUsers(rw http.ResponseWriter, r *http.Request) {
 	users, err := gh.db.GetGroupMembers(groupID, p.page, p.limit)
 	if err != nil {
 		gh.log("GetGroupUsers", err.Error())
-		SendError(rw, "cannot find", http.StatusNotFound)
+		SendErrorOf(rw, err, "cannot find", http.StatusNotFound)
 		return
 	}
 	enc := json.NewEncoder(rw)
@@ -129,7 +192,7 @@ func (gh *GetHandler) GetGroupBundles(rw http.ResponseWriter, r *http.Request) {
 	bundles, err := gh.db.GetGroupBundles(groupID, p.page, p.limit)
 	if err != nil {
 		gh.log("GetGroupBundles", err.Error())
-		SendError(rw, "cannot find", http.StatusNotFound)
+		SendErrorOf(rw, err, "cannot find", http.StatusNotFound)
 		return
 	}
 	enc := json.NewEncoder(rw)
@@ -142,6 +205,136 @@ func (gh *GetHandler) GetGroupBundles(rw http.ResponseWriter, r *http.Request) {
 	gh.log("GetGroupBundles", "SUCCESS")
 }
 
+func (gh *GetHandler) GetGroupInvites(rw http.ResponseWriter, r *http.Request) {
+	groupID, err := getParam("groupID", r)
+	if err != nil {
+		gh.log("GetGroupInvites", err.Error())
+		SendError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p := newPaging(r)
+	invites, err := gh.db.GetGroupInvites(groupID, p.page, p.limit)
+	if err != nil {
+		gh.log("GetGroupInvites", err.Error())
+		SendErrorOf(rw, err, "cannot find", http.StatusNotFound)
+		return
+	}
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(invites); err != nil {
+		gh.log("GetGroupInvites", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	gh.log("GetGroupInvites", "SUCCESS")
+}
+
+// GetGroupJoinRequests lists the join requests of the group, filtered by the query parameter status.
+func (gh *GetHandler) GetGroupJoinRequests(rw http.ResponseWriter, r *http.Request) {
+	groupID, err := getParam("groupID", r)
+	if err != nil {
+		gh.log("GetGroupJoinRequests", err.Error())
+		SendError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p := newPaging(r)
+	requests, err := gh.db.GetGroupJoinRequests(groupID, r.URL.Query().Get("status"), p.page, p.limit)
+	if err != nil {
+		gh.log("GetGroupJoinRequests", err.Error())
+		SendErrorOf(rw, err, "cannot find", http.StatusNotFound)
+		return
+	}
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(requests); err != nil {
+		gh.log("GetGroupJoinRequests", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	gh.log("GetGroupJoinRequests", "SUCCESS")
+}
+
+// GetCardRevisions lists the revisions of the card with their diffs.
+func (gh *GetHandler) GetCardRevisions(rw http.ResponseWriter, r *http.Request) {
+	cardID, err := getParam("cardID", r)
+	if err != nil {
+		gh.log("GetCardRevisions", err.Error())
+		SendError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p := newPaging(r)
+	revisions, err := gh.db.GetCardRevisions(cardID, p.page, p.limit)
+	if err != nil {
+		gh.log("GetCardRevisions", err.Error())
+		SendErrorOf(rw, err, "cannot find", http.StatusNotFound)
+		return
+	}
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(revisions); err != nil {
+		gh.log("GetCardRevisions", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	gh.log("GetCardRevisions", "SUCCESS")
+}
+
+// GetGroupAudit lists the audit log of the group, filtered by actor, action and time range.
+func (gh *GetHandler) GetGroupAudit(rw http.ResponseWriter, r *http.Request) {
+	groupID, err := getParam("groupID", r)
+	if err != nil {
+		gh.log("GetGroupAudit", err.Error())
+		SendError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	aq, err := newAuditQuery(r)
+	if err != nil {
+		gh.log("GetGroupAudit", err.Error())
+		SendError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p := newPaging(r)
+	entries, err := gh.db.GetAuditEntries(groupID, aq, p.page, p.limit)
+	if err != nil {
+		gh.log("GetGroupAudit", err.Error())
+		SendErrorOf(rw, err, "cannot find", http.StatusNotFound)
+		return
+	}
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(entries); err != nil {
+		gh.log("GetGroupAudit", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	gh.log("GetGroupAudit", "SUCCESS")
+}
+
+// GetGroupTrash lists the deleted bundles and cards of the group.
+func (gh *GetHandler) GetGroupTrash(rw http.ResponseWriter, r *http.Request) {
+	groupID, err := getParam("groupID", r)
+	if err != nil {
+		gh.log("GetGroupTrash", err.Error())
+		SendError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p := newPaging(r)
+	items, err := gh.db.GetGroupTrash(groupID, p.page, p.limit)
+	if err != nil {
+		gh.log("GetGroupTrash", err.Error())
+		SendErrorOf(rw, err, "cannot find", http.StatusNotFound)
+		return
+	}
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(items); err != nil {
+		gh.log("GetGroupTrash", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	gh.log("GetGroupTrash", "SUCCESS")
+}
+
 // TODO ONLY FOR MEMBERS!! TEST
 func (gh *GetHandler) GetBundle(rw http.ResponseWriter, r *http.Request) {
 	bundleID, err := getParam("bundleID", r)
@@ -154,7 +347,7 @@ func (gh *GetHandler) GetBundle(rw http.ResponseWriter, r *http.Request) {
 	bundle, err := gh.db.GetBundle(bundleID)
 	if err != nil {
 		gh.log("GetBundle", err.Error())
-		SendError(rw, "cannot find", http.StatusNotFound)
+		sendErrorCode(rw, http.StatusNotFound, CodeBundleNotFound, "cannot find")
 		return
 	}
 
@@ -182,7 +375,7 @@ func (gh *GetHandler) GetBundleCards(rw http.ResponseWriter, r *http.Request) {
 	cards, err := gh.db.GetBundleCards(bundleID, p.page, p.limit)
 	if err != nil {
 		gh.log("GetBundleCards", err.Error())
-		SendError(rw, "cannot find", http.StatusNotFound)
+		SendErrorOf(rw, err, "cannot find", http.StatusNotFound)
 		return
 	}
 
@@ -196,6 +389,113 @@ func (gh *GetHandler) GetBundleCards(rw http.ResponseWriter, r *http.Request) {
 	gh.log("GetBundleCards", "SUCCESS")
 }
 
+// ExportCards writes every card of the bundle as CSV or TSV with a header row.
+func (gh *GetHandler) ExportCards(rw http.ResponseWriter, r *http.Request) {
+	bundleID, err := getParam("bundleID", r)
+	if err != nil {
+		gh.log("ExportCards", err.Error())
+		SendError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	delimiter, err := csvDelimiter(r)
+	if err != nil {
+		gh.log("ExportCards format", err.Error())
+		SendError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cards, err := gh.db.GetAllBundleCards(bundleID)
+	if err != nil {
+		gh.log("ExportCards dbGet", err.Error())
+		SendErrorOf(rw, err, "cannot find", http.StatusNotFound)
+		return
+	}
+
+	ext, contentType := "csv", "text/csv"
+	if delimiter == '\t' {
+		ext, contentType = "tsv", "text/tab-separated-values"
+	}
+	rw.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", bundleID, ext))
+	w := csv.NewWriter(rw)
+	w.Comma = delimiter
+	records := [][]string{{"question", "answer"}}
+	for _, c := range cards {
+		records = append(records, []string{c.Question, c.Answer})
+	}
+	if err := w.WriteAll(records); err != nil {
+		gh.log("ExportCards write", err.Error())
+		return
+	}
+
+	gh.log("ExportCards", "SUCCESS")
+}
+
+func (gh *GetHandler) GetCardStats(rw http.ResponseWriter, r *http.Request) {
+	cardID, err := getParam("cardID", r)
+	if err != nil {
+		gh.log("GetCardStats", err.Error())
+		SendError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := principal.UserID(r.Context())
+	if err != nil {
+		gh.log("GetCardStats getIDFromToken", err.Error())
+		SendError(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	stats, err := gh.db.GetCardStats(userID, cardID)
+	if err != nil {
+		gh.log("GetCardStats dbGet", err.Error())
+		SendErrorOf(rw, err, "cannot find", http.StatusNotFound)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(stats); err != nil {
+		gh.log("GetCardStats encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	gh.log("GetCardStats", "SUCCESS")
+}
+
+func (gh *GetHandler) SearchCards(rw http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if len(strings.TrimSpace(query)) == 0 {
+		gh.log("SearchCards", "empty query")
+		sendErrorCode(rw, http.StatusBadRequest, CodeMissingParam, "missing parameter q")
+		return
+	}
+
+	userID, err := principal.UserID(r.Context())
+	if err != nil {
+		gh.log("SearchCards getIDFromToken", err.Error())
+		SendError(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	p := newPaging(r)
+	hits, err := gh.db.SearchCards(userID, query, p.page, p.limit)
+	if err != nil {
+		gh.log("SearchCards dbGet", err.Error())
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(hits); err != nil {
+		gh.log("SearchCards encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	gh.log("SearchCards", "SUCCESS")
+}
+
 func (gh *GetHandler) log(prefix, msg string) {
 	if gh.debugLog != nil {
 		gh.debugLog.Printf("[%s] %s\n", prefix, msg)
@@ -5,6 +5,8 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/ironstone95/FlashQudoV2/database"
 	"github.com/ironstone95/FlashQudoV2/handler/request"
+	"github.com/ironstone95/FlashQudoV2/model"
+	"github.com/ironstone95/FlashQudoV2/principal"
 	"log"
 	"net/http"
 	"os"
@@ -12,11 +14,11 @@ import (
 
 type PatchHandler struct {
 	l        *log.Logger
-	db       *database.Database
+	db       database.Store
 	debugLog *log.Logger
 }
 
-func NewPatchHandler(l *log.Logger, db *database.Database, fullLog bool) *PatchHandler {
+func NewPatchHandler(l *log.Logger, db database.Store, fullLog bool) *PatchHandler {
 	ph := new(PatchHandler)
 	ph.l = l
 	ph.db = db
@@ -28,26 +30,25 @@ func NewPatchHandler(l *log.Logger, db *database.Database, fullLog bool) *PatchH
 
 func (ph *PatchHandler) PatchUser(rw http.ResponseWriter, r *http.Request) {
 	upr := request.UserPatchRequest{}
-	dec := json.NewDecoder(r.Body)
-	if err := dec.Decode(&upr); err != nil {
+	if err := decodeRequest(r, &upr); err != nil {
 		ph.log("PatchUser decode", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
 	pv, err := upr.GetPatchValues()
 	if err != nil {
 		ph.log("PatchUser getPatchValues", err.Error())
-		SendError(rw, "missing field", http.StatusBadRequest)
+		SendErrorOf(rw, err, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	userID, err := ph.db.GetIDFromUsername(mux.Vars(r)["username"])
 	if err != nil {
 		ph.log("PatchUser getIDFromUsername", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
-	tokenUserID, err := ph.db.GetIDFromToken(r.Header.Get("X-Auth-Token"))
+	tokenUserID, err := principal.UserID(r.Context())
 	if err != nil || userID != tokenUserID {
 		if err != nil {
 			ph.log("PatchUser readToken", err.Error())
@@ -61,7 +62,7 @@ func (ph *PatchHandler) PatchUser(rw http.ResponseWriter, r *http.Request) {
 	dbUser, err := ph.db.UpdateUser(userID, pv)
 	if err != nil {
 		ph.log("PatchUser updateDB", err.Error())
-		SendError(rw, "update error", http.StatusBadRequest)
+		SendErrorOf(rw, err, "update error", http.StatusBadRequest)
 		return
 	}
 
@@ -77,24 +78,45 @@ func (ph *PatchHandler) PatchUser(rw http.ResponseWriter, r *http.Request) {
 
 func (ph *PatchHandler) PatchGroup(rw http.ResponseWriter, r *http.Request) {
 	gpr := request.GroupPatchRequest{}
-	dec := json.NewDecoder(r.Body)
-	if err := dec.Decode(&gpr); err != nil {
+	if err := decodeRequest(r, &gpr); err != nil {
 		ph.log("PatchGroup decode", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
 
 	pv, err := gpr.GetPatchValues()
 	if err != nil {
 		ph.log("PatchGroup getPatchValues", err.Error())
-		SendError(rw, "missing field", http.StatusBadRequest)
+		SendErrorOf(rw, err, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	dbGroup, err := ph.db.UpdateGroup(mux.Vars(r)["groupID"], pv)
+	groupID := mux.Vars(r)["groupID"]
+	oldGroup, err := ph.db.GetGroup(map[string]interface{}{"id": groupID})
+	if err != nil {
+		ph.log("PatchGroup getGroup", err.Error())
+		SendErrorOf(rw, err, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	var dbGroup *model.Group
+	err = ph.db.Transaction(func(s database.Store) error {
+		if dbGroup, err = s.UpdateGroup(groupID, pv); err != nil {
+			return err
+		}
+		if oldGroup.Name != dbGroup.Name {
+			if err := audit(s, r, model.AuditEntry{GroupID: groupID, Action: model.AuditGroupRename, TargetType: model.TargetGroup, TargetID: groupID, Before: oldGroup.Name, After: dbGroup.Name}); err != nil {
+				return err
+			}
+		}
+		if oldGroup.Visibility != dbGroup.Visibility {
+			return audit(s, r, model.AuditEntry{GroupID: groupID, Action: model.AuditGroupVisibility, TargetType: model.TargetGroup, TargetID: groupID, Before: oldGroup.Visibility, After: dbGroup.Visibility})
+		}
+		return nil
+	})
 	if err != nil {
 		ph.log("PatchGroup updateDB", err.Error())
-		SendError(rw, "update error", http.StatusBadRequest)
+		SendErrorOf(rw, err, "update error", http.StatusBadRequest)
 		return
 	}
 
@@ -109,23 +131,28 @@ func (ph *PatchHandler) PatchGroup(rw http.ResponseWriter, r *http.Request) {
 
 func (ph *PatchHandler) PatchBundle(rw http.ResponseWriter, r *http.Request) {
 	bpr := request.BundlePatchRequest{}
-	dec := json.NewDecoder(r.Body)
-	if err := dec.Decode(&bpr); err != nil {
+	if err := decodeRequest(r, &bpr); err != nil {
 		ph.log("PatchBundle decode", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
 
 	pv, err := bpr.GetPatchValues()
 	if err != nil {
 		ph.log("PatchBundle getPatchValues", err.Error())
-		SendError(rw, "missing field", http.StatusBadRequest)
+		SendErrorOf(rw, err, "missing field", http.StatusBadRequest)
 		return
 	}
-	dbBundle, err := ph.db.UpdateBundle(mux.Vars(r)["bundleID"], pv)
+	var dbBundle *model.Bundle
+	err = ph.db.Transaction(func(s database.Store) error {
+		if dbBundle, err = s.UpdateBundle(mux.Vars(r)["bundleID"], pv); err != nil {
+			return err
+		}
+		return audit(s, r, model.AuditEntry{GroupID: dbBundle.GroupID, Action: model.AuditBundleUpdate, TargetType: model.TargetBundle, TargetID: dbBundle.ID, After: dbBundle.Title})
+	})
 	if err != nil {
 		ph.log("PatchBundle updateDB", err.Error())
-		SendError(rw, "update error", http.StatusBadRequest)
+		SendErrorOf(rw, err, "update error", http.StatusBadRequest)
 		return
 	}
 
@@ -140,24 +167,36 @@ func (ph *PatchHandler) PatchBundle(rw http.ResponseWriter, r *http.Request) {
 
 func (ph *PatchHandler) PatchCard(rw http.ResponseWriter, r *http.Request) {
 	cpr := request.CardPatchRequest{}
-	dec := json.NewDecoder(r.Body)
-	if err := dec.Decode(&cpr); err != nil {
+	if err := decodeRequest(r, &cpr); err != nil {
 		ph.log("PatchCard decode", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
 
 	pv, err := cpr.GetPatchValues()
 	if err != nil {
 		ph.log("PatchCard getPatchValues", err.Error())
-		SendError(rw, "missing field", http.StatusBadRequest)
+		SendErrorOf(rw, err, "missing field", http.StatusBadRequest)
+		return
+	}
+
+	editorID, err := principal.UserID(r.Context())
+	if err != nil {
+		ph.log("PatchCard Auth", err.Error())
+		SendError(rw, "forbidden", http.StatusForbidden)
 		return
 	}
 
-	dbCard, err := ph.db.UpdateCard(mux.Vars(r)["cardID"], pv)
+	var dbCard *model.Card
+	err = ph.db.Transaction(func(s database.Store) error {
+		if dbCard, err = s.UpdateCard(mux.Vars(r)["cardID"], editorID, pv); err != nil {
+			return err
+		}
+		return audit(s, r, model.AuditEntry{ActorID: editorID, Action: model.AuditCardUpdate, TargetType: model.TargetCard, TargetID: dbCard.ID, After: dbCard.Question})
+	})
 	if err != nil {
 		ph.log("PatchCard updateDB", err.Error())
-		SendError(rw, "update error", http.StatusBadRequest)
+		SendErrorOf(rw, err, "update error", http.StatusBadRequest)
 		return
 	}
 
@@ -172,21 +211,20 @@ func (ph *PatchHandler) PatchCard(rw http.ResponseWriter, r *http.Request) {
 
 func (ph *PatchHandler) PatchMember(rw http.ResponseWriter, r *http.Request) {
 	mpr := request.MemberPatchRequest{}
-	dec := json.NewDecoder(r.Body)
-	if err := dec.Decode(&mpr); err != nil {
+	if err := decodeRequest(r, &mpr); err != nil {
 		ph.log("PatchMember decode", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
 
 	pv, err := mpr.GetPatchValues()
 	if err != nil {
 		ph.log("PatchMember getPatchValues", err.Error())
-		SendError(rw, "missing field", http.StatusBadRequest)
+		SendErrorOf(rw, err, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	requesterID, err := ph.db.GetIDFromToken(r.Header.Get("X-Auth-Token"))
+	requesterID, err := principal.UserID(r.Context())
 	if err != nil {
 		ph.log("PatchMember Auth", err.Error())
 		SendError(rw, "forbidden", http.StatusForbidden)
@@ -194,20 +232,37 @@ func (ph *PatchHandler) PatchMember(rw http.ResponseWriter, r *http.Request) {
 	}
 
 	vars := mux.Vars(r)
-	if isAdmin, err := ph.db.IsAdmin(vars["groupID"], vars["userID"]); err != nil {
-		ph.log("PatchMember isAdmin", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+	requesterRole, targetRole, err := memberRoles(ph.db, vars["groupID"], requesterID, vars["userID"])
+	if err != nil {
+		ph.log("PatchMember memberRoles", err.Error())
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
-	} else if isAdmin && vars["userID"] != requesterID {
-		ph.log("PatchMember requesterCheck", "Requester is not same with admin")
-		SendError(rw, "an admin cannot change other admins status", http.StatusForbidden)
+	} else if len(targetRole) == 0 {
+		ph.log("PatchMember memberRoles", "user is not a member")
+		SendErrorOf(rw, database.ErrMemberNotFound, "cannot find", http.StatusNotFound)
+		return
+	}
+	if err := checkMemberChange(requesterID, vars["userID"], requesterRole, targetRole); err != nil {
+		ph.log("PatchMember requesterCheck", err.Error())
+		SendErrorOf(rw, err, err.Error(), http.StatusForbidden)
+		return
+	}
+	if model.RoleRank(pv["role"].(string)) > model.RoleRank(requesterRole) {
+		ph.log("PatchMember requesterCheck", "role is higher than the role of the requester")
+		SendErrorOf(rw, ErrGrantedRoleTooHigh, ErrGrantedRoleTooHigh.Error(), http.StatusForbidden)
 		return
 	}
 
-	dbMember, err := ph.db.UpdateMember(vars["groupID"], vars["userID"], pv)
+	var dbMember *model.Member
+	err = ph.db.Transaction(func(s database.Store) error {
+		if dbMember, err = s.UpdateMember(vars["groupID"], vars["userID"], pv); err != nil {
+			return err
+		}
+		return audit(s, r, model.AuditEntry{GroupID: vars["groupID"], ActorID: requesterID, Action: model.AuditMemberUpdate, TargetType: model.TargetUser, TargetID: vars["userID"], Before: targetRole, After: dbMember.Role})
+	})
 	if err != nil {
 		ph.log("PatchMember updateDB", err.Error())
-		SendError(rw, "update error", http.StatusBadRequest)
+		SendErrorOf(rw, err, "update error", http.StatusBadRequest)
 		return
 	}
 
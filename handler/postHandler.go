@@ -2,22 +2,31 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 
 	"github.com/gorilla/mux"
+	"github.com/ironstone95/FlashQudoV2/anki"
 	"github.com/ironstone95/FlashQudoV2/database"
 	"github.com/ironstone95/FlashQudoV2/handler/request"
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+	"github.com/ironstone95/FlashQudoV2/principal"
 )
 
+// maxImportSize is the maximum size of an uploaded file in bytes.
+const maxImportSize = 100 << 20
+
 type PostHandler struct {
 	l        *log.Logger
-	db       *database.Database
+	db       database.Store
 	debugLog *log.Logger
 }
 
-func NewPostHandler(l *log.Logger, db *database.Database, fullLog bool) *PostHandler {
+func NewPostHandler(l *log.Logger, db database.Store, fullLog bool) *PostHandler {
 	ph := new(PostHandler)
 	ph.l = l
 	ph.db = db
@@ -29,26 +38,31 @@ func NewPostHandler(l *log.Logger, db *database.Database, fullLog bool) *PostHan
 
 func (ph *PostHandler) InsertBundle(rw http.ResponseWriter, r *http.Request) {
 	bpr := request.BundlePostRequest{}
-	dec := json.NewDecoder(r.Body)
-	if err := dec.Decode(&bpr); err != nil {
+	if err := decodeRequest(r, &bpr); err != nil {
 		ph.log("InsertBundle", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
 
 	b, err := bpr.CreateBundle()
 	if err != nil {
 		ph.log("InsertBundle", err.Error())
-		SendError(rw, "missing field", http.StatusBadRequest)
+		SendErrorOf(rw, err, "missing field", http.StatusBadRequest)
 		return
 	}
 
 	b.GroupID = mux.Vars(r)["groupID"]
 
-	dbBundle, err := ph.db.InsertBundle(b)
+	var dbBundle *model.Bundle
+	err = ph.db.Transaction(func(s database.Store) error {
+		if dbBundle, err = s.InsertBundle(b); err != nil {
+			return err
+		}
+		return audit(s, r, model.AuditEntry{GroupID: dbBundle.GroupID, Action: model.AuditBundleCreate, TargetType: model.TargetBundle, TargetID: dbBundle.ID, After: dbBundle.Title})
+	})
 	if err != nil {
 		ph.log("InsertBundle", err.Error())
-		SendError(rw, "insertion failed", http.StatusBadRequest)
+		SendErrorOf(rw, err, "insertion failed", http.StatusBadRequest)
 		return
 	}
 	enc := json.NewEncoder(rw)
@@ -61,28 +75,182 @@ func (ph *PostHandler) InsertBundle(rw http.ResponseWriter, r *http.Request) {
 	ph.log("InsertBundle", "SUCCESS")
 }
 
+// ImportBundles creates a bundle with its cards for each deck of the uploaded Anki package (form field "file").
+// The package is imported in one transaction, notes whose question already exists in the deck are skipped and
+// notes which are not a valid card are reported as errors.
+func (ph *PostHandler) ImportBundles(rw http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(rw, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		ph.log("ImportBundles parseForm", err.Error())
+		SendError(rw, "bad request", http.StatusBadRequest)
+		return
+	}
+	// the parts larger than the memory limit are in temporary files
+	defer r.MultipartForm.RemoveAll()
+	f, fh, err := r.FormFile("file")
+	if err != nil {
+		ph.log("ImportBundles formFile", err.Error())
+		SendError(rw, "missing field", http.StatusBadRequest)
+		return
+	}
+	defer f.Close()
+
+	decks, err := anki.ReadPackage(f, fh.Size)
+	if err != nil {
+		ph.log("ImportBundles readPackage", err.Error())
+		sendErrorCode(rw, http.StatusUnprocessableEntity, CodeInvalidPackage, "invalid anki package")
+		return
+	}
+
+	groupID := mux.Vars(r)["groupID"]
+	var imported []response.ImportedBundle
+	err = ph.db.Transaction(func(s database.Store) error {
+		imported = make([]response.ImportedBundle, 0, len(decks))
+		for _, d := range decks {
+			b, err := s.InsertBundle(model.Bundle{Title: d.Name, Description: d.Description, GroupID: groupID})
+			if err != nil {
+				return err
+			}
+			ib := response.ImportedBundle{ID: b.ID, Title: b.Title, Errors: []response.RowError{}}
+			for i, n := range d.Notes {
+				question, answer, err := checkCard(n.Question, n.Answer)
+				if err != nil {
+					ib.Errors = append(ib.Errors, response.RowError{Row: i + 1, Message: err.Error()})
+					continue
+				}
+				_, err = s.InsertCard(model.Card{BundleID: b.ID, Question: question, Answer: answer})
+				if errors.Is(err, database.ErrQuestionExists) {
+					ib.SkippedCount++
+					continue
+				} else if err != nil {
+					return err
+				}
+				ib.CardCount++
+			}
+			imported = append(imported, ib)
+			if err := audit(s, r, model.AuditEntry{GroupID: groupID, Action: model.AuditBundleCreate, TargetType: model.TargetBundle, TargetID: b.ID, After: b.Title}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		ph.log("ImportBundles import", err.Error())
+		SendErrorOf(rw, err, "insertion failed", http.StatusBadRequest)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(imported); err != nil {
+		ph.log("ImportBundles encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	ph.log("ImportBundles", "SUCCESS")
+}
+
+// ImportCards inserts the cards of a CSV or TSV body to the bundle. Query parameter onConflict decides what happens
+// to the cards whose question already exists: skip (default), overwrite or fail. Overwrites are card updates
+// of the requester. A failed import is a conflict error with the rejected rows as details.
+func (ph *PostHandler) ImportCards(rw http.ResponseWriter, r *http.Request) {
+	delimiter, err := csvDelimiter(r)
+	if err != nil {
+		ph.log("ImportCards format", err.Error())
+		SendError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	onConflict := r.URL.Query().Get("onConflict")
+	if len(onConflict) == 0 {
+		onConflict = database.ConflictSkip
+	}
+
+	rows, rowErrors, err := readCardRows(http.MaxBytesReader(rw, r.Body, maxImportSize), delimiter)
+	if err != nil {
+		ph.log("ImportCards read", err.Error())
+		SendError(rw, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	editorID, err := principal.UserID(r.Context())
+	if err != nil {
+		ph.log("ImportCards getIDFromToken", err.Error())
+		SendError(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var res *response.CardImport
+	err = ph.db.Transaction(func(s database.Store) error {
+		if res, err = s.ImportCards(mux.Vars(r)["bundleID"], editorID, rows, onConflict); err != nil {
+			return err
+		}
+		for _, c := range res.Overwritten {
+			if err := audit(s, r, model.AuditEntry{ActorID: editorID, Action: model.AuditCardUpdate, TargetType: model.TargetCard, TargetID: c.ID, After: c.Question}); err != nil {
+				return err
+			}
+		}
+		if res.Inserted == 0 && res.Updated == 0 {
+			return nil
+		}
+		after := fmt.Sprintf("%d inserted, %d updated", res.Inserted, res.Updated)
+		return audit(s, r, model.AuditEntry{Action: model.AuditCardImport, TargetType: model.TargetBundle, TargetID: mux.Vars(r)["bundleID"], After: after})
+	})
+	if err != nil && (res == nil || !errors.Is(err, database.ErrQuestionExists)) {
+		ph.log("ImportCards dbImport", err.Error())
+		SendErrorOf(rw, err, "insertion failed", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		ph.log("ImportCards dbImport", err.Error())
+		details := make([]response.FieldError, 0, len(rowErrors)+len(res.Errors))
+		for _, re := range rowErrors {
+			details = append(details, response.FieldError{Field: fmt.Sprintf("rows[%d]", re.Row), Code: CodeInvalidField, Message: re.Message})
+		}
+		for _, re := range res.Errors {
+			details = append(details, response.FieldError{Field: fmt.Sprintf("rows[%d]", re.Row), Code: CodeQuestionDuplicate, Message: re.Message})
+		}
+		sendErrorCode(rw, http.StatusConflict, CodeImportConflict, "import failed on conflicting rows", details...)
+		return
+	}
+	res.Errors = append(rowErrors, res.Errors...)
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(res); err != nil {
+		ph.log("ImportCards encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	ph.log("ImportCards", "SUCCESS")
+}
+
 func (ph *PostHandler) InsertCard(rw http.ResponseWriter, r *http.Request) {
 	cpr := request.CardPostRequest{}
-	dec := json.NewDecoder(r.Body)
-	if err := dec.Decode(&cpr); err != nil {
+	if err := decodeRequest(r, &cpr); err != nil {
 		ph.log("InsertCard", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
 
 	c, err := cpr.CreateCard()
 	if err != nil {
 		ph.log("InsertCard", err.Error())
-		SendError(rw, "missing field", http.StatusBadRequest)
+		SendErrorOf(rw, err, "missing field", http.StatusBadRequest)
 		return
 	}
 
 	c.BundleID = mux.Vars(r)["bundleID"]
 
-	dbCard, err := ph.db.InsertCard(c)
+	var dbCard *model.Card
+	err = ph.db.Transaction(func(s database.Store) error {
+		if dbCard, err = s.InsertCard(c); err != nil {
+			return err
+		}
+		return audit(s, r, model.AuditEntry{Action: model.AuditCardCreate, TargetType: model.TargetCard, TargetID: dbCard.ID, After: dbCard.Question})
+	})
 	if err != nil {
 		ph.log("InsertCard", err.Error())
-		SendError(rw, "insertion failed", http.StatusBadRequest)
+		SendErrorOf(rw, err, "insertion failed", http.StatusBadRequest)
 		return
 	}
 
@@ -98,21 +266,20 @@ func (ph *PostHandler) InsertCard(rw http.ResponseWriter, r *http.Request) {
 
 func (ph *PostHandler) InsertGroup(rw http.ResponseWriter, r *http.Request) {
 	gpr := request.GroupPostRequest{}
-	dec := json.NewDecoder(r.Body)
-	if err := dec.Decode(&gpr); err != nil {
+	if err := decodeRequest(r, &gpr); err != nil {
 		ph.log("InsertGroup", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
 
 	g, err := gpr.CreateGroup()
 	if err != nil {
 		ph.log("InsertGroup", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	userID, err := ph.db.GetIDFromToken(r.Header.Get("X-Auth-Token"))
+	userID, err := principal.UserID(r.Context())
 	if err != nil {
 		ph.log("InsertGroup", err.Error())
 		SendError(rw, "bad request", http.StatusBadRequest)
@@ -122,7 +289,7 @@ func (ph *PostHandler) InsertGroup(rw http.ResponseWriter, r *http.Request) {
 	dbGroup, err := ph.db.InsertGroup(g, userID)
 	if err != nil {
 		ph.log("InsertGroup", err.Error())
-		SendError(rw, "insertion failed", http.StatusBadRequest)
+		SendErrorOf(rw, err, "insertion failed", http.StatusBadRequest)
 		return
 	}
 
@@ -138,30 +305,40 @@ func (ph *PostHandler) InsertGroup(rw http.ResponseWriter, r *http.Request) {
 
 func (ph *PostHandler) InsertMember(rw http.ResponseWriter, r *http.Request) {
 	mpr := request.MemberPostRequest{}
-	dec := json.NewDecoder(r.Body)
-	if err := dec.Decode(&mpr); err != nil {
+	if err := decodeRequest(r, &mpr); err != nil {
 		ph.log("InsertMember decode", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
 
 	userID, err := ph.db.GetIDFromUsername(*mpr.Username)
 	if err != nil {
 		ph.log("InsertMember getIDFromUsername", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
-	m, err := mpr.CreateMember(userID)
+	m, err := mpr.CreateMember(mux.Vars(r)["groupID"], userID)
 	if err != nil {
 		ph.log("InsertMember mpr.CreateMember", err.Error())
-		SendError(rw, "missing field", http.StatusBadRequest)
+		SendErrorOf(rw, err, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := ph.checkGrantedRole(r, m.GroupID, m.Role); err != nil {
+		ph.log("InsertMember checkGrantedRole", err.Error())
+		SendErrorOf(rw, err, err.Error(), http.StatusForbidden)
 		return
 	}
 
-	dbMember, err := ph.db.InsertMember(m)
+	var dbMember *model.Member
+	err = ph.db.Transaction(func(s database.Store) error {
+		if dbMember, err = s.InsertMember(m); err != nil {
+			return err
+		}
+		return audit(s, r, model.AuditEntry{GroupID: dbMember.GroupID, Action: model.AuditMemberAdd, TargetType: model.TargetUser, TargetID: dbMember.UserID, After: dbMember.Role})
+	})
 	if err != nil {
 		ph.log("InsertMember DB insertion", err.Error())
-		SendError(rw, "insertion failed", http.StatusBadRequest)
+		SendErrorOf(rw, err, "insertion failed", http.StatusBadRequest)
 		return
 	}
 
@@ -175,23 +352,289 @@ func (ph *PostHandler) InsertMember(rw http.ResponseWriter, r *http.Request) {
 	ph.log("InsertMember", "SUCCESS")
 }
 
+func (ph *PostHandler) InsertInvite(rw http.ResponseWriter, r *http.Request) {
+	ipr := request.InvitePostRequest{}
+	if err := decodeRequest(r, &ipr); err != nil {
+		ph.log("InsertInvite decode", err.Error())
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := principal.UserID(r.Context())
+	if err != nil {
+		ph.log("InsertInvite getIDFromToken", err.Error())
+		SendError(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	i, err := ipr.CreateInvite(mux.Vars(r)["groupID"], userID)
+	if err != nil {
+		ph.log("InsertInvite createInvite", err.Error())
+		SendErrorOf(rw, err, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := ph.checkGrantedRole(r, i.GroupID, i.Role); err != nil {
+		ph.log("InsertInvite checkGrantedRole", err.Error())
+		SendErrorOf(rw, err, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	dbInvite, err := ph.db.InsertInvite(i)
+	if err != nil {
+		ph.log("InsertInvite dbInsert", err.Error())
+		SendErrorOf(rw, err, "insertion failed", http.StatusBadRequest)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(dbInvite); err != nil {
+		ph.log("InsertInvite encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	ph.log("InsertInvite", "SUCCESS")
+}
+
+func (ph *PostHandler) AcceptInvite(rw http.ResponseWriter, r *http.Request) {
+	userID, err := principal.UserID(r.Context())
+	if err != nil {
+		ph.log("AcceptInvite getIDFromToken", err.Error())
+		SendError(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var dbMember *model.Member
+	err = ph.db.Transaction(func(s database.Store) error {
+		if dbMember, err = s.AcceptInvite(mux.Vars(r)["code"], userID); err != nil {
+			return err
+		}
+		return audit(s, r, model.AuditEntry{GroupID: dbMember.GroupID, ActorID: userID, Action: model.AuditMemberAdd, TargetType: model.TargetUser, TargetID: userID, After: dbMember.Role})
+	})
+	if err != nil {
+		ph.log("AcceptInvite dbAccept", err.Error())
+		SendErrorOf(rw, err, "insertion failed", http.StatusBadRequest)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(dbMember); err != nil {
+		ph.log("AcceptInvite encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	ph.log("AcceptInvite", "SUCCESS")
+}
+
+// InsertJoinRequest requests to join a discoverable group, or joins an open group directly.
+func (ph *PostHandler) InsertJoinRequest(rw http.ResponseWriter, r *http.Request) {
+	userID, err := principal.UserID(r.Context())
+	if err != nil {
+		ph.log("InsertJoinRequest getIDFromToken", err.Error())
+		SendError(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var jr *model.JoinRequest
+	err = ph.db.Transaction(func(s database.Store) error {
+		if jr, err = s.InsertJoinRequest(mux.Vars(r)["groupID"], userID); err != nil {
+			return err
+		}
+		if jr.Status == model.JoinRequestApproved {
+			return audit(s, r, model.AuditEntry{GroupID: jr.GroupID, ActorID: userID, Action: model.AuditMemberAdd, TargetType: model.TargetUser, TargetID: userID, After: model.RoleViewer})
+		}
+		return nil
+	})
+	if err != nil {
+		ph.log("InsertJoinRequest dbInsert", err.Error())
+		SendErrorOf(rw, err, "insertion failed", http.StatusBadRequest)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(jr); err != nil {
+		ph.log("InsertJoinRequest encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	ph.log("InsertJoinRequest", "SUCCESS")
+}
+
+// TransferOwnership makes another member the owner of the group, the requester becomes an admin.
+func (ph *PostHandler) TransferOwnership(rw http.ResponseWriter, r *http.Request) {
+	opr := request.OwnershipPostRequest{}
+	if err := decodeRequest(r, &opr); err != nil {
+		ph.log("TransferOwnership decode", err.Error())
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
+		return
+	}
+	newOwnerID, err := opr.GetUserID()
+	if err != nil {
+		ph.log("TransferOwnership getUserID", err.Error())
+		SendErrorOf(rw, err, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ownerID, err := principal.UserID(r.Context())
+	if err != nil {
+		ph.log("TransferOwnership getIDFromToken", err.Error())
+		SendError(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+	if ownerID == newOwnerID {
+		ph.log("TransferOwnership requesterCheck", "user is already the owner")
+		SendError(rw, "user is already the owner", http.StatusBadRequest)
+		return
+	}
+
+	var dbMember *model.Member
+	err = ph.db.Transaction(func(s database.Store) error {
+		if dbMember, err = s.TransferOwnership(mux.Vars(r)["groupID"], ownerID, newOwnerID); err != nil {
+			return err
+		}
+		return audit(s, r, model.AuditEntry{GroupID: dbMember.GroupID, ActorID: ownerID, Action: model.AuditOwnerTransfer, TargetType: model.TargetUser, TargetID: newOwnerID, Before: ownerID, After: newOwnerID})
+	})
+	if err != nil {
+		ph.log("TransferOwnership dbTransfer", err.Error())
+		SendErrorOf(rw, err, "update error", http.StatusBadRequest)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(dbMember); err != nil {
+		ph.log("TransferOwnership encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	ph.log("TransferOwnership", "SUCCESS")
+}
+
+// RevertCardRevision sets the question and the answer of the card back to the values before the revision.
+func (ph *PostHandler) RevertCardRevision(rw http.ResponseWriter, r *http.Request) {
+	editorID, err := principal.UserID(r.Context())
+	if err != nil {
+		ph.log("RevertCardRevision getIDFromToken", err.Error())
+		SendError(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	vars := mux.Vars(r)
+	var dbCard *model.Card
+	err = ph.db.Transaction(func(s database.Store) error {
+		if dbCard, err = s.RevertCardRevision(vars["cardID"], vars["revID"], editorID); err != nil {
+			return err
+		}
+		return audit(s, r, model.AuditEntry{ActorID: editorID, Action: model.AuditCardRevert, TargetType: model.TargetCard, TargetID: dbCard.ID, Before: vars["revID"], After: dbCard.Question})
+	})
+	if err != nil {
+		ph.log("RevertCardRevision dbRevert", err.Error())
+		SendErrorOf(rw, err, "revert failed", http.StatusBadRequest)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(dbCard); err != nil {
+		ph.log("RevertCardRevision encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	ph.log("RevertCardRevision", "SUCCESS")
+}
+
+// RestoreTrashItem restores a deleted bundle or card.
+func (ph *PostHandler) RestoreTrashItem(rw http.ResponseWriter, r *http.Request) {
+	var item *response.TrashItem
+	err := ph.db.Transaction(func(s database.Store) (err error) {
+		if item, err = s.RestoreTrashItem(mux.Vars(r)["itemID"]); err != nil {
+			return err
+		}
+		entry := model.AuditEntry{Action: model.AuditCardRestore, TargetType: model.TargetCard, TargetID: item.ID, After: item.Title}
+		if item.Kind == response.TrashBundle {
+			entry.Action, entry.TargetType = model.AuditBundleRestore, model.TargetBundle
+		}
+		return audit(s, r, entry)
+	})
+	if err != nil {
+		ph.log("RestoreTrashItem dbRestore", err.Error())
+		SendErrorOf(rw, err, "restore failed", http.StatusBadRequest)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(item); err != nil {
+		ph.log("RestoreTrashItem encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	ph.log("RestoreTrashItem", "SUCCESS")
+}
+
+func (ph *PostHandler) ApproveJoinRequest(rw http.ResponseWriter, r *http.Request) {
+	ph.decideJoinRequest(rw, r, true)
+}
+
+func (ph *PostHandler) RejectJoinRequest(rw http.ResponseWriter, r *http.Request) {
+	ph.decideJoinRequest(rw, r, false)
+}
+
+func (ph *PostHandler) decideJoinRequest(rw http.ResponseWriter, r *http.Request, approve bool) {
+	deciderID, err := principal.UserID(r.Context())
+	if err != nil {
+		ph.log("decideJoinRequest getIDFromToken", err.Error())
+		SendError(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	vars := mux.Vars(r)
+	var jr *model.JoinRequest
+	err = ph.db.Transaction(func(s database.Store) error {
+		var added bool
+		if jr, added, err = s.DecideJoinRequest(vars["groupID"], vars["requestID"], deciderID, approve); err != nil {
+			return err
+		}
+		// a user who is a member already keeps the role
+		if added {
+			return audit(s, r, model.AuditEntry{GroupID: jr.GroupID, ActorID: deciderID, Action: model.AuditMemberAdd, TargetType: model.TargetUser, TargetID: jr.UserID, After: model.RoleViewer})
+		}
+		return nil
+	})
+	if err != nil {
+		ph.log("decideJoinRequest dbDecide", err.Error())
+		SendErrorOf(rw, err, "update error", http.StatusBadRequest)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(jr); err != nil {
+		ph.log("decideJoinRequest encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	ph.log("decideJoinRequest", "SUCCESS")
+}
+
 func (ph *PostHandler) InsertUser(rw http.ResponseWriter, r *http.Request) {
 	urp := request.UserPostRequest{}
-	dec := json.NewDecoder(r.Body)
-	if err := dec.Decode(&urp); err != nil {
+	if err := decodeRequest(r, &urp); err != nil {
 		ph.log("InsertUser", err.Error())
-		SendError(rw, "bad request", http.StatusBadRequest)
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
 		return
 	}
 
 	u, err := urp.CreateUser()
 	if err != nil {
 		ph.log("InsertUser", err.Error())
-		SendError(rw, "missing field", http.StatusBadRequest)
+		SendErrorOf(rw, err, "missing field", http.StatusBadRequest)
 		return
 	}
 
-	userID, err := ph.db.GetIDFromToken(r.Header.Get("X-Auth-Token"))
+	userID, err := principal.UserID(r.Context())
 	if err != nil {
 		SendError(rw, "forbidden", http.StatusForbidden)
 		return
@@ -202,7 +645,7 @@ func (ph *PostHandler) InsertUser(rw http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		ph.log("InsertUser", err.Error())
-		SendError(rw, "insertion failed", http.StatusBadRequest)
+		SendErrorOf(rw, err, "insertion failed", http.StatusBadRequest)
 		return
 	}
 
@@ -216,6 +659,102 @@ func (ph *PostHandler) InsertUser(rw http.ResponseWriter, r *http.Request) {
 	ph.log("InsertUser", "SUCCESS")
 }
 
+// InsertAccessToken creates a personal access token for the user. Access tokens cannot create other tokens.
+func (ph *PostHandler) InsertAccessToken(rw http.ResponseWriter, r *http.Request) {
+	p, ok := principal.FromContext(r.Context())
+	if !ok || p.IsAccessToken() {
+		ph.log("InsertAccessToken", "requested with an access token")
+		SendError(rw, "access tokens cannot manage access tokens", http.StatusForbidden)
+		return
+	}
+	apr := request.AccessTokenPostRequest{}
+	if err := decodeRequest(r, &apr); err != nil {
+		ph.log("InsertAccessToken decode", err.Error())
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
+		return
+	}
+	t, err := apr.CreateAccessToken(p.UserID)
+	if err != nil {
+		ph.log("InsertAccessToken createAccessToken", err.Error())
+		SendErrorOf(rw, err, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dbToken, err := ph.db.InsertAccessToken(t)
+	if err != nil {
+		ph.log("InsertAccessToken dbInsert", err.Error())
+		SendErrorOf(rw, err, "insertion failed", http.StatusBadRequest)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(dbToken); err != nil {
+		ph.log("InsertAccessToken encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	ph.log("InsertAccessToken", "SUCCESS")
+}
+
+func (ph *PostHandler) InsertReview(rw http.ResponseWriter, r *http.Request) {
+	rpr := request.ReviewPostRequest{}
+	if err := decodeRequest(r, &rpr); err != nil {
+		ph.log("InsertReview decode", err.Error())
+		SendErrorOf(rw, err, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	grade, err := rpr.GetGrade()
+	if err != nil {
+		ph.log("InsertReview getGrade", err.Error())
+		SendErrorOf(rw, err, err.Error(), http.StatusBadRequest)
+		return
+	}
+	responseTime, err := rpr.GetResponseTime()
+	if err != nil {
+		ph.log("InsertReview getResponseTime", err.Error())
+		SendErrorOf(rw, err, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := principal.UserID(r.Context())
+	if err != nil {
+		ph.log("InsertReview getIDFromToken", err.Error())
+		SendError(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	rs, err := ph.db.ReviewCard(userID, mux.Vars(r)["cardID"], grade, responseTime)
+	if err != nil {
+		ph.log("InsertReview reviewCard", err.Error())
+		SendErrorOf(rw, err, "review failed", http.StatusBadRequest)
+		return
+	}
+
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(rs); err != nil {
+		ph.log("InsertReview encode", err.Error())
+		SendError(rw, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	ph.log("InsertReview", "SUCCESS")
+}
+
+// checkGrantedRole returns why the requester cannot grant the role in the group, nil if he/she can.
+func (ph *PostHandler) checkGrantedRole(r *http.Request, groupID, role string) error {
+	requesterID, err := principal.UserID(r.Context())
+	if err != nil {
+		return err
+	}
+	requesterRole, err := ph.db.GetMemberRole(groupID, requesterID)
+	if err != nil || model.RoleRank(role) > model.RoleRank(requesterRole) {
+		return ErrGrantedRoleTooHigh
+	}
+	return nil
+}
+
 func (ph *PostHandler) log(prefix, msg string) {
 	if ph.debugLog != nil {
 		ph.debugLog.Printf("[%s] %s\n", prefix, msg)
@@ -3,3 +3,18 @@ package request
 import "errors"
 
 var ErrMissingField = errors.New("field(s) missing")
+var ErrInvalidGrade = errors.New("grade must be between 0 and 5")
+var ErrInvalidScheduler = errors.New("scheduler must be sm2 or fsrs")
+var ErrInvalidRetention = errors.New("desired retention must be between 0.7 and 0.99")
+var ErrInvalidResponseTime = errors.New("response time cannot be negative")
+var ErrInvalidExpiration = errors.New("expiration date must be in the future")
+var ErrInvalidMaxUses = errors.New("max uses cannot be negative")
+var ErrInvalidVisibility = errors.New("visibility must be private, discoverable or open")
+var ErrInvalidScope = errors.New("scopes must be read or write")
+var ErrInvalidRole = errors.New("role must be admin, editor or viewer")
+var ErrGroupMismatch = errors.New("group must be the group of the URL")
+var ErrBlankField = errors.New("field cannot be blank")
+var ErrTooShort = errors.New("field is too short")
+var ErrTooLong = errors.New("field is too long")
+var ErrInvalidFormat = errors.New("field has an invalid format")
+var ErrInvalidURL = errors.New("URL must be http or https")
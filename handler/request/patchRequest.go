@@ -1,25 +1,30 @@
 package request
 
+import "github.com/ironstone95/FlashQudoV2/model"
+
 type BundlePatchRequest struct {
-	Title       *string `json:"title"`
-	Description *string `json:"description"`
+	Title       *string `json:"title" validate:"trim,notblank,max=200"`
+	Description *string `json:"description" validate:"trim,max=2000"`
 }
 
 type CardPatchRequest struct {
-	Question *string `json:"question"`
-	Answer   *string `json:"answer"`
+	Question *string `json:"question" validate:"trim,notblank,max=1000"`
+	Answer   *string `json:"answer" validate:"trim,max=10000"`
 }
 
 type UserPatchRequest struct {
-	ImageURL *string `json:"imageURL"`
+	ImageURL         *string  `json:"imageURL" validate:"trim,default=Default,max=2048,url"`
+	Scheduler        *string  `json:"scheduler"`        // sm2 or fsrs
+	DesiredRetention *float64 `json:"desiredRetention"` // between 0.7 and 0.99
 }
 
 type GroupPatchRequest struct {
-	Name *string `json:"name"`
+	Name       *string `json:"name" validate:"trim,notblank,max=100"`
+	Visibility *string `json:"visibility"`
 }
 
 type MemberPatchRequest struct {
-	IsAdmin *bool `json:"isAdmin"`
+	Role *string `json:"role"` // admin, editor or viewer
 }
 
 func (bpr *BundlePatchRequest) GetPatchValues() (map[string]interface{}, error) {
@@ -55,29 +60,54 @@ func (cpr *CardPatchRequest) GetPatchValues() (map[string]interface{}, error) {
 }
 
 func (gpr *GroupPatchRequest) GetPatchValues() (map[string]interface{}, error) {
-	if gpr.Name == nil {
+	if gpr.Name == nil && gpr.Visibility == nil {
 		return nil, ErrMissingField
 	}
 	pv := make(map[string]interface{})
-	pv["name"] = *gpr.Name
+	if gpr.Name != nil {
+		pv["name"] = *gpr.Name
+	}
+	if gpr.Visibility != nil {
+		if !isVisibility(*gpr.Visibility) {
+			return nil, ErrInvalidVisibility
+		}
+		pv["visibility"] = *gpr.Visibility
+	}
 	return pv, nil
 }
 
 func (upr *UserPatchRequest) GetPatchValues() (map[string]interface{}, error) {
-	if upr.ImageURL == nil {
+	if upr.ImageURL == nil && upr.Scheduler == nil && upr.DesiredRetention == nil {
 		return nil, ErrMissingField
 	}
 	pv := make(map[string]interface{})
-	pv["image_url"] = *upr.ImageURL
+	if upr.ImageURL != nil {
+		pv["image_url"] = *upr.ImageURL
+	}
+	if upr.Scheduler != nil {
+		if *upr.Scheduler != model.SchedulerSM2 && *upr.Scheduler != model.SchedulerFSRS {
+			return nil, ErrInvalidScheduler
+		}
+		pv["scheduler"] = *upr.Scheduler
+	}
+	if upr.DesiredRetention != nil {
+		if *upr.DesiredRetention < 0.7 || *upr.DesiredRetention > 0.99 {
+			return nil, ErrInvalidRetention
+		}
+		pv["desired_retention"] = *upr.DesiredRetention
+	}
 	return pv, nil
 }
 
 func (mpr *MemberPatchRequest) GetPatchValues() (map[string]interface{}, error) {
-	if mpr.IsAdmin == nil {
+	if mpr.Role == nil {
 		return nil, ErrMissingField
 	}
+	if !isGrantableRole(*mpr.Role) {
+		return nil, ErrInvalidRole
+	}
 
 	pv := make(map[string]interface{})
-	pv["is_admin"] = *mpr.IsAdmin
+	pv["role"] = *mpr.Role
 	return pv, nil
 }
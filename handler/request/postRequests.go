@@ -1,27 +1,53 @@
 package request
 
-import "github.com/ironstone95/FlashQudoV2/model"
+import (
+	"strings"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+// defaultInviteDuration is the duration of an invite without an expiration date.
+const defaultInviteDuration = 7 * 24 * time.Hour
 
 type BundlePostRequest struct {
-	Title       *string `json:"title"`
-	Description *string `json:"description"` // CAN BE NULL
+	Title       *string `json:"title" validate:"required,trim,notblank,max=200"`
+	Description *string `json:"description" validate:"trim,max=2000"` // CAN BE NULL
 }
 
 type CardPostRequest struct {
-	Question *string `json:"question"`
-	Answer   *string `json:"answer"`
+	Question *string `json:"question" validate:"required,trim,notblank,max=1000"`
+	Answer   *string `json:"answer" validate:"required,trim,max=10000"`
 }
 type GroupPostRequest struct {
-	Name *string `json:"name"`
+	Name       *string `json:"name" validate:"required,trim,notblank,max=100"`
+	Visibility *string `json:"visibility"` // CAN BE NULL, private
 }
 type MemberPostRequest struct {
-	GroupID  *string `json:"groupID"`
-	Username *string `json:"username"`
-	IsAdmin  *bool   `json:"isAdmin"`
+	GroupID  *string `json:"groupID"` // CAN BE NULL, the group of the URL
+	Username *string `json:"username" validate:"required,trim"`
+	Role     *string `json:"role"` // CAN BE NULL, viewer
 }
 type UserPostRequest struct {
-	Username *string `json:"username"`
-	ImageURL *string `json:"imageURL"`
+	Username *string `json:"username" validate:"required,trim,min=3,max=32,pattern=username"`
+	ImageURL *string `json:"imageURL" validate:"trim,default=Default,max=2048,url"` // CAN BE NULL, model.DefaultImageURL
+}
+type InvitePostRequest struct {
+	ExpiresAt *time.Time `json:"expiresAt"` // CAN BE NULL, expires in a week
+	MaxUses   *int       `json:"maxUses"`   // CAN BE NULL, unlimited
+	Role      *string    `json:"role"`      // CAN BE NULL, viewer
+}
+type OwnershipPostRequest struct {
+	UserID *string `json:"userID" validate:"required"` // id of the member who becomes the owner
+}
+type AccessTokenPostRequest struct {
+	Name      *string    `json:"name" validate:"required,trim,notblank,max=100"`
+	Scopes    []string   `json:"scopes"`    // read and/or write
+	ExpiresAt *time.Time `json:"expiresAt"` // CAN BE NULL, never expires
+}
+type ReviewPostRequest struct {
+	Grade        *int   `json:"grade" validate:"required"` // 0-5
+	ResponseTime *int64 `json:"responseTime"`              // in milliseconds, CAN BE NULL
 }
 
 // CreateBundle creates bundle if all fields are valid.
@@ -49,24 +75,135 @@ func (gpr *GroupPostRequest) CreateGroup() (model.Group, error) {
 	if gpr.Name == nil {
 		return model.Group{}, ErrMissingField
 	}
-	return model.Group{Name: *gpr.Name}, nil
+	g := model.Group{Name: *gpr.Name, Visibility: model.VisibilityPrivate}
+	if gpr.Visibility != nil {
+		if !isVisibility(*gpr.Visibility) {
+			return model.Group{}, ErrInvalidVisibility
+		}
+		g.Visibility = *gpr.Visibility
+	}
+	return g, nil
+}
+
+func isVisibility(v string) bool {
+	return v == model.VisibilityPrivate || v == model.VisibilityDiscoverable || v == model.VisibilityOpen
 }
 
-func (mpr *MemberPostRequest) CreateMember(userID string) (model.Member, error) {
-	if mpr.GroupID == nil || mpr.Username == nil || mpr.IsAdmin == nil {
+// CreateMember returns the member of the group of the URL, the group of the request must be the same if present.
+func (mpr *MemberPostRequest) CreateMember(groupID, userID string) (model.Member, error) {
+	if mpr.Username == nil {
 		return model.Member{}, ErrMissingField
 	}
+	if mpr.GroupID != nil && *mpr.GroupID != groupID {
+		return model.Member{}, ErrGroupMismatch
+	}
+	role := model.RoleViewer
+	if mpr.Role != nil {
+		if !isGrantableRole(*mpr.Role) {
+			return model.Member{}, ErrInvalidRole
+		}
+		role = *mpr.Role
+	}
 
 	return model.Member{
-		GroupID: *mpr.GroupID,
+		GroupID: groupID,
 		UserID:  userID,
-		IsAdmin: *mpr.IsAdmin,
+		Role:    role,
 	}, nil
 }
 
+// isGrantableRole checks role can be given to a member. Ownership can only be transferred.
+func isGrantableRole(role string) bool {
+	return model.IsRole(role) && role != model.RoleOwner
+}
+
 func (urp *UserPostRequest) CreateUser() (model.User, error) {
-	if urp.Username == nil || urp.ImageURL == nil {
+	if urp.Username == nil {
 		return model.User{}, ErrMissingField
 	}
-	return model.User{Username: *urp.Username, ImageURL: *urp.ImageURL}, nil
+	u := model.User{Username: *urp.Username, ImageURL: model.DefaultImageURL}
+	if urp.ImageURL != nil {
+		u.ImageURL = *urp.ImageURL
+	}
+	return u, nil
+}
+
+// GetGrade returns the grade if it is present and between 0 and 5.
+func (rpr *ReviewPostRequest) GetGrade() (int, error) {
+	if rpr.Grade == nil {
+		return 0, ErrMissingField
+	}
+	if *rpr.Grade < 0 || *rpr.Grade > 5 {
+		return 0, ErrInvalidGrade
+	}
+	return *rpr.Grade, nil
+}
+
+// GetResponseTime returns the response time in milliseconds, 0 if it is not given.
+func (rpr *ReviewPostRequest) GetResponseTime() (int64, error) {
+	if rpr.ResponseTime == nil {
+		return 0, nil
+	}
+	if *rpr.ResponseTime < 0 {
+		return 0, ErrInvalidResponseTime
+	}
+	return *rpr.ResponseTime, nil
+}
+
+// CreateInvite creates an invite of the group if the expiration date is in the future and max uses is not negative.
+func (ipr *InvitePostRequest) CreateInvite(groupID, createdBy string) (model.Invite, error) {
+	i := model.Invite{GroupID: groupID, CreatedBy: createdBy, Role: model.RoleViewer, ExpiresAt: time.Now().Add(defaultInviteDuration)}
+	if ipr.ExpiresAt != nil {
+		if !ipr.ExpiresAt.After(time.Now()) {
+			return model.Invite{}, ErrInvalidExpiration
+		}
+		i.ExpiresAt = *ipr.ExpiresAt
+	}
+	if ipr.MaxUses != nil {
+		if *ipr.MaxUses < 0 {
+			return model.Invite{}, ErrInvalidMaxUses
+		}
+		i.MaxUses = *ipr.MaxUses
+	}
+	if ipr.Role != nil {
+		if !isGrantableRole(*ipr.Role) {
+			return model.Invite{}, ErrInvalidRole
+		}
+		i.Role = *ipr.Role
+	}
+	return i, nil
+}
+
+// GetUserID returns the id of the new owner.
+func (opr *OwnershipPostRequest) GetUserID() (string, error) {
+	if opr.UserID == nil || len(*opr.UserID) == 0 {
+		return "", ErrMissingField
+	}
+	return *opr.UserID, nil
+}
+
+// CreateAccessToken creates a personal access token of the user if it has a name and valid scopes.
+func (apr *AccessTokenPostRequest) CreateAccessToken(userID string) (model.AccessToken, error) {
+	if apr.Name == nil || len(strings.TrimSpace(*apr.Name)) == 0 || len(apr.Scopes) == 0 {
+		return model.AccessToken{}, ErrMissingField
+	}
+	var scopes []string
+	seen := make(map[string]bool)
+	for _, scope := range apr.Scopes {
+		if scope != model.ScopeRead && scope != model.ScopeWrite {
+			return model.AccessToken{}, ErrInvalidScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	t := model.AccessToken{UserID: userID, Name: strings.TrimSpace(*apr.Name), Scopes: strings.Join(scopes, " ")}
+	if apr.ExpiresAt != nil {
+		if !apr.ExpiresAt.After(time.Now()) {
+			return model.AccessToken{}, ErrInvalidExpiration
+		}
+		t.ExpiresAt = apr.ExpiresAt
+	}
+	return t, nil
 }
@@ -0,0 +1,164 @@
+package request
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// patterns are the named patterns of the pattern rule.
+var patterns = map[string]*regexp.Regexp{
+	"username": regexp.MustCompile(`^[A-Za-z0-9_.-]+$`),
+}
+
+// urlSchemes are the schemes allowed by the url rule.
+var urlSchemes = map[string]bool{"http": true, "https": true}
+
+// FieldError is a failed rule of a field of the request. Field is the JSON name of the field.
+type FieldError struct {
+	Field   string
+	Err     error
+	Message string
+}
+
+// ValidationError is the failed rules of a request, at most one for each field.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (ve *ValidationError) Error() string {
+	msgs := make([]string, 0, len(ve.Fields))
+	for _, f := range ve.Fields {
+		msgs = append(msgs, f.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate trims and checks the fields of the request by their validate tags, v must be a pointer to a struct.
+// The rules of a field run in order and stop at the first failure:
+//
+//	required      the field must be present, a string must not be empty
+//	trim          removes the leading and trailing white space of the string
+//	notblank      the string must not be empty or only white space
+//	min=n, max=n  the length of the string in characters
+//	pattern=name  the string must match the named pattern
+//	url           the string must be an absolute URL with an allowed scheme
+//	default=s     an empty string or s itself is set to s and the rules after it are skipped
+//
+// Fields without the required rule are only checked when they are present, the required rule can be anywhere
+// in the rules.
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+	ve := &ValidationError{}
+	for i := 0; i < rt.NumField(); i++ {
+		tag, ok := rt.Field(i).Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+		name := jsonName(rt.Field(i))
+		if msg, err := checkField(rv.Field(i), strings.Split(tag, ",")); err != nil {
+			ve.Fields = append(ve.Fields, FieldError{Field: name, Err: err, Message: name + " " + msg})
+		}
+	}
+	if len(ve.Fields) != 0 {
+		return ve
+	}
+	return nil
+}
+
+// checkField runs the rules on the field, it returns the message and the error of the first failed rule.
+func checkField(fv reflect.Value, rules []string) (string, error) {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			if hasRule(rules, "required") {
+				return "is required", ErrMissingField
+			}
+			return "", nil
+		}
+		fv = fv.Elem()
+	}
+	if fv.Kind() != reflect.String {
+		return "", nil
+	}
+
+	for _, rule := range rules {
+		key, arg := rule, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			key, arg = rule[:i], rule[i+1:]
+		}
+		s := fv.String()
+		switch key {
+		case "required":
+			if len(s) == 0 {
+				return "is required", ErrMissingField
+			}
+		case "trim":
+			fv.SetString(strings.TrimSpace(s))
+		case "notblank":
+			if len(strings.TrimSpace(s)) == 0 {
+				return "cannot be blank", ErrBlankField
+			}
+		case "min":
+			if n := ruleArg(rule, arg); utf8.RuneCountInString(s) < n {
+				return fmt.Sprintf("must be at least %d characters", n), ErrTooShort
+			}
+		case "max":
+			if n := ruleArg(rule, arg); utf8.RuneCountInString(s) > n {
+				return fmt.Sprintf("must be at most %d characters", n), ErrTooLong
+			}
+		case "pattern":
+			p, ok := patterns[arg]
+			if !ok {
+				panic("request: unknown pattern " + arg)
+			}
+			if !p.MatchString(s) {
+				return "has an invalid format", ErrInvalidFormat
+			}
+		case "default":
+			if len(s) == 0 || s == arg {
+				fv.SetString(arg)
+				return "", nil
+			}
+		case "url":
+			u, err := url.Parse(s)
+			if err != nil || !urlSchemes[strings.ToLower(u.Scheme)] || len(u.Host) == 0 {
+				return "must be an http or https URL", ErrInvalidURL
+			}
+		default:
+			panic("request: unknown validation rule " + rule)
+		}
+	}
+	return "", nil
+}
+
+// hasRule reports whether the rules have the rule without an argument.
+func hasRule(rules []string, rule string) bool {
+	for _, r := range rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleArg returns the number argument of the rule.
+func ruleArg(rule, arg string) int {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		panic("request: invalid validation rule " + rule)
+	}
+	return n
+}
+
+// jsonName returns the name of the field in the JSON body.
+func jsonName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("json"), ",")[0]; len(name) != 0 {
+		return name
+	}
+	return f.Name
+}
@@ -0,0 +1,50 @@
+package request
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	type body struct {
+		First *string `json:"first" validate:"required,trim,max=5"`
+		Last  *string `json:"last" validate:"trim,max=5,required"`
+		Image *string `json:"image" validate:"trim,default=Default,url"`
+	}
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name  string
+		body  body
+		err   error  // the error of the failed field, nil if valid
+		field string // the failed field
+		image string // the image after the validation
+	}{
+		{name: "valid", body: body{First: str(" a "), Last: str("b"), Image: str("https://example.com/a.png")}, image: "https://example.com/a.png"},
+		{name: "required first", body: body{Last: str("b")}, err: ErrMissingField, field: "first"},
+		{name: "required last", body: body{First: str("a")}, err: ErrMissingField, field: "last"},
+		{name: "empty required last", body: body{First: str("a"), Last: str(" ")}, err: ErrMissingField, field: "last"},
+		{name: "long", body: body{First: str("abcdef"), Last: str("b")}, err: ErrTooLong, field: "first"},
+		{name: "empty image", body: body{First: str("a"), Last: str("b"), Image: str(" ")}, image: "Default"},
+		{name: "default image", body: body{First: str("a"), Last: str("b"), Image: str("Default")}, image: "Default"},
+		{name: "invalid image", body: body{First: str("a"), Last: str("b"), Image: str("image")}, err: ErrInvalidURL, field: "image"},
+	}
+	for _, tt := range tests {
+		err := Validate(&tt.body)
+		if tt.err == nil {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			} else if tt.body.Image != nil && *tt.body.Image != tt.image {
+				t.Errorf("%s: image %q, want %q", tt.name, *tt.body.Image, tt.image)
+			}
+			continue
+		}
+		var ve *ValidationError
+		if !errors.As(err, &ve) || len(ve.Fields) != 1 {
+			t.Errorf("%s: error %v, want one failed field", tt.name, err)
+			continue
+		}
+		if f := ve.Fields[0]; f.Field != tt.field || f.Err != tt.err {
+			t.Errorf("%s: %s failed with %v, want %s with %v", tt.name, f.Field, f.Err, tt.field, tt.err)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package response
+
+import "time"
+
+type AuditEntry struct {
+	ID            string    `json:"id"`
+	ActorID       string    `json:"actorID"`
+	ActorUsername string    `json:"actorUsername"`
+	Action        string    `json:"action"`
+	TargetType    string    `json:"targetType"`
+	TargetID      string    `json:"targetID"`
+	Before        string    `json:"before,omitempty"`
+	After         string    `json:"after,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
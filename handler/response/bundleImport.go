@@ -0,0 +1,9 @@
+package response
+
+type ImportedBundle struct {
+	ID           string     `json:"id"`
+	Title        string     `json:"title"`
+	CardCount    int        `json:"cardCount"`
+	SkippedCount int        `json:"skippedCount"` // duplicate questions
+	Errors       []RowError `json:"errors"`       // notes which are not a valid card, by their position in the deck
+}
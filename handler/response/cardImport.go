@@ -0,0 +1,17 @@
+package response
+
+import "github.com/ironstone95/FlashQudoV2/model"
+
+type RowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+type CardImport struct {
+	Inserted int        `json:"inserted"`
+	Updated  int        `json:"updated"`
+	Skipped  int        `json:"skipped"`
+	Errors   []RowError `json:"errors"`
+	// Overwritten are the existing cards whose answers the import overwrote, for the audit log
+	Overwritten []model.Card `json:"-"`
+}
@@ -0,0 +1,22 @@
+package response
+
+import (
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/diff"
+)
+
+type CardRevision struct {
+	ID             string    `json:"id"`
+	CardID         string    `json:"cardID"`
+	EditorID       string    `json:"editorID"`
+	EditorUsername string    `json:"editorUsername"`
+	OldQuestion    string    `json:"oldQuestion"`
+	NewQuestion    string    `json:"newQuestion"`
+	OldAnswer      string    `json:"oldAnswer"`
+	NewAnswer      string    `json:"newAnswer"`
+	RevertOf       *string   `json:"revertOf,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	QuestionDiff   []diff.Op `gorm:"-" json:"questionDiff"`
+	AnswerDiff     []diff.Op `gorm:"-" json:"answerDiff"`
+}
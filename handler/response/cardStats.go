@@ -0,0 +1,15 @@
+package response
+
+import "time"
+
+type CardStats struct {
+	CardID         string     `json:"cardID"`
+	Reviews        int        `json:"reviews"`
+	Lapses         int        `json:"lapses"`
+	AverageGrade   float64    `json:"averageGrade"`
+	Retention      float64    `json:"retention"`
+	TimeSpentMs    int64      `json:"timeSpentMs"`
+	Interval       int        `json:"interval"`
+	LastReviewedAt *time.Time `json:"lastReviewedAt,omitempty"`
+	DueAt          *time.Time `json:"dueAt,omitempty"`
+}
@@ -0,0 +1,21 @@
+package response
+
+import "time"
+
+type DueCard struct {
+	ID          string     `json:"id"`
+	BundleID    string     `json:"bundleID"`
+	GroupID     string     `json:"groupID"`
+	Question    string     `json:"question"`
+	Answer      string     `json:"answer"`
+	IsNew       bool       `json:"isNew"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	Interval    int        `json:"interval"`
+	Repetitions int        `json:"repetitions"`
+}
+
+type StudyQueue struct {
+	NewCount    int       `json:"newCount"`
+	ReviewCount int       `json:"reviewCount"`
+	Cards       []DueCard `json:"cards"`
+}
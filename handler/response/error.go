@@ -1,6 +1,17 @@
 package response
 
+// Error is the body of every error response. Code is stable for programs to check, Message is for people
+// and can change.
 type Error struct {
-	Code    int    `json:"code"`
+	Status  int          `json:"status"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// FieldError is the problem of a field of the request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
 	Message string `json:"message"`
 }
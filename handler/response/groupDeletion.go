@@ -0,0 +1,14 @@
+package response
+
+// GroupDeletion is the number of the rows removed with the group.
+type GroupDeletion struct {
+	GroupID       string `json:"groupID"`
+	Bundles       int64  `json:"bundles"`
+	Cards         int64  `json:"cards"`
+	ReviewStates  int64  `json:"reviewStates"`
+	ReviewLogs    int64  `json:"reviewLogs"`
+	CardRevisions int64  `json:"cardRevisions"`
+	Members       int64  `json:"members"`
+	Invites       int64  `json:"invites"`
+	JoinRequests  int64  `json:"joinRequests"`
+}
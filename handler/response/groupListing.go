@@ -0,0 +1,8 @@
+package response
+
+type GroupListing struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Visibility  string `json:"visibility"`
+	MemberCount int    `json:"memberCount"`
+}
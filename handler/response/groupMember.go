@@ -6,6 +6,6 @@ type GroupMember struct {
 	ID          string    `json:"id"`
 	Username    string    `json:"username"`
 	ImageURL    string    `json:"imageUrl"`
-	IsAdmin     bool      `json:"isAdmin"`
+	Role        string    `json:"role"`
 	MemberSince time.Time `json:"memberSince"`
 }
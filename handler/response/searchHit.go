@@ -0,0 +1,12 @@
+package response
+
+type SearchHit struct {
+	CardID          string  `json:"cardID"`
+	BundleID        string  `json:"bundleID"`
+	BundleTitle     string  `json:"bundleTitle"`
+	GroupID         string  `json:"groupID"`
+	GroupName       string  `json:"groupName"`
+	QuestionSnippet string  `json:"questionSnippet"` // matches are wrapped in <mark></mark>
+	AnswerSnippet   string  `json:"answerSnippet"`
+	Rank            float64 `json:"rank"`
+}
@@ -0,0 +1,20 @@
+package response
+
+import "time"
+
+// kinds of the items in the trash
+const (
+	TrashBundle = "bundle"
+	TrashCard   = "card"
+)
+
+// TrashItem is a deleted bundle or card. The cards deleted with their bundle are counted in CardCount of the bundle.
+type TrashItem struct {
+	ID        string     `json:"id"`
+	Kind      string     `json:"kind"`
+	Title     string     `json:"title"` // title of the bundle or question of the card
+	BundleID  string     `json:"bundleID,omitempty"`
+	CardCount int        `json:"cardCount"`
+	DeletedAt time.Time  `json:"deletedAt"`
+	PurgeAt   *time.Time `json:"purgeAt,omitempty"`
+}
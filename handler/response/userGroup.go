@@ -6,5 +6,5 @@ type UserGroup struct {
 	ID          string    `json:"id"`
 	Name        string    `json:"name"`
 	MemberSince time.Time `json:"memberSince"`
-	IsAdmin     bool      `json:"isAdmin"`
+	Role        string    `json:"role"`
 }
@@ -1,15 +1,27 @@
 package handler
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"github.com/ironstone95/FlashQudoV2/database"
+	"github.com/ironstone95/FlashQudoV2/handler/request"
 	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+	"github.com/ironstone95/FlashQudoV2/principal"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// maxBodySize is the maximum size of a JSON request body in bytes.
+const maxBodySize = 64 << 10
+
 type paging struct {
 	limit int
 	page  int
@@ -44,6 +56,161 @@ func newPaging(r *http.Request) *paging {
 	return p
 }
 
+// newDueQuery reads the study queue options from the query string. Invalid values fall back to the defaults.
+func newDueQuery(r *http.Request) database.DueQuery {
+	q := r.URL.Query()
+	dq := database.DueQuery{
+		GroupID:     q.Get("groupID"),
+		BundleID:    q.Get("bundleID"),
+		NewLimit:    20,
+		ReviewLimit: 200,
+		Interleave:  true,
+	}
+	if newLimit, err := strconv.Atoi(q.Get("newLimit")); err == nil && newLimit >= 0 {
+		dq.NewLimit = newLimit
+	}
+	if reviewLimit, err := strconv.Atoi(q.Get("reviewLimit")); err == nil && reviewLimit >= 0 {
+		dq.ReviewLimit = reviewLimit
+	}
+	if interleave, err := strconv.ParseBool(q.Get("interleave")); err == nil {
+		dq.Interleave = interleave
+	}
+	return dq
+}
+
+// newAuditQuery reads the audit log filters actor (user id), action, from and to (RFC 3339) from the query string.
+func newAuditQuery(r *http.Request) (database.AuditQuery, error) {
+	q := r.URL.Query()
+	aq := database.AuditQuery{ActorID: q.Get("actor"), Action: q.Get("action")}
+	for _, f := range []struct {
+		key string
+		t   **time.Time
+	}{{"from", &aq.From}, {"to", &aq.To}} {
+		if v := q.Get(f.key); len(v) != 0 {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return database.AuditQuery{}, fmt.Errorf("%s must be an RFC 3339 time", f.key)
+			}
+			*f.t = &t
+		}
+	}
+	return aq, nil
+}
+
+// audit records the entry in the audit log of the group, with the principal of the request as the actor
+// if the entry has none. Call it with the store of the transaction of the action: the entry is written inside
+// the transaction, and an error rolls the action back.
+func audit(db database.Store, r *http.Request, entry model.AuditEntry) error {
+	if len(entry.ActorID) == 0 {
+		actorID, err := principal.UserID(r.Context())
+		if err != nil {
+			return err
+		}
+		entry.ActorID = actorID
+	}
+	return db.InsertAuditEntry(entry)
+}
+
+// csvDelimiter returns the delimiter of the format query parameter, csv by default.
+func csvDelimiter(r *http.Request) (rune, error) {
+	switch r.URL.Query().Get("format") {
+	case "", "csv":
+		return ',', nil
+	case "tsv":
+		return '\t', nil
+	}
+	return 0, fmt.Errorf("format must be csv or tsv")
+}
+
+// readCardRows reads question, answer records from a CSV or TSV file. A header row is skipped.
+// Records which are not a valid card are reported as row errors.
+func readCardRows(r io.Reader, delimiter rune) ([]database.CardRow, []response.RowError, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = delimiter
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = delimiter == '\t'
+	var rows []database.CardRow
+	rowErrors := []response.RowError{}
+	for row := 1; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); ok {
+				rowErrors = append(rowErrors, response.RowError{Row: row, Message: err.Error()})
+				continue
+			}
+			return nil, nil, err
+		}
+		if row == 1 && len(record) >= 2 && strings.EqualFold(record[0], "question") && strings.EqualFold(record[1], "answer") {
+			continue
+		}
+		if len(record) < 2 {
+			rowErrors = append(rowErrors, response.RowError{Row: row, Message: "question and answer required"})
+			continue
+		}
+		question, answer, err := checkCard(record[0], record[1])
+		if err != nil {
+			rowErrors = append(rowErrors, response.RowError{Row: row, Message: err.Error()})
+			continue
+		}
+		rows = append(rows, database.CardRow{Row: row, Question: question, Answer: answer})
+	}
+	return rows, rowErrors, nil
+}
+
+// checkCard checks the question and the answer of an imported card by the rules of the card fields, it returns
+// them trimmed. Unlike a new card of the API, an imported card may have an empty answer.
+func checkCard(question, answer string) (string, string, error) {
+	cpr := request.CardPatchRequest{Question: &question, Answer: &answer}
+	if err := request.Validate(&cpr); err != nil {
+		return "", "", err
+	}
+	return *cpr.Question, *cpr.Answer, nil
+}
+
+// memberRoles returns the roles of the requester and the target user in the group, empty for non members.
+func memberRoles(db database.Store, groupID, requesterID, targetID string) (string, string, error) {
+	requesterRole, err := db.GetMemberRole(groupID, requesterID)
+	if err != nil {
+		return "", "", err
+	}
+	targetRole, err := db.GetMemberRole(groupID, targetID)
+	if err != nil {
+		return "", "", err
+	}
+	return requesterRole, targetRole, nil
+}
+
+// checkMemberChange returns why the requester cannot change or remove the target member, nil if he/she can.
+// The owner cannot be changed, members can change themselves and the members with lower roles.
+func checkMemberChange(requesterID, targetID, requesterRole, targetRole string) error {
+	if targetRole == model.RoleOwner {
+		return ErrOwnerUnchangeable
+	}
+	if requesterID != targetID && model.RoleRank(requesterRole) <= model.RoleRank(targetRole) {
+		return ErrMemberRoleTooLow
+	}
+	return nil
+}
+
+// decodeRequest decodes the JSON body of the request into v and validates its fields, v must be a pointer to
+// a request. Bodies larger than maxBodySize are not read.
+func decodeRequest(r *http.Request, v interface{}) error {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxBodySize {
+		return ErrBodyTooLarge
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return err
+	}
+	return request.Validate(v)
+}
+
 func getParam(paramKey string, r *http.Request) (string, error) {
 	vars := mux.Vars(r)
 	if param, ok := vars[paramKey]; ok {
@@ -51,10 +218,3 @@ func getParam(paramKey string, r *http.Request) (string, error) {
 	}
 	return "", fmt.Errorf("parameter does not exist")
 }
-
-func SendError(rw http.ResponseWriter, message string, code int) {
-	err := response.Error{Code: code, Message: message}
-	enc := json.NewEncoder(rw)
-	rw.WriteHeader(err.Code)
-	_ = enc.Encode(err)
-}
@@ -2,102 +2,52 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"net/http"
 	"os"
 
-	firebase "firebase.google.com/go"
-	"github.com/gorilla/mux"
 	"github.com/ironstone95/FlashQudoV2/authentication"
+	"github.com/ironstone95/FlashQudoV2/config"
 	"github.com/ironstone95/FlashQudoV2/database"
-	"github.com/ironstone95/FlashQudoV2/handler"
+	"github.com/ironstone95/FlashQudoV2/server"
 )
 
 func main() {
 	l := log.New(os.Stdout, "BACKEND ", log.Default().Flags())
 	l.SetFlags(log.LstdFlags | log.Lshortfile)
-	db := database.ConnectDB(l, true, true, true, true)
-	ctxApp := context.Background()
-	app, err := firebase.NewApp(ctxApp, nil)
+	cfg, args, err := config.Load(os.Args[1:])
 	if err != nil {
-		log.Fatal(err)
+		l.Fatal(err)
+	}
+	if len(args) != 0 {
+		if args[0] != "migrate" {
+			l.Fatalf("unknown command %q", args[0])
+		}
+		os.Exit(migrate(l, cfg, args[1:]))
 	}
-	ctxClient := context.Background()
-	authClient, err := app.Auth(ctxClient)
+
+	db := database.ConnectDB(l, cfg.Database, cfg.Debug())
+	db.StartTrashPurge(cfg.TrashRetention())
+	db.EnableAuthCache(cfg.AuthCacheTTL())
+	verifier, err := authentication.NewVerifier(context.Background(), cfg.Auth)
 	if err != nil {
 		log.Fatal(err)
 	}
-	auth := authentication.NewAuthenticator(l, db, authClient, true)
-
-	router := mux.NewRouter()
-
-	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "COMING SOON")
-	})
-
-	// GET
-	gr := router.Methods(http.MethodGet).Subrouter()
-	gr.Use(auth.AuthMW)
-	gh := handler.NewGetHandler(l, db, true)
-
-	// Authenticated Access
-	gr.HandleFunc("/users/{username}", gh.GetUser)
-	gr.HandleFunc("/groups/{groupID}", gh.GetGroup)
-
-	// Authorized Access
-	gr.Handle("/groups/{groupID}/users", auth.AuthGroupMemberMW(http.HandlerFunc(gh.GetGroupUsers)))
-	gr.Handle("/groups/{groupID}/bundles", auth.AuthGroupMemberMW(http.HandlerFunc(gh.GetGroupBundles)))
-	gr.Handle("/bundles/{bundleID}", auth.AuthBundleGroupMemberMW(http.HandlerFunc(gh.GetBundle)))
-	gr.Handle("/bundles/{bundleID}/cards", auth.AuthBundleGroupMemberMW(http.HandlerFunc(gh.GetBundleCards)))
-	gr.Handle("/users/{username}/groups", auth.AuthUser(http.HandlerFunc(gh.GetUserGroups)))
+	auth := authentication.NewAuthenticator(l, db, verifier, cfg.Debug())
 
-	// POST
-	pr := router.Methods(http.MethodPost).Subrouter()
-	pr.Use(auth.AuthMW)
-	ph := handler.NewPostHandler(l, db, true)
+	router := server.NewRouter(l, cfg, db, auth)
 
-	// Authenticated Access
-	pr.HandleFunc("/groups", ph.InsertGroup)
-	pr.HandleFunc("/users", ph.InsertUser)
-
-	// Authorized Access
-	pr.Handle("/groups/{groupID}/bundles", auth.AuthGroupAdminMW(http.HandlerFunc(ph.InsertBundle)))
-	pr.Handle("/bundles/{bundleID}/cards", auth.AuthBundleGroupAdminMW(http.HandlerFunc(ph.InsertCard)))
-	pr.Handle("/groups/{groupID}/users", auth.AuthGroupAdminMW(http.HandlerFunc(ph.InsertMember)))
-
-	// Patch
-	paR := router.Methods(http.MethodPatch).Subrouter()
-	paR.Use(auth.AuthMW)
-	paH := handler.NewPatchHandler(l, db, true)
-
-	// Authenticated Access
-	paR.HandleFunc("/users/{username}", paH.PatchUser)
-
-	// Authorized Access
-	paR.Handle("/bundles/{bundleID}", auth.AuthBundleGroupAdminMW(http.HandlerFunc(paH.PatchBundle)))
-	paR.Handle("/cards/{cardID}", auth.AuthCardGroupAdminMW(http.HandlerFunc(paH.PatchCard)))
-	paR.Handle("/groups/{groupID}", auth.AuthGroupAdminMW(http.HandlerFunc(paH.PatchGroup)))
-	paR.Handle("/groups/{groupID}/users/{userID}", auth.AuthGroupAdminMW(http.HandlerFunc(paH.PatchMember)))
-
-	// Delete
-	dr := router.Methods(http.MethodDelete).Subrouter()
-	dh := handler.NewDeleteHandler(l, db, true)
-
-	// Authorized Access
-	dr.Handle("/groups/{groupID}", auth.AuthGroupAdminMW(http.HandlerFunc(dh.DeleteGroup)))
-	dr.Handle("/bundles/{bundleID}", auth.AuthBundleGroupAdminMW(http.HandlerFunc(dh.DeleteBundle)))
-	dr.Handle("/bundles/{bundleID}/cards", auth.AuthBundleGroupAdminMW(http.HandlerFunc(dh.DeleteBundleCards)))
-	dr.Handle("/cards/{cardID}", auth.AuthCardGroupAdminMW(http.HandlerFunc(dh.DeleteCard)))
-	dr.Handle("/groups/{groupID}/users/{userID}", auth.AuthGroupAdminMW(http.HandlerFunc(dh.DeleteMember)))
-
-	server := http.Server{
-		Addr:     ":5000",
+	srv := http.Server{
+		Addr:     cfg.Listen,
 		ErrorLog: l,
 		Handler:  router,
 	}
 
-	err = server.ListenAndServe()
+	if cfg.TLS() {
+		err = srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
 	if err != nil {
 		l.Fatal(err)
 	}
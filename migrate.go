@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/ironstone95/FlashQudoV2/config"
+	"github.com/ironstone95/FlashQudoV2/database"
+)
+
+const migrateUsage = "usage: migrate up | down [steps] | status"
+
+// migrate runs the migrate command, up applies the pending migrations, down rolls back the last one
+// or the given number of migrations and status lists the migrations. Returns the exit code.
+func migrate(l *log.Logger, cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		l.Println(migrateUsage)
+		return 2
+	}
+	dbCfg := cfg.Database
+	dbCfg.Migrate, dbCfg.Reset, dbCfg.Seed = false, false, false
+	db := database.ConnectDB(l, dbCfg, cfg.Debug())
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp()
+		for _, m := range applied {
+			l.Printf("applied %d %s", m.Version, m.Name)
+		}
+		if err != nil {
+			l.Println(err)
+			return 1
+		}
+		if len(applied) == 0 {
+			l.Println("no pending migrations")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				l.Println(migrateUsage)
+				return 2
+			}
+			steps = n
+		}
+		rolledBack, err := db.MigrateDown(steps)
+		for _, m := range rolledBack {
+			l.Printf("rolled back %d %s", m.Version, m.Name)
+		}
+		if err != nil {
+			l.Println(err)
+			return 1
+		}
+	case "status":
+		status, err := db.MigrationStatus()
+		if err != nil {
+			l.Println(err)
+			return 1
+		}
+		for _, m := range status {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = "applied " + m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			l.Printf("%04d %-30s %s", m.Version, m.Name, applied)
+		}
+	default:
+		l.Println(migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// AccessTokenPrefix starts every personal access token, to tell them apart from the ID tokens.
+const AccessTokenPrefix = "fqpat_"
+
+// scopes of a personal access token. read allows the safe methods (GET), write allows every method.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// AccessToken is a long-lived personal access token of a user. Only the SHA-256 hash of the token is stored.
+type AccessToken struct {
+	ID         string     `gorm:"primaryKey" json:"id"`
+	UserID     string     `gorm:"index;not null" json:"userID"`
+	User       User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-" faker:"-"`
+	Name       string     `gorm:"not null" json:"name"`
+	Hash       string     `gorm:"uniqueIndex;not null" json:"-"`
+	Prefix     string     `json:"prefix"`                 // start of the token, to recognize it
+	Scopes     string     `gorm:"not null" json:"scopes"` // space separated
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// Allows checks the scopes of the token allow the HTTP method.
+func (t *AccessToken) Allows(method string) bool {
+	for _, scope := range strings.Fields(t.Scopes) {
+		if scope == ScopeWrite || (scope == ScopeRead && (method == "GET" || method == "HEAD")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+)
+
+// actions recorded in the audit log of a group
+const (
+	AuditMemberAdd       = "member.add"
+	AuditMemberUpdate    = "member.update"
+	AuditMemberRemove    = "member.remove"
+	AuditOwnerTransfer   = "group.transfer"
+	AuditGroupRename     = "group.rename"
+	AuditGroupVisibility = "group.visibility"
+	AuditGroupDelete     = "group.delete"
+	AuditBundleCreate    = "bundle.create"
+	AuditBundleUpdate    = "bundle.update"
+	AuditBundleDelete    = "bundle.delete"
+	AuditBundleRestore   = "bundle.restore"
+	AuditBundleClear     = "bundle.clear"
+	AuditCardCreate      = "card.create"
+	AuditCardImport      = "card.import"
+	AuditCardUpdate      = "card.update"
+	AuditCardRevert      = "card.revert"
+	AuditCardDelete      = "card.delete"
+	AuditCardRestore     = "card.restore"
+)
+
+// types of the targets of the audited actions
+const (
+	TargetUser   = "user"
+	TargetGroup  = "group"
+	TargetBundle = "bundle"
+	TargetCard   = "card"
+)
+
+// AuditEntry is an action of a member in a group. Before and After hold the changed value when there is one,
+// e.g. the role of a member or the name of the group. The entries are kept after the group is deleted.
+type AuditEntry struct {
+	ID         string    `gorm:"primaryKey" json:"id"`
+	GroupID    string    `gorm:"index:ix_audit_group_time;not null" json:"groupID"`
+	ActorID    string    `gorm:"index;not null" json:"actorID"`
+	Action     string    `gorm:"index;not null" json:"action"`
+	TargetType string    `json:"targetType"`
+	TargetID   string    `json:"targetID"`
+	Before     string    `json:"before,omitempty"`
+	After      string    `json:"after,omitempty"`
+	CreatedAt  time.Time `gorm:"index:ix_audit_group_time" json:"createdAt"`
+}
@@ -2,15 +2,18 @@ package model
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Bundle struct {
-	ID          string    `gorm:"primaryKey" json:"id" faker:"uuid_digit"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	GroupID     string    `json:"groupID"`
-	Group       Group     `gorm:"foreignKey:GroupID" json:"-"`
-	CreatedAt   time.Time `json:"createdAt" faker:"-"`
-	UpdatedAt   time.Time `json:"updatedAt" faker:"-"`
-	Cards       []Card    `gorm:"foreignKey:bundle_id" json:"cards,omitempty" faker:"-"`
+	ID          string         `gorm:"primaryKey" json:"id" faker:"uuid_digit"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	GroupID     string         `json:"groupID"`
+	Group       Group          `gorm:"foreignKey:GroupID" json:"-" faker:"-"`
+	CreatedAt   time.Time      `json:"createdAt" faker:"-"`
+	UpdatedAt   time.Time      `json:"updatedAt" faker:"-"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-" faker:"-"`
+	Cards       []Card         `gorm:"foreignKey:bundle_id" json:"cards,omitempty" faker:"-"`
 }
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+// CardRevision is an edit of the question and the answer of a card.
+type CardRevision struct {
+	ID          string    `gorm:"primaryKey" json:"id"`
+	CardID      string    `gorm:"index;not null" json:"cardID"`
+	Card        Card      `gorm:"foreignKey:CardID;constraint:OnDelete:CASCADE" json:"-" faker:"-"`
+	EditorID    string    `json:"editorID"`
+	OldQuestion string    `json:"oldQuestion"`
+	NewQuestion string    `json:"newQuestion"`
+	OldAnswer   string    `json:"oldAnswer"`
+	NewAnswer   string    `json:"newAnswer"`
+	RevertOf    *string   `json:"revertOf,omitempty"` // id of the reverted revision
+	CreatedAt   time.Time `json:"createdAt"`
+}
@@ -4,10 +4,19 @@ import (
 	"time"
 )
 
+// visibilities of a group. Private groups can only be joined by invitation, discoverable groups can be found
+// and joined with a request approved by an admin, open groups can be found and joined directly.
+const (
+	VisibilityPrivate      = "private"
+	VisibilityDiscoverable = "discoverable"
+	VisibilityOpen         = "open"
+)
+
 type Group struct {
-	ID        string    `gorm:"primaryKey" json:"id" faker:"uuid_digit"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"createdAt" faker:"-"`
-	Members   []User    `gorm:"many2many:members" json:"members,omitempty" faker:"-"`
-	Bundles   []Bundle  `gorm:"foreignKey:group_id" json:"bundles,omitempty" faker:"-"`
+	ID         string    `gorm:"primaryKey" json:"id" faker:"uuid_digit"`
+	Name       string    `json:"name"`
+	Visibility string    `gorm:"not null;default:private;index" json:"visibility" faker:"-"`
+	CreatedAt  time.Time `json:"createdAt" faker:"-"`
+	Members    []User    `gorm:"many2many:members" json:"members,omitempty" faker:"-"`
+	Bundles    []Bundle  `gorm:"foreignKey:group_id" json:"bundles,omitempty" faker:"-"`
 }
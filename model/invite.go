@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+)
+
+// Invite lets users join a group with its code until it expires, runs out of uses or gets revoked.
+type Invite struct {
+	ID        string     `gorm:"primaryKey" json:"id" faker:"uuid_digit"`
+	GroupID   string     `gorm:"index;not null" json:"groupID"`
+	Group     Group      `gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE" json:"-" faker:"-"`
+	Code      string     `gorm:"uniqueIndex;not null" json:"code"`
+	CreatedBy string     `json:"createdBy"`
+	Role      string     `gorm:"not null;default:viewer" json:"role"` // granted to the users who accept the invite
+	MaxUses   int        `json:"maxUses"`                             // 0 is unlimited
+	Uses      int        `json:"uses"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt" faker:"-"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+)
+
+// statuses of a join request
+const (
+	JoinRequestPending  = "pending"
+	JoinRequestApproved = "approved"
+	JoinRequestRejected = "rejected"
+)
+
+// JoinRequest is the request of a user to become a member of a discoverable group.
+type JoinRequest struct {
+	ID        string     `gorm:"primaryKey" json:"id" faker:"uuid_digit"`
+	GroupID   string     `gorm:"index;not null" json:"groupID"`
+	Group     Group      `gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE" json:"-" faker:"-"`
+	UserID    string     `gorm:"index;not null" json:"userID"`
+	Status    string     `gorm:"not null" json:"status"`
+	DecidedBy string     `json:"decidedBy,omitempty"`
+	DecidedAt *time.Time `json:"decidedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt" faker:"-"`
+}
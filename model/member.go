@@ -4,9 +4,56 @@ import (
 	"time"
 )
 
+// roles of a member in a group, from the most to the least privileged
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Permission is an action which is allowed to some of the roles of a group.
+type Permission int
+
+const (
+	PermView          Permission = iota // see the group, its members, bundles and cards
+	PermEditContent                     // create, update and delete the bundles and cards
+	PermManageMembers                   // add, update and remove members, manage invites and join requests
+	PermManageGroup                     // change the name and the visibility of the group
+	PermOwnGroup                        // delete the group and transfer its ownership
+)
+
+var roleRanks = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3, RoleOwner: 4}
+
+// permissionRanks is the rank of the least privileged role with the permission.
+var permissionRanks = map[Permission]int{
+	PermView:          roleRanks[RoleViewer],
+	PermEditContent:   roleRanks[RoleEditor],
+	PermManageMembers: roleRanks[RoleAdmin],
+	PermManageGroup:   roleRanks[RoleAdmin],
+	PermOwnGroup:      roleRanks[RoleOwner],
+}
+
+// IsRole checks role is one of the roles of a group.
+func IsRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleRank returns the rank of the role, higher ranks have more permissions. Unknown roles have rank 0.
+func RoleRank(role string) int {
+	return roleRanks[role]
+}
+
+// HasPermission checks the role has the permission.
+func HasPermission(role string, perm Permission) bool {
+	rank, ok := permissionRanks[perm]
+	return ok && RoleRank(role) >= rank
+}
+
 type Member struct {
 	GroupID     string    `gorm:"primaryKey" json:"groupID,omitempty"`
 	UserID      string    `gorm:"primaryKey" json:"userID,omitempty"`
 	MemberSince time.Time `json:"memberSince" faker:"-"`
-	IsAdmin     bool      `json:"isAdmin"`
+	Role        string    `gorm:"not null;default:viewer" json:"role" faker:"-"`
 }
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+)
+
+// schedulers a user can select for the reviews
+const (
+	SchedulerSM2  = "sm2"
+	SchedulerFSRS = "fsrs"
+)
+
+// DefaultRetention is the recall probability FSRS aims for when the user did not choose one.
+const DefaultRetention = 0.9
+
+// ReviewState is the spaced repetition schedule of a card for a single user.
+type ReviewState struct {
+	UserID         string    `gorm:"primaryKey" json:"userID"`
+	CardID         string    `gorm:"primaryKey" json:"cardID"`
+	Card           Card      `gorm:"foreignKey:CardID;constraint:OnDelete:CASCADE" json:"-" faker:"-"`
+	EaseFactor     float64   `gorm:"not null" json:"easeFactor"`
+	Interval       int       `json:"interval"` // in days
+	Repetitions    int       `json:"repetitions"`
+	Lapses         int       `json:"lapses"`
+	Stability      float64   `json:"stability"`  // FSRS only
+	Difficulty     float64   `json:"difficulty"` // FSRS only
+	DueAt          time.Time `gorm:"index" json:"dueAt"`
+	LastReviewedAt time.Time `json:"lastReviewedAt"`
+	UpdatedAt      time.Time `json:"updatedAt" faker:"-"`
+}
+
+// SchedulerParams holds the FSRS parameters of a user which are tuned from the review history of the user.
+type SchedulerParams struct {
+	UserID          string     `gorm:"primaryKey" json:"userID"`
+	StabilityScale  float64    `gorm:"not null;default:1" json:"stabilityScale"`
+	Reviews         int        `json:"-"` // reviews since the last tuning
+	Recalls         int        `json:"-"`
+	PredictedRecall float64    `json:"-"` // sum of the predicted recall probabilities
+	TunedAt         *time.Time `json:"tunedAt"`
+	UpdatedAt       time.Time  `json:"updatedAt" faker:"-"`
+}
+
+// ReviewLog is an immutable record of a single grading of a card by a user.
+type ReviewLog struct {
+	ID             string    `gorm:"primaryKey" json:"id"`
+	UserID         string    `gorm:"index:ix_review_log_user_card;not null" json:"userID"`
+	CardID         string    `gorm:"index:ix_review_log_user_card;not null" json:"cardID"`
+	Card           Card      `gorm:"foreignKey:CardID;constraint:OnDelete:CASCADE" json:"-" faker:"-"`
+	Grade          int       `json:"grade"`
+	ResponseTimeMs int64     `json:"responseTimeMs"`
+	IntervalBefore int       `json:"intervalBefore"` // in days, 0 if the card was never reviewed
+	IntervalAfter  int       `json:"intervalAfter"`  // in days
+	CreatedAt      time.Time `json:"createdAt" faker:"-"`
+}
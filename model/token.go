@@ -1,7 +1,14 @@
 package model
 
+import (
+	"time"
+)
+
+// Token is a verified ID token, cached until it expires. Only the SHA-256 hash of the token is stored.
 type Token struct {
-	Token   string `gorm:"primaryKey"`
-	UserID  string `gorm:"primaryKey;uniqueIndex"`
-	Expires int64
+	Hash      string `gorm:"primaryKey"`
+	UserID    string `gorm:"index;not null"`
+	Expires   int64
+	CheckedAt int64      // last revocation check at the identity provider, in unix seconds
+	RevokedAt *time.Time // logged out
 }
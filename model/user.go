@@ -2,11 +2,17 @@ package model
 
 import "time"
 
+// DefaultImageURL is the image of the users without an image of their own.
+const DefaultImageURL = "Default"
+
 type User struct {
-	ID        string    `gorm:"primaryKey" json:"id" faker:"uuid_digit"`
-	Username  string    `gorm:"uniqueIndex;not null" json:"username" faker:"username"`
-	CreatedAt time.Time `json:"-" faker:"-"`
-	UpdatedAt time.Time `json:"-" faker:"-"`
-	ImageURL  string    `json:"imageURL"`
-	Groups    []Group   `gorm:"many2many:members" faker:"-" json:"groups,omitempty"`
+	ID               string    `gorm:"primaryKey" json:"id" faker:"uuid_digit"`
+	Username         string    `gorm:"uniqueIndex;not null" json:"username" faker:"username"`
+	CreatedAt        time.Time `json:"-" faker:"-"`
+	UpdatedAt        time.Time `json:"-" faker:"-"`
+	ImageURL         string    `json:"imageURL"`
+	Scheduler        string    `gorm:"not null;default:sm2" json:"scheduler" faker:"-"`
+	DesiredRetention float64   `gorm:"not null;default:0.9" json:"desiredRetention" faker:"-"` // FSRS only
+	TokensValidAfter int64     `gorm:"not null;default:0" json:"-" faker:"-"`                  // ID tokens issued before are rejected, in unix seconds
+	Groups           []Group   `gorm:"many2many:members" faker:"-" json:"groups,omitempty"`
 }
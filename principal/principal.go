@@ -0,0 +1,60 @@
+// Package principal carries the authenticated user of a request in the request context.
+package principal
+
+import (
+	"context"
+	"errors"
+)
+
+// kinds of the credentials of a principal
+const (
+	KindIDToken     = "id_token"
+	KindAccessToken = "access_token"
+)
+
+var ErrNoPrincipal = errors.New("request is not authenticated")
+
+// Principal is the user of a request, resolved once by the authentication middleware.
+// Scopes are the scopes of a personal access token, nil for ID tokens which can do everything.
+// Role is the role of the user in the group of the requested group, bundle, card or trash item.
+// It is set by the authorization middlewares and empty before them.
+type Principal struct {
+	UserID string
+	Kind   string
+	Scopes []string
+	Role   string
+}
+
+// IsAccessToken reports if the principal was authenticated with a personal access token.
+func (p *Principal) IsAccessToken() bool {
+	return p.Kind == KindAccessToken
+}
+
+// WithRole returns a copy of the principal with the role.
+func (p *Principal) WithRole(role string) *Principal {
+	cp := *p
+	cp.Role = role
+	return &cp
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx which carries the principal.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal of ctx.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// UserID returns the id of the user of the principal of ctx.
+func UserID(ctx context.Context) (string, error) {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return "", ErrNoPrincipal
+	}
+	return p.UserID, nil
+}
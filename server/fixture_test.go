@@ -0,0 +1,263 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime/multipart"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ironstone95/FlashQudoV2/authentication"
+	"github.com/ironstone95/FlashQudoV2/config"
+	"github.com/ironstone95/FlashQudoV2/database"
+	"github.com/ironstone95/FlashQudoV2/generator"
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+// the users of the fixture, each has the ID token name+"-token"
+const (
+	owner    = "owner"
+	admin    = "admin"
+	admin2   = "admin2"
+	editor   = "editor"
+	viewer   = "viewer"
+	outsider = "outsider"
+	newcomer = "newcomer" // has a valid ID token but no user yet
+)
+
+// the read-scoped access tokens of the fixture, used in place of a user to authenticate with the access token
+const (
+	ownerPAT  = "owner-pat"
+	viewerPAT = "viewer-pat"
+)
+
+// groupRoles are the roles of the users in the group of the fixture. The outsider is not a member.
+var groupRoles = map[string]string{
+	owner:  model.RoleOwner,
+	admin:  model.RoleAdmin,
+	admin2: model.RoleAdmin,
+	editor: model.RoleEditor,
+	viewer: model.RoleViewer,
+}
+
+// the stores the router tests run against
+const (
+	storeSQLite = "sqlite"
+	storeMemory = "memory"
+)
+
+var stores = []string{storeSQLite, storeMemory}
+
+// fixture is a router over an in-memory SQLite database or a Memory store with random data: a private group with a member of
+// every role, a bundle with a revised card, a card in the trash, an invite, a discoverable group with a
+// pending join request of the outsider and read-scoped access tokens of the owner and the viewer.
+type fixture struct {
+	t      *testing.T
+	db     database.Store
+	router *mux.Router
+
+	users         map[string]*model.User
+	group         *model.Group
+	club          *model.Group // discoverable
+	bundle        *model.Bundle
+	card          *model.Card
+	trashedCardID string
+	revisionID    string
+	invite        *model.Invite
+	joinRequestID string
+	accessTokenID string            // the access token of the viewer
+	accessTokens  map[string]string // raw access tokens by ownerPAT and viewerPAT
+}
+
+func newFixture(t *testing.T, store string) *fixture {
+	t.Helper()
+	l := log.New(ioutil.Discard, "", 0)
+	cfg := config.Default()
+	var db database.Store = database.NewMemory()
+	if store == storeSQLite {
+		cfg.Database.Driver = config.DriverSQLite
+		cfg.Database.File = ":memory:"
+		db = database.ConnectDB(l, cfg.Database, false)
+	}
+
+	f := &fixture{t: t, db: db, users: make(map[string]*model.User), accessTokens: make(map[string]string)}
+	tokens := make(map[string]string)
+	for _, name := range []string{owner, admin, admin2, editor, viewer, outsider} {
+		u, err := db.InsertUser(*generator.GetRandomUser())
+		f.check(err)
+		f.users[name] = u
+		tokens[name+"-token"] = u.ID
+	}
+	tokens[newcomer+"-token"] = generator.CreateID()
+	f.load()
+
+	auth := authentication.NewAuthenticator(l, db, authentication.NewStaticVerifier(tokens), false)
+	f.router = NewRouter(l, cfg, db, auth)
+	return f
+}
+
+// load inserts the groups and their content.
+func (f *fixture) load() {
+	var err error
+	f.group, err = f.db.InsertGroup(*generator.GetRandomGroup(), f.id(owner))
+	f.check(err)
+	for name, role := range groupRoles {
+		if role == model.RoleOwner {
+			continue
+		}
+		_, err = f.db.InsertMember(model.Member{GroupID: f.group.ID, UserID: f.id(name), Role: role})
+		f.check(err)
+	}
+
+	f.bundle, err = f.db.InsertBundle(*generator.GetRandomBundle(f.group.ID))
+	f.check(err)
+	f.card, err = f.db.InsertCard(*generator.GetRandomCard(f.bundle.ID))
+	f.check(err)
+	f.card, err = f.db.UpdateCard(f.card.ID, f.id(editor), map[string]interface{}{"answer": f.card.Answer + " revised"})
+	f.check(err)
+	revisions, err := f.db.GetCardRevisions(f.card.ID, 1, 1)
+	f.check(err)
+	f.revisionID = revisions[0].ID
+
+	trashed, err := f.db.InsertCard(*generator.GetRandomCard(f.bundle.ID))
+	f.check(err)
+	f.check(f.db.DeleteCard(trashed.ID))
+	f.trashedCardID = trashed.ID
+
+	f.invite, err = f.db.InsertInvite(model.Invite{GroupID: f.group.ID, CreatedBy: f.id(owner), Role: model.RoleViewer, ExpiresAt: time.Now().Add(time.Hour)})
+	f.check(err)
+
+	club := generator.GetRandomGroup()
+	club.Visibility = model.VisibilityDiscoverable
+	f.club, err = f.db.InsertGroup(*club, f.id(owner))
+	f.check(err)
+	jr, err := f.db.InsertJoinRequest(f.club.ID, f.id(outsider))
+	f.check(err)
+	f.joinRequestID = jr.ID
+
+	at, err := f.db.InsertAccessToken(model.AccessToken{UserID: f.id(viewer), Name: "cli", Scopes: model.ScopeRead})
+	f.check(err)
+	f.accessTokenID = at.ID
+	f.accessTokens[viewerPAT] = at.Token
+	at, err = f.db.InsertAccessToken(model.AccessToken{UserID: f.id(owner), Name: "cli", Scopes: model.ScopeRead})
+	f.check(err)
+	f.accessTokens[ownerPAT] = at.Token
+}
+
+// forEachStore runs the test with a fixture of every store.
+func forEachStore(t *testing.T, test func(t *testing.T, f *fixture)) {
+	for _, store := range stores {
+		store := store
+		t.Run(store, func(t *testing.T) {
+			test(t, newFixture(t, store))
+		})
+	}
+}
+
+func (f *fixture) check(err error) {
+	f.t.Helper()
+	if err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fixture) id(name string) string {
+	return f.users[name].ID
+}
+
+func (f *fixture) username(name string) string {
+	return f.users[name].Username
+}
+
+// do serves the request with the ID token of the user as, or with the access token if as is ownerPAT or viewerPAT.
+// The request has no token if as is empty.
+func (f *fixture) do(method, path, as, contentType, body string) *httptest.ResponseRecorder {
+	var rb io.Reader
+	if len(body) != 0 {
+		rb = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, path, rb)
+	if token, ok := f.accessTokens[as]; ok {
+		r.Header.Set("X-Auth-Token", token)
+	} else if len(as) != 0 {
+		r.Header.Set("X-Auth-Token", as+"-token")
+	}
+	if len(contentType) != 0 {
+		r.Header.Set("Content-Type", contentType)
+	}
+	rw := httptest.NewRecorder()
+	f.router.ServeHTTP(rw, r)
+	return rw
+}
+
+// role returns the role of the user in the group, empty if he/she is not a member.
+func (f *fixture) role(groupID, name string) string {
+	role, err := f.db.GetMemberRole(groupID, f.id(name))
+	f.check(err)
+	return role
+}
+
+// ankiPackage returns a multipart body with an Anki package of one deck and its content type. Each note is a
+// question and an answer.
+func ankiPackage(t *testing.T, deck string, notes [][2]string) (string, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "collection.anki2")
+	sdb, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts := [][]interface{}{
+		{"create table col (decks text)"},
+		{"create table notes (id integer primary key, flds text)"},
+		{"create table cards (id integer primary key, nid integer, did integer, ord integer)"},
+		{"insert into col values (?)", fmt.Sprintf(`{"1": {"name": %q, "desc": ""}}`, deck)},
+	}
+	for i, n := range notes {
+		stmts = append(stmts,
+			[]interface{}{"insert into notes values (?, ?)", i + 1, n[0] + "\x1f" + n[1]},
+			[]interface{}{"insert into cards values (?, ?, 1, 0)", i + 1, i + 1})
+	}
+	for _, stmt := range stmts {
+		if _, err := sdb.Exec(stmt[0].(string), stmt[1:]...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sdb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	col, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "deck.apkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(fw)
+	zf, err := zw.Create("collection.anki2")
+	if err == nil {
+		_, err = zf.Write(col)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = mw.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body.String(), mw.FormDataContentType()
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/ironstone95/FlashQudoV2/authentication"
+	"github.com/ironstone95/FlashQudoV2/config"
+	"github.com/ironstone95/FlashQudoV2/database"
+	"github.com/ironstone95/FlashQudoV2/handler"
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+// NewRouter registers all routes of the API with their authentication and authorization middlewares.
+func NewRouter(l *log.Logger, cfg *config.Config, db database.Store, auth *authentication.Authenticator) *mux.Router {
+	router := mux.NewRouter()
+
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "COMING SOON")
+	})
+	// cache hit/miss counters and runtime stats, only when enabled as they are not authenticated
+	if cfg.DebugVars {
+		router.Handle("/debug/vars", expvar.Handler())
+	}
+
+	// GET
+	gr := router.Methods(http.MethodGet).Subrouter()
+	gr.Use(auth.AuthMW)
+	gh := handler.NewGetHandler(l, db, cfg.Debug())
+
+	// Authenticated Access
+	gr.HandleFunc("/users/{username}", gh.GetUser)
+	gr.HandleFunc("/groups", gh.GetGroups)
+	gr.HandleFunc("/groups/{groupID}", gh.GetGroup)
+	gr.HandleFunc("/search", gh.SearchCards)
+
+	// Authorized Access
+	gr.Handle("/groups/{groupID}/users", auth.AuthGroupMW(model.PermView)(http.HandlerFunc(gh.GetGroupUsers)))
+	gr.Handle("/groups/{groupID}/bundles", auth.AuthGroupMW(model.PermView)(http.HandlerFunc(gh.GetGroupBundles)))
+	gr.Handle("/groups/{groupID}/invites", auth.AuthGroupMW(model.PermManageMembers)(http.HandlerFunc(gh.GetGroupInvites)))
+	gr.Handle("/groups/{groupID}/join-requests", auth.AuthGroupMW(model.PermManageMembers)(http.HandlerFunc(gh.GetGroupJoinRequests)))
+	gr.Handle("/groups/{groupID}/audit", auth.AuthGroupMW(model.PermManageMembers)(http.HandlerFunc(gh.GetGroupAudit)))
+	gr.Handle("/groups/{groupID}/trash", auth.AuthGroupMW(model.PermEditContent)(http.HandlerFunc(gh.GetGroupTrash)))
+	gr.Handle("/bundles/{bundleID}", auth.AuthBundleMW(model.PermView)(http.HandlerFunc(gh.GetBundle)))
+	gr.Handle("/bundles/{bundleID}/cards", auth.AuthBundleMW(model.PermView)(http.HandlerFunc(gh.GetBundleCards)))
+	gr.Handle("/bundles/{bundleID}/cards:export", auth.AuthBundleMW(model.PermView)(http.HandlerFunc(gh.ExportCards)))
+	gr.Handle("/users/{username}/groups", auth.AuthUser(http.HandlerFunc(gh.GetUserGroups)))
+	gr.Handle("/cards/{cardID}/stats", auth.AuthCardMW(model.PermView)(http.HandlerFunc(gh.GetCardStats)))
+	gr.Handle("/cards/{cardID}/revisions", auth.AuthCardMW(model.PermView)(http.HandlerFunc(gh.GetCardRevisions)))
+	gr.Handle("/users/{username}/tokens", auth.AuthUser(http.HandlerFunc(gh.GetAccessTokens)))
+	gr.Handle("/users/{username}/due", auth.AuthUser(http.HandlerFunc(gh.GetDueCards)))
+
+	// POST
+	pr := router.Methods(http.MethodPost).Subrouter()
+	pr.Use(auth.AuthMW)
+	ph := handler.NewPostHandler(l, db, cfg.Debug())
+
+	// Authenticated Access
+	pr.HandleFunc("/groups", ph.InsertGroup)
+	pr.HandleFunc("/users", ph.InsertUser)
+	pr.HandleFunc("/invites/{code}/accept", ph.AcceptInvite)
+	pr.HandleFunc("/groups/{groupID}/join-requests", ph.InsertJoinRequest)
+	pr.HandleFunc("/logout", auth.Logout)
+	pr.HandleFunc("/logout/all", auth.LogoutEverywhere)
+
+	// Authorized Access
+	pr.Handle("/users/{username}/tokens", auth.AuthUser(http.HandlerFunc(ph.InsertAccessToken)))
+	pr.Handle("/groups/{groupID}/bundles", auth.AuthGroupMW(model.PermEditContent)(http.HandlerFunc(ph.InsertBundle)))
+	pr.Handle("/groups/{groupID}/bundles/import", auth.AuthGroupMW(model.PermEditContent)(http.HandlerFunc(ph.ImportBundles)))
+	pr.Handle("/bundles/{bundleID}/cards", auth.AuthBundleMW(model.PermEditContent)(http.HandlerFunc(ph.InsertCard)))
+	pr.Handle("/bundles/{bundleID}/cards:import", auth.AuthBundleMW(model.PermEditContent)(http.HandlerFunc(ph.ImportCards)))
+	pr.Handle("/groups/{groupID}/users", auth.AuthGroupMW(model.PermManageMembers)(http.HandlerFunc(ph.InsertMember)))
+	pr.Handle("/groups/{groupID}/invites", auth.AuthGroupMW(model.PermManageMembers)(http.HandlerFunc(ph.InsertInvite)))
+	pr.Handle("/groups/{groupID}/join-requests/{requestID}/approve", auth.AuthGroupMW(model.PermManageMembers)(http.HandlerFunc(ph.ApproveJoinRequest)))
+	pr.Handle("/groups/{groupID}/join-requests/{requestID}/reject", auth.AuthGroupMW(model.PermManageMembers)(http.HandlerFunc(ph.RejectJoinRequest)))
+	pr.Handle("/groups/{groupID}/transfer-ownership", auth.AuthGroupMW(model.PermOwnGroup)(http.HandlerFunc(ph.TransferOwnership)))
+	pr.Handle("/cards/{cardID}/revisions/{revID}/revert", auth.AuthCardMW(model.PermEditContent)(http.HandlerFunc(ph.RevertCardRevision)))
+	pr.Handle("/trash/{itemID}/restore", auth.AuthTrashMW(model.PermEditContent)(http.HandlerFunc(ph.RestoreTrashItem)))
+	pr.Handle("/cards/{cardID}/reviews", auth.AuthCardMW(model.PermView)(http.HandlerFunc(ph.InsertReview)))
+
+	// Patch
+	paR := router.Methods(http.MethodPatch).Subrouter()
+	paR.Use(auth.AuthMW)
+	paH := handler.NewPatchHandler(l, db, cfg.Debug())
+
+	// Authenticated Access
+	paR.HandleFunc("/users/{username}", paH.PatchUser)
+
+	// Authorized Access
+	paR.Handle("/bundles/{bundleID}", auth.AuthBundleMW(model.PermEditContent)(http.HandlerFunc(paH.PatchBundle)))
+	paR.Handle("/cards/{cardID}", auth.AuthCardMW(model.PermEditContent)(http.HandlerFunc(paH.PatchCard)))
+	paR.Handle("/groups/{groupID}", auth.AuthGroupMW(model.PermManageGroup)(http.HandlerFunc(paH.PatchGroup)))
+	paR.Handle("/groups/{groupID}/users/{userID}", auth.AuthGroupMW(model.PermManageMembers)(http.HandlerFunc(paH.PatchMember)))
+
+	// Delete
+	dr := router.Methods(http.MethodDelete).Subrouter()
+	dr.Use(auth.AuthMW)
+	dh := handler.NewDeleteHandler(l, db, cfg.Debug())
+
+	// Authorized Access
+	dr.Handle("/groups/{groupID}", auth.AuthGroupMW(model.PermOwnGroup)(http.HandlerFunc(dh.DeleteGroup)))
+	dr.Handle("/bundles/{bundleID}", auth.AuthBundleMW(model.PermEditContent)(http.HandlerFunc(dh.DeleteBundle)))
+	dr.Handle("/bundles/{bundleID}/cards", auth.AuthBundleMW(model.PermEditContent)(http.HandlerFunc(dh.DeleteBundleCards)))
+	dr.Handle("/cards/{cardID}", auth.AuthCardMW(model.PermEditContent)(http.HandlerFunc(dh.DeleteCard)))
+	dr.Handle("/groups/{groupID}/users/{userID}", auth.AuthGroupMW(model.PermManageMembers)(http.HandlerFunc(dh.DeleteMember)))
+	dr.Handle("/users/{username}/tokens/{tokenID}", auth.AuthUser(http.HandlerFunc(dh.DeleteAccessToken)))
+	dr.Handle("/groups/{groupID}/invites/{inviteID}", auth.AuthGroupMW(model.PermManageMembers)(http.HandlerFunc(dh.DeleteInvite)))
+
+	return router
+}
@@ -0,0 +1,621 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ironstone95/FlashQudoV2/database"
+	"github.com/ironstone95/FlashQudoV2/handler/response"
+	"github.com/ironstone95/FlashQudoV2/model"
+)
+
+// routeTest is a request to the router and the expected status. Every test gets a new fixture.
+type routeTest struct {
+	name        string
+	method      string
+	path        func(f *fixture) string
+	as          string // the user of the ID token or ownerPAT/viewerPAT, no token if empty
+	contentType string
+	body        func(f *fixture) string
+	setup       func(f *fixture)
+	want        int
+	code        string           // the code of the error response, not checked if empty
+	check       func(f *fixture) // checks the state after a successful request
+}
+
+func runRouteTests(t *testing.T, tests []routeTest) {
+	for _, store := range stores {
+		for _, tt := range tests {
+			store, tt := store, tt
+			t.Run(store+"/"+tt.name, func(t *testing.T) {
+				f := newFixture(t, store)
+				if tt.setup != nil {
+					tt.setup(f)
+				}
+				var body string
+				if tt.body != nil {
+					body = tt.body(f)
+				}
+				path := tt.path(f)
+				rw := f.do(tt.method, path, tt.as, tt.contentType, body)
+				if rw.Code != tt.want {
+					t.Fatalf("%s %s as %q: status %d, want %d, body %s", tt.method, path, tt.as, rw.Code, tt.want, rw.Body)
+				}
+				if len(tt.code) != 0 {
+					var res response.Error
+					if err := json.NewDecoder(rw.Body).Decode(&res); err != nil {
+						t.Fatal(err)
+					}
+					if res.Code != tt.code {
+						t.Fatalf("%s %s as %q: code %q, want %q", tt.method, path, tt.as, res.Code, tt.code)
+					}
+				}
+				if tt.check != nil {
+					tt.check(f)
+				}
+			})
+		}
+	}
+}
+
+func static(path string) func(f *fixture) string {
+	return func(f *fixture) string { return path }
+}
+
+func text(s string) func(f *fixture) string {
+	return static(s)
+}
+
+func userPath(name, suffix string) func(f *fixture) string {
+	return func(f *fixture) string { return "/users/" + f.username(name) + suffix }
+}
+
+func groupPath(suffix string) func(f *fixture) string {
+	return func(f *fixture) string { return "/groups/" + f.group.ID + suffix }
+}
+
+func clubPath(suffix string) func(f *fixture) string {
+	return func(f *fixture) string { return "/groups/" + f.club.ID + suffix }
+}
+
+func bundlePath(suffix string) func(f *fixture) string {
+	return func(f *fixture) string { return "/bundles/" + f.bundle.ID + suffix }
+}
+
+func cardPath(suffix string) func(f *fixture) string {
+	return func(f *fixture) string { return "/cards/" + f.card.ID + suffix }
+}
+
+func memberPath(name string) func(f *fixture) string {
+	return func(f *fixture) string { return "/groups/" + f.group.ID + "/users/" + f.id(name) }
+}
+
+// hasRole checks the role of the user in the group of the fixture, empty for a removed member.
+func hasRole(name, role string) func(f *fixture) {
+	return func(f *fixture) {
+		if got := f.role(f.group.ID, name); got != role {
+			f.t.Errorf("role of %s is %q, want %q", name, got, role)
+		}
+	}
+}
+
+// hasImage checks the image of the user with the username.
+func hasImage(username, image string) func(f *fixture) {
+	return func(f *fixture) {
+		u, err := f.db.GetUser(map[string]interface{}{"username": username})
+		f.check(err)
+		if u.ImageURL != image {
+			f.t.Errorf("image of %s is %q, want %q", username, u.ImageURL, image)
+		}
+	}
+}
+
+func TestGetRoutes(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{name: "root", method: http.MethodGet, path: static("/"), want: http.StatusOK},
+		{name: "user", method: http.MethodGet, path: userPath(outsider, ""), as: viewer, want: http.StatusOK},
+		{name: "user without token", method: http.MethodGet, path: userPath(outsider, ""), want: http.StatusForbidden},
+		{name: "user with unknown token", method: http.MethodGet, path: userPath(outsider, ""), as: "stranger", want: http.StatusForbidden},
+		{name: "unknown user", method: http.MethodGet, path: static("/users/nobody"), as: viewer, want: http.StatusNotFound, code: "user.not_found"},
+		{name: "discoverable groups", method: http.MethodGet, path: static("/groups?query=a"), as: outsider, want: http.StatusOK},
+		{name: "group", method: http.MethodGet, path: groupPath(""), as: viewer, want: http.StatusOK},
+		{name: "unknown group", method: http.MethodGet, path: static("/groups/nothing"), as: viewer, want: http.StatusNotFound, code: "group.not_found"},
+		{name: "search", method: http.MethodGet, path: static("/search?q=a"), as: viewer, want: http.StatusOK},
+		{name: "search without query", method: http.MethodGet, path: static("/search"), as: viewer, want: http.StatusBadRequest, code: "request.missing_param"},
+
+		{name: "group users", method: http.MethodGet, path: groupPath("/users"), as: viewer, want: http.StatusOK},
+		{name: "group users of outsider", method: http.MethodGet, path: groupPath("/users"), as: outsider, want: http.StatusForbidden},
+		{name: "users of unknown group", method: http.MethodGet, path: static("/groups/nothing/users"), as: viewer, want: http.StatusForbidden},
+		{name: "group bundles", method: http.MethodGet, path: groupPath("/bundles"), as: viewer, want: http.StatusOK},
+		{name: "group bundles of outsider", method: http.MethodGet, path: groupPath("/bundles"), as: outsider, want: http.StatusForbidden},
+		{name: "group invites", method: http.MethodGet, path: groupPath("/invites"), as: admin, want: http.StatusOK},
+		{name: "group invites of editor", method: http.MethodGet, path: groupPath("/invites"), as: editor, want: http.StatusForbidden},
+		{name: "join requests", method: http.MethodGet, path: clubPath("/join-requests?status=pending"), as: owner, want: http.StatusOK},
+		{name: "join requests of editor", method: http.MethodGet, path: groupPath("/join-requests"), as: editor, want: http.StatusForbidden},
+		{name: "audit", method: http.MethodGet, path: groupPath("/audit"), as: admin, want: http.StatusOK},
+		{name: "audit with invalid time", method: http.MethodGet, path: groupPath("/audit?from=yesterday"), as: admin, want: http.StatusBadRequest},
+		{name: "audit of editor", method: http.MethodGet, path: groupPath("/audit"), as: editor, want: http.StatusForbidden},
+		{name: "trash", method: http.MethodGet, path: groupPath("/trash"), as: editor, want: http.StatusOK},
+		{name: "trash of viewer", method: http.MethodGet, path: groupPath("/trash"), as: viewer, want: http.StatusForbidden},
+
+		{name: "bundle", method: http.MethodGet, path: bundlePath(""), as: viewer, want: http.StatusOK},
+		{name: "bundle of outsider", method: http.MethodGet, path: bundlePath(""), as: outsider, want: http.StatusForbidden},
+		{name: "unknown bundle", method: http.MethodGet, path: static("/bundles/nothing"), as: viewer, want: http.StatusForbidden},
+		{name: "bundle cards", method: http.MethodGet, path: bundlePath("/cards"), as: viewer, want: http.StatusOK},
+		{name: "bundle cards of outsider", method: http.MethodGet, path: bundlePath("/cards"), as: outsider, want: http.StatusForbidden},
+		{name: "export", method: http.MethodGet, path: bundlePath("/cards:export?format=tsv"), as: viewer, want: http.StatusOK},
+		{name: "export with unknown format", method: http.MethodGet, path: bundlePath("/cards:export?format=xml"), as: viewer, want: http.StatusBadRequest},
+		{name: "export of outsider", method: http.MethodGet, path: bundlePath("/cards:export"), as: outsider, want: http.StatusForbidden},
+		{name: "card revisions", method: http.MethodGet, path: cardPath("/revisions"), as: viewer, want: http.StatusOK},
+		{name: "card revisions of outsider", method: http.MethodGet, path: cardPath("/revisions"), as: outsider, want: http.StatusForbidden},
+		{name: "revisions of unknown card", method: http.MethodGet, path: static("/cards/nothing/revisions"), as: viewer, want: http.StatusForbidden},
+		{name: "card stats", method: http.MethodGet, path: cardPath("/stats"), as: viewer, want: http.StatusOK},
+		{name: "card stats of outsider", method: http.MethodGet, path: cardPath("/stats"), as: outsider, want: http.StatusForbidden},
+
+		{name: "user groups", method: http.MethodGet, path: userPath(viewer, "/groups"), as: viewer, want: http.StatusOK},
+		{name: "user groups of another user", method: http.MethodGet, path: userPath(viewer, "/groups"), as: admin, want: http.StatusForbidden},
+		{name: "groups of unknown user", method: http.MethodGet, path: static("/users/nobody/groups"), as: viewer, want: http.StatusNotFound, code: "user.not_found"},
+		{name: "access tokens", method: http.MethodGet, path: userPath(viewer, "/tokens"), as: viewer, want: http.StatusOK},
+		{name: "access tokens of another user", method: http.MethodGet, path: userPath(viewer, "/tokens"), as: owner, want: http.StatusForbidden},
+		{name: "due cards", method: http.MethodGet, path: userPath(viewer, "/due"), as: viewer, want: http.StatusOK},
+		{name: "due cards of another user", method: http.MethodGet, path: userPath(viewer, "/due"), as: editor, want: http.StatusForbidden},
+	})
+}
+
+func TestPostRoutes(t *testing.T) {
+	csv := "text/csv"
+	runRouteTests(t, []routeTest{
+		{name: "group", method: http.MethodPost, path: static("/groups"), as: outsider, body: text(`{"name":"Group"}`), want: http.StatusOK},
+		{name: "group without name", method: http.MethodPost, path: static("/groups"), as: outsider, body: text(`{}`), want: http.StatusUnprocessableEntity, code: "request.missing_field"},
+		{name: "group with invalid visibility", method: http.MethodPost, path: static("/groups"), as: outsider, body: text(`{"name":"Group","visibility":"public"}`), want: http.StatusUnprocessableEntity, code: "request.invalid_field"},
+		{name: "group without token", method: http.MethodPost, path: static("/groups"), body: text(`{"name":"Group"}`), want: http.StatusForbidden},
+		{name: "user", method: http.MethodPost, path: static("/users"), as: newcomer, body: text(`{"username":"newcomer","imageURL":"https://example.com/avatar.png"}`), want: http.StatusOK},
+		{name: "user without image", method: http.MethodPost, path: static("/users"), as: newcomer, body: text(`{"username":"newcomer"}`), want: http.StatusOK,
+			check: hasImage("newcomer", model.DefaultImageURL)},
+		{name: "user with empty image", method: http.MethodPost, path: static("/users"), as: newcomer, body: text(`{"username":"newcomer","imageURL":" "}`), want: http.StatusOK,
+			check: hasImage("newcomer", model.DefaultImageURL)},
+		{name: "user with default image", method: http.MethodPost, path: static("/users"), as: newcomer, body: text(`{"username":"newcomer","imageURL":"Default"}`), want: http.StatusOK,
+			check: hasImage("newcomer", model.DefaultImageURL)},
+		{name: "existing user", method: http.MethodPost, path: static("/users"), as: viewer, body: text(`{"username":"again","imageURL":"https://example.com/avatar.png"}`), want: http.StatusConflict, code: "user.exists"},
+		{name: "accept invite", method: http.MethodPost, path: func(f *fixture) string { return "/invites/" + f.invite.Code + "/accept" }, as: outsider, want: http.StatusOK,
+			check: hasRole(outsider, model.RoleViewer)},
+		{name: "accept invite as member", method: http.MethodPost, path: func(f *fixture) string { return "/invites/" + f.invite.Code + "/accept" }, as: viewer, want: http.StatusConflict, code: "member.exists"},
+		{name: "accept unknown invite", method: http.MethodPost, path: static("/invites/nothing/accept"), as: outsider, want: http.StatusNotFound, code: "invite.not_found"},
+		{name: "join request", method: http.MethodPost, path: clubPath("/join-requests"), as: editor, want: http.StatusOK},
+		{name: "pending join request", method: http.MethodPost, path: clubPath("/join-requests"), as: outsider, want: http.StatusConflict, code: "join_request.pending"},
+		{name: "join request to private group", method: http.MethodPost, path: groupPath("/join-requests"), as: outsider, want: http.StatusForbidden, code: "group.not_joinable"},
+		{name: "join request to unknown group", method: http.MethodPost, path: static("/groups/nothing/join-requests"), as: outsider, want: http.StatusNotFound},
+		{name: "logout", method: http.MethodPost, path: static("/logout"), as: viewer, want: http.StatusOK},
+		{name: "logout without token", method: http.MethodPost, path: static("/logout"), want: http.StatusForbidden},
+		{name: "logout everywhere", method: http.MethodPost, path: static("/logout/all"), as: viewer, want: http.StatusOK},
+
+		{name: "access token", method: http.MethodPost, path: userPath(viewer, "/tokens"), as: viewer, body: text(`{"name":"ci","scopes":["read"]}`), want: http.StatusOK},
+		{name: "access token with invalid scope", method: http.MethodPost, path: userPath(viewer, "/tokens"), as: viewer, body: text(`{"name":"ci","scopes":["admin"]}`), want: http.StatusUnprocessableEntity, code: "request.invalid_field"},
+		{name: "access token of another user", method: http.MethodPost, path: userPath(viewer, "/tokens"), as: owner, body: text(`{"name":"ci","scopes":["read"]}`), want: http.StatusForbidden},
+		{name: "bundle", method: http.MethodPost, path: groupPath("/bundles"), as: editor, body: text(`{"title":"Bundle"}`), want: http.StatusOK},
+		{name: "bundle without title", method: http.MethodPost, path: groupPath("/bundles"), as: editor, body: text(`{}`), want: http.StatusUnprocessableEntity, code: "request.missing_field"},
+		{name: "bundle of viewer", method: http.MethodPost, path: groupPath("/bundles"), as: viewer, body: text(`{"title":"Bundle"}`), want: http.StatusForbidden},
+		{name: "bundle import without file", method: http.MethodPost, path: groupPath("/bundles/import"), as: editor, want: http.StatusBadRequest},
+		{name: "bundle import of viewer", method: http.MethodPost, path: groupPath("/bundles/import"), as: viewer, want: http.StatusForbidden},
+		{name: "card", method: http.MethodPost, path: bundlePath("/cards"), as: editor, body: text(`{"question":"Question","answer":"Answer"}`), want: http.StatusOK},
+		{name: "card without answer", method: http.MethodPost, path: bundlePath("/cards"), as: editor, body: text(`{"question":"Question"}`), want: http.StatusUnprocessableEntity, code: "request.missing_field"},
+		{name: "card with existing question", method: http.MethodPost, path: bundlePath("/cards"), as: editor,
+			body: func(f *fixture) string { return fmt.Sprintf(`{"question":%q,"answer":"Answer"}`, f.card.Question) }, want: http.StatusConflict, code: "card.question_duplicate"},
+		{name: "card of viewer", method: http.MethodPost, path: bundlePath("/cards"), as: viewer, body: text(`{"question":"Question","answer":"Answer"}`), want: http.StatusForbidden},
+		{name: "card import", method: http.MethodPost, path: bundlePath("/cards:import"), as: editor, contentType: csv, body: text("question,answer\nQuestion,Answer\n"), want: http.StatusOK},
+		{name: "card import with conflict", method: http.MethodPost, path: bundlePath("/cards:import?onConflict=fail"), as: editor, contentType: csv,
+			body: func(f *fixture) string { return fmt.Sprintf("%q,Answer\nNew,Answer\n", f.card.Question) }, want: http.StatusConflict, code: "import.conflict"},
+		{name: "card import with overwrite", method: http.MethodPost, path: bundlePath("/cards:import?onConflict=overwrite"), as: editor, contentType: csv,
+			body: func(f *fixture) string { return fmt.Sprintf("%q,Overwritten\n", f.card.Question) }, want: http.StatusOK,
+			check: func(f *fixture) {
+				revisions, err := f.db.GetCardRevisions(f.card.ID, 1, 10)
+				f.check(err)
+				if len(revisions) != 2 || revisions[0].NewAnswer != "Overwritten" || revisions[0].EditorID != f.id(editor) {
+					f.t.Errorf("revisions %+v, want the overwrite of the editor first", revisions)
+				}
+				entries, err := f.db.GetAuditEntries(f.group.ID, database.AuditQuery{Action: model.AuditCardUpdate}, 1, 10)
+				f.check(err)
+				if len(entries) != 1 || entries[0].TargetID != f.card.ID {
+					f.t.Errorf("audit entries %+v, want the update of the card", entries)
+				}
+			}},
+		{name: "card import with unknown format", method: http.MethodPost, path: bundlePath("/cards:import?format=xml"), as: editor, want: http.StatusBadRequest},
+		{name: "card import of viewer", method: http.MethodPost, path: bundlePath("/cards:import"), as: viewer, contentType: csv, body: text("Question,Answer\n"), want: http.StatusForbidden},
+
+		{name: "member", method: http.MethodPost, path: groupPath("/users"), as: admin,
+			body: func(f *fixture) string {
+				return fmt.Sprintf(`{"groupID":%q,"username":%q,"role":"editor"}`, f.group.ID, f.username(outsider))
+			}, want: http.StatusOK, check: hasRole(outsider, model.RoleEditor)},
+		{name: "member as owner", method: http.MethodPost, path: groupPath("/users"), as: admin,
+			body: func(f *fixture) string {
+				return fmt.Sprintf(`{"groupID":%q,"username":%q,"role":"owner"}`, f.group.ID, f.username(outsider))
+			}, want: http.StatusUnprocessableEntity, code: "request.invalid_field"},
+		{name: "unknown member", method: http.MethodPost, path: groupPath("/users"), as: admin,
+			body: func(f *fixture) string { return fmt.Sprintf(`{"groupID":%q,"username":"nobody"}`, f.group.ID) }, want: http.StatusNotFound, code: "user.not_found"},
+		{name: "member without group", method: http.MethodPost, path: groupPath("/users"), as: admin,
+			body: func(f *fixture) string { return fmt.Sprintf(`{"username":%q}`, f.username(outsider)) }, want: http.StatusOK, check: hasRole(outsider, model.RoleViewer)},
+		{name: "member of another group", method: http.MethodPost, path: groupPath("/users"), as: admin,
+			setup: func(f *fixture) {
+				_, err := f.db.InsertMember(model.Member{GroupID: f.club.ID, UserID: f.id(admin), Role: model.RoleViewer})
+				f.check(err)
+			},
+			body: func(f *fixture) string {
+				return fmt.Sprintf(`{"groupID":%q,"username":%q}`, f.club.ID, f.username(outsider))
+			}, want: http.StatusUnprocessableEntity, code: "request.invalid_field",
+			check: func(f *fixture) {
+				if role := f.role(f.club.ID, outsider); len(role) != 0 {
+					f.t.Errorf("outsider is %s of the other group", role)
+				}
+			}},
+		{name: "member of editor", method: http.MethodPost, path: groupPath("/users"), as: editor,
+			body: func(f *fixture) string {
+				return fmt.Sprintf(`{"groupID":%q,"username":%q}`, f.group.ID, f.username(outsider))
+			}, want: http.StatusForbidden},
+		{name: "invite", method: http.MethodPost, path: groupPath("/invites"), as: admin, body: text(`{"maxUses":5,"role":"editor"}`), want: http.StatusOK},
+		{name: "invite with negative uses", method: http.MethodPost, path: groupPath("/invites"), as: admin, body: text(`{"maxUses":-1}`), want: http.StatusUnprocessableEntity, code: "request.invalid_field"},
+		{name: "invite of editor", method: http.MethodPost, path: groupPath("/invites"), as: editor, body: text(`{}`), want: http.StatusForbidden},
+		{name: "approve join request", method: http.MethodPost, path: func(f *fixture) string {
+			return "/groups/" + f.club.ID + "/join-requests/" + f.joinRequestID + "/approve"
+		},
+			as: owner, want: http.StatusOK, check: func(f *fixture) {
+				if got := f.role(f.club.ID, outsider); got != model.RoleViewer {
+					f.t.Errorf("role of the approved user is %q, want %q", got, model.RoleViewer)
+				}
+				entries, err := f.db.GetAuditEntries(f.club.ID, database.AuditQuery{Action: model.AuditMemberAdd}, 1, 10)
+				f.check(err)
+				if len(entries) != 1 || entries[0].TargetID != f.id(outsider) {
+					f.t.Errorf("audit entries %+v, want the addition of the approved user", entries)
+				}
+			}},
+		{name: "approve join request of member", method: http.MethodPost, path: func(f *fixture) string {
+			return "/groups/" + f.club.ID + "/join-requests/" + f.joinRequestID + "/approve"
+		},
+			setup: func(f *fixture) {
+				_, err := f.db.InsertMember(model.Member{GroupID: f.club.ID, UserID: f.id(outsider), Role: model.RoleEditor})
+				f.check(err)
+			},
+			as: owner, want: http.StatusOK, check: func(f *fixture) {
+				if got := f.role(f.club.ID, outsider); got != model.RoleEditor {
+					f.t.Errorf("role of the member is %q, want %q", got, model.RoleEditor)
+				}
+				entries, err := f.db.GetAuditEntries(f.club.ID, database.AuditQuery{Action: model.AuditMemberAdd}, 1, 10)
+				f.check(err)
+				if len(entries) != 0 {
+					f.t.Errorf("audit entries %+v, want none for a member", entries)
+				}
+			}},
+		{name: "reject join request", method: http.MethodPost, path: func(f *fixture) string {
+			return "/groups/" + f.club.ID + "/join-requests/" + f.joinRequestID + "/reject"
+		},
+			as: owner, want: http.StatusOK},
+		{name: "approve unknown join request", method: http.MethodPost, path: clubPath("/join-requests/nothing/approve"), as: owner, want: http.StatusNotFound, code: "join_request.not_found"},
+		{name: "approve join request of non member", method: http.MethodPost, path: func(f *fixture) string {
+			return "/groups/" + f.club.ID + "/join-requests/" + f.joinRequestID + "/approve"
+		},
+			as: admin, want: http.StatusForbidden},
+		{name: "transfer ownership", method: http.MethodPost, path: groupPath("/transfer-ownership"), as: owner,
+			body: func(f *fixture) string { return fmt.Sprintf(`{"userID":%q}`, f.id(admin)) }, want: http.StatusOK,
+			check: func(f *fixture) {
+				hasRole(admin, model.RoleOwner)(f)
+				hasRole(owner, model.RoleAdmin)(f)
+			}},
+		{name: "transfer ownership to the owner", method: http.MethodPost, path: groupPath("/transfer-ownership"), as: owner,
+			body: func(f *fixture) string { return fmt.Sprintf(`{"userID":%q}`, f.id(owner)) }, want: http.StatusBadRequest},
+		{name: "transfer ownership to non member", method: http.MethodPost, path: groupPath("/transfer-ownership"), as: owner,
+			body: func(f *fixture) string { return fmt.Sprintf(`{"userID":%q}`, f.id(outsider)) }, want: http.StatusNotFound},
+		{name: "transfer ownership of admin", method: http.MethodPost, path: groupPath("/transfer-ownership"), as: admin,
+			body: func(f *fixture) string { return fmt.Sprintf(`{"userID":%q}`, f.id(admin)) }, want: http.StatusForbidden},
+		{name: "revert revision", method: http.MethodPost, path: func(f *fixture) string { return "/cards/" + f.card.ID + "/revisions/" + f.revisionID + "/revert" },
+			as: editor, want: http.StatusOK},
+		{name: "revert unknown revision", method: http.MethodPost, path: cardPath("/revisions/nothing/revert"), as: editor, want: http.StatusNotFound, code: "revision.not_found"},
+		{name: "revert revision of viewer", method: http.MethodPost, path: func(f *fixture) string { return "/cards/" + f.card.ID + "/revisions/" + f.revisionID + "/revert" },
+			as: viewer, want: http.StatusForbidden},
+		{name: "restore", method: http.MethodPost, path: func(f *fixture) string { return "/trash/" + f.trashedCardID + "/restore" }, as: editor, want: http.StatusOK},
+		{name: "restore of viewer", method: http.MethodPost, path: func(f *fixture) string { return "/trash/" + f.trashedCardID + "/restore" }, as: viewer, want: http.StatusForbidden},
+		{name: "restore unknown item", method: http.MethodPost, path: static("/trash/nothing/restore"), as: editor, want: http.StatusForbidden},
+		{name: "review", method: http.MethodPost, path: cardPath("/reviews"), as: viewer, body: text(`{"grade":4,"responseTime":1500}`), want: http.StatusOK},
+		{name: "review with invalid grade", method: http.MethodPost, path: cardPath("/reviews"), as: viewer, body: text(`{"grade":9}`), want: http.StatusUnprocessableEntity, code: "request.invalid_field"},
+		{name: "review of outsider", method: http.MethodPost, path: cardPath("/reviews"), as: outsider, body: text(`{"grade":4}`), want: http.StatusForbidden},
+	})
+}
+
+func TestPatchRoutes(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{name: "user", method: http.MethodPatch, path: userPath(viewer, ""), as: viewer, body: text(`{"scheduler":"fsrs"}`), want: http.StatusOK},
+		{name: "user with invalid scheduler", method: http.MethodPatch, path: userPath(viewer, ""), as: viewer, body: text(`{"scheduler":"leitner"}`), want: http.StatusUnprocessableEntity, code: "request.invalid_field"},
+		{name: "another user", method: http.MethodPatch, path: userPath(viewer, ""), as: owner, body: text(`{"scheduler":"fsrs"}`), want: http.StatusForbidden},
+		{name: "unknown user", method: http.MethodPatch, path: static("/users/nobody"), as: viewer, body: text(`{"scheduler":"fsrs"}`), want: http.StatusNotFound, code: "user.not_found"},
+		{name: "bundle", method: http.MethodPatch, path: bundlePath(""), as: editor, body: text(`{"title":"Renamed"}`), want: http.StatusOK},
+		{name: "bundle without fields", method: http.MethodPatch, path: bundlePath(""), as: editor, body: text(`{}`), want: http.StatusUnprocessableEntity, code: "request.missing_field"},
+		{name: "bundle of viewer", method: http.MethodPatch, path: bundlePath(""), as: viewer, body: text(`{"title":"Renamed"}`), want: http.StatusForbidden},
+		{name: "card", method: http.MethodPatch, path: cardPath(""), as: editor, body: text(`{"answer":"Changed"}`), want: http.StatusOK},
+		{name: "card with existing question", method: http.MethodPatch, path: cardPath(""), as: editor, body: text(`{"question":"Taken"}`),
+			setup: func(f *fixture) {
+				_, err := f.db.InsertCard(model.Card{BundleID: f.bundle.ID, Question: "Taken", Answer: "Answer"})
+				f.check(err)
+			}, want: http.StatusConflict},
+		{name: "card of viewer", method: http.MethodPatch, path: cardPath(""), as: viewer, body: text(`{"answer":"Changed"}`), want: http.StatusForbidden},
+		{name: "group", method: http.MethodPatch, path: groupPath(""), as: admin, body: text(`{"name":"Renamed","visibility":"open"}`), want: http.StatusOK},
+		{name: "group with invalid visibility", method: http.MethodPatch, path: groupPath(""), as: admin, body: text(`{"visibility":"public"}`), want: http.StatusUnprocessableEntity, code: "request.invalid_field"},
+		{name: "group of editor", method: http.MethodPatch, path: groupPath(""), as: editor, body: text(`{"name":"Renamed"}`), want: http.StatusForbidden},
+	})
+}
+
+func TestRequestValidation(t *testing.T) {
+	image := `"imageURL":"https://example.com/avatar.png"`
+	runRouteTests(t, []routeTest{
+		{name: "user with short username", method: http.MethodPost, path: static("/users"), as: newcomer, body: text(`{"username":"ab",` + image + `}`),
+			want: http.StatusUnprocessableEntity, code: "request.too_short"},
+		{name: "user with invalid username", method: http.MethodPost, path: static("/users"), as: newcomer, body: text(`{"username":"new comer",` + image + `}`),
+			want: http.StatusUnprocessableEntity, code: "request.invalid_format"},
+		{name: "user with script image", method: http.MethodPost, path: static("/users"), as: newcomer, body: text(`{"username":"newcomer","imageURL":"javascript:alert(1)"}`),
+			want: http.StatusUnprocessableEntity, code: "request.invalid_url"},
+		{name: "image with script", method: http.MethodPatch, path: userPath(viewer, ""), as: viewer, body: text(`{"imageURL":"javascript:alert(1)"}`),
+			want: http.StatusUnprocessableEntity, code: "request.invalid_url"},
+		{name: "group with blank name", method: http.MethodPost, path: static("/groups"), as: outsider, body: text(`{"name":"   "}`),
+			want: http.StatusUnprocessableEntity, code: "request.blank_field"},
+		{name: "card with blank question", method: http.MethodPost, path: bundlePath("/cards"), as: editor, body: text(`{"question":"","answer":"Answer"}`),
+			want: http.StatusUnprocessableEntity, code: "request.missing_field"},
+		{name: "card with long question", method: http.MethodPost, path: bundlePath("/cards"), as: editor,
+			body: text(`{"question":"` + strings.Repeat("q", 1001) + `","answer":"Answer"}`), want: http.StatusUnprocessableEntity, code: "request.too_long"},
+		{name: "card with large body", method: http.MethodPost, path: bundlePath("/cards"), as: editor,
+			body: text(`{"question":"Question","answer":"` + strings.Repeat("a", 64<<10) + `"}`), want: http.StatusRequestEntityTooLarge, code: "request.too_large"},
+		{name: "card with blank question change", method: http.MethodPatch, path: cardPath(""), as: editor, body: text(`{"question":" "}`),
+			want: http.StatusUnprocessableEntity, code: "request.blank_field"},
+		{name: "bundle with padded title", method: http.MethodPatch, path: bundlePath(""), as: editor, body: text(`{"title":"  Renamed  "}`), want: http.StatusOK,
+			check: func(f *fixture) {
+				b, err := f.db.GetBundle(f.bundle.ID)
+				f.check(err)
+				if b.Title != "Renamed" {
+					f.t.Errorf("title is %q, want %q", b.Title, "Renamed")
+				}
+			}},
+	})
+}
+
+func TestValidationDetails(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *fixture) {
+		rw := f.do(http.MethodPost, "/users", newcomer, "", `{"username":"ab","imageURL":"ftp://example.com/a.png"}`)
+		if rw.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("status %d, want %d, body %s", rw.Code, http.StatusUnprocessableEntity, rw.Body)
+		}
+		var res response.Error
+		f.check(json.NewDecoder(rw.Body).Decode(&res))
+		want := []response.FieldError{
+			{Field: "username", Code: "request.too_short", Message: "username must be at least 3 characters"},
+			{Field: "imageURL", Code: "request.invalid_url", Message: "imageURL must be an http or https URL"},
+		}
+		if len(res.Details) != len(want) {
+			t.Fatalf("details %+v, want %+v", res.Details, want)
+		}
+		for i := range want {
+			if res.Details[i] != want[i] {
+				t.Errorf("detail %d is %+v, want %+v", i, res.Details[i], want[i])
+			}
+		}
+	})
+}
+
+// cached enables the role cache of the database and caches the roles of the users in the group of the fixture.
+// Memory has no cache.
+func cached(names ...string) func(f *fixture) {
+	return func(f *fixture) {
+		if db, ok := f.db.(*database.Database); ok {
+			db.EnableAuthCache(time.Minute)
+		}
+		for _, name := range names {
+			f.role(f.group.ID, name)
+		}
+	}
+}
+
+func TestPatchMember(t *testing.T) {
+	role := func(r string) func(f *fixture) string { return text(`{"role":"` + r + `"}`) }
+	runRouteTests(t, []routeTest{
+		{name: "admin demotes editor", method: http.MethodPatch, path: memberPath(editor), as: admin, body: role(model.RoleViewer), want: http.StatusOK,
+			check: hasRole(editor, model.RoleViewer)},
+		{name: "admin promotes viewer to admin", method: http.MethodPatch, path: memberPath(viewer), as: admin, body: role(model.RoleAdmin), want: http.StatusOK,
+			check: hasRole(viewer, model.RoleAdmin)},
+		{name: "admin promotes viewer with cached roles", method: http.MethodPatch, path: memberPath(viewer), as: admin, body: role(model.RoleEditor), want: http.StatusOK,
+			setup: cached(admin, viewer), check: hasRole(viewer, model.RoleEditor)},
+		{name: "admin demotes self", method: http.MethodPatch, path: memberPath(admin), as: admin, body: role(model.RoleEditor), want: http.StatusOK,
+			check: hasRole(admin, model.RoleEditor)},
+		{name: "owner demotes admin", method: http.MethodPatch, path: memberPath(admin), as: owner, body: role(model.RoleViewer), want: http.StatusOK,
+			check: hasRole(admin, model.RoleViewer)},
+		{name: "admin demotes admin", method: http.MethodPatch, path: memberPath(admin2), as: admin, body: role(model.RoleEditor), want: http.StatusForbidden, code: "member.role_too_low",
+			check: hasRole(admin2, model.RoleAdmin)},
+		{name: "admin demotes owner", method: http.MethodPatch, path: memberPath(owner), as: admin, body: role(model.RoleViewer), want: http.StatusForbidden, code: "member.is_owner"},
+		{name: "owner demotes self", method: http.MethodPatch, path: memberPath(owner), as: owner, body: role(model.RoleAdmin), want: http.StatusForbidden},
+		{name: "admin grants ownership", method: http.MethodPatch, path: memberPath(editor), as: admin, body: role(model.RoleOwner), want: http.StatusUnprocessableEntity, code: "request.invalid_field"},
+		{name: "admin without role", method: http.MethodPatch, path: memberPath(editor), as: admin, body: text(`{}`), want: http.StatusUnprocessableEntity, code: "request.missing_field"},
+		{name: "editor demotes viewer", method: http.MethodPatch, path: memberPath(viewer), as: editor, body: role(model.RoleViewer), want: http.StatusForbidden},
+		{name: "admin changes non member", method: http.MethodPatch, path: memberPath(outsider), as: admin, body: role(model.RoleViewer), want: http.StatusNotFound, code: "member.not_found"},
+	})
+}
+
+// audited checks that the group of the fixture has one audit entry of the action.
+func audited(action string) func(f *fixture) {
+	return func(f *fixture) {
+		entries, err := f.db.GetAuditEntries(f.group.ID, database.AuditQuery{Action: action}, 1, 10)
+		f.check(err)
+		if len(entries) != 1 {
+			f.t.Errorf("%d %s audit entries, want 1", len(entries), action)
+		}
+	}
+}
+
+func TestDeleteRoutes(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{name: "group with members", method: http.MethodDelete, path: groupPath(""), as: owner, want: http.StatusConflict, code: "group.not_empty"},
+		{name: "group cascade", method: http.MethodDelete, path: func(f *fixture) string {
+			return "/groups/" + f.group.ID + "?cascade=true&confirm=" + url.QueryEscape(f.group.Name)
+		}, as: owner, want: http.StatusOK, check: audited(model.AuditGroupDelete)},
+		{name: "group cascade without confirmation", method: http.MethodDelete, path: groupPath("?cascade=true&confirm=wrong"), as: owner, want: http.StatusUnprocessableEntity, code: "request.invalid_field"},
+		{name: "group of admin", method: http.MethodDelete, path: groupPath(""), as: admin, want: http.StatusForbidden},
+		{name: "bundle", method: http.MethodDelete, path: bundlePath(""), as: editor, want: http.StatusOK, check: audited(model.AuditBundleDelete)},
+		{name: "bundle of viewer", method: http.MethodDelete, path: bundlePath(""), as: viewer, want: http.StatusForbidden},
+		{name: "bundle cards", method: http.MethodDelete, path: bundlePath("/cards"), as: editor, want: http.StatusOK, check: audited(model.AuditBundleClear)},
+		{name: "bundle cards of viewer", method: http.MethodDelete, path: bundlePath("/cards"), as: viewer, want: http.StatusForbidden},
+		{name: "card", method: http.MethodDelete, path: cardPath(""), as: editor, want: http.StatusOK, check: audited(model.AuditCardDelete)},
+		{name: "card of viewer", method: http.MethodDelete, path: cardPath(""), as: viewer, want: http.StatusForbidden},
+		{name: "card of outsider", method: http.MethodDelete, path: cardPath(""), as: outsider, want: http.StatusForbidden},
+		{name: "access token", method: http.MethodDelete, path: func(f *fixture) string { return "/users/" + f.username(viewer) + "/tokens/" + f.accessTokenID },
+			as: viewer, want: http.StatusOK},
+		{name: "unknown access token", method: http.MethodDelete, path: userPath(viewer, "/tokens/nothing"), as: viewer, want: http.StatusNotFound, code: "access_token.not_found"},
+		{name: "access token of another user", method: http.MethodDelete, path: func(f *fixture) string { return "/users/" + f.username(viewer) + "/tokens/" + f.accessTokenID },
+			as: owner, want: http.StatusForbidden},
+		{name: "invite", method: http.MethodDelete, path: func(f *fixture) string { return "/groups/" + f.group.ID + "/invites/" + f.invite.ID }, as: admin, want: http.StatusOK},
+		{name: "unknown invite", method: http.MethodDelete, path: groupPath("/invites/nothing"), as: admin, want: http.StatusNotFound, code: "invite.not_found"},
+		{name: "invite of editor", method: http.MethodDelete, path: func(f *fixture) string { return "/groups/" + f.group.ID + "/invites/" + f.invite.ID }, as: editor, want: http.StatusForbidden},
+	})
+}
+
+func TestDeleteMember(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{name: "admin removes editor", method: http.MethodDelete, path: memberPath(editor), as: admin, want: http.StatusOK, check: hasRole(editor, "")},
+		{name: "admin removes viewer", method: http.MethodDelete, path: memberPath(viewer), as: admin, want: http.StatusOK, check: hasRole(viewer, "")},
+		{name: "admin removes viewer with cached roles", method: http.MethodDelete, path: memberPath(viewer), as: admin, want: http.StatusOK,
+			setup: cached(admin, viewer), check: hasRole(viewer, "")},
+		{name: "admin removes self", method: http.MethodDelete, path: memberPath(admin), as: admin, want: http.StatusOK, check: hasRole(admin, "")},
+		{name: "owner removes admin", method: http.MethodDelete, path: memberPath(admin), as: owner, want: http.StatusOK, check: hasRole(admin, "")},
+		{name: "admin removes admin", method: http.MethodDelete, path: memberPath(admin2), as: admin, want: http.StatusForbidden, code: "member.role_too_low", check: hasRole(admin2, model.RoleAdmin)},
+		{name: "admin removes owner", method: http.MethodDelete, path: memberPath(owner), as: admin, want: http.StatusForbidden, code: "member.is_owner"},
+		{name: "owner removes self", method: http.MethodDelete, path: memberPath(owner), as: owner, want: http.StatusForbidden},
+		{name: "viewer removes self", method: http.MethodDelete, path: memberPath(viewer), as: viewer, want: http.StatusForbidden},
+		{name: "editor removes viewer", method: http.MethodDelete, path: memberPath(viewer), as: editor, want: http.StatusForbidden},
+		{name: "admin removes non member", method: http.MethodDelete, path: memberPath(outsider), as: admin, want: http.StatusNotFound, code: "member.not_found"},
+	})
+}
+
+// TestAccessTokenScopes checks the read-scoped access tokens of the owner and the viewer are refused on every
+// method which writes, on routes their users can use with the ID token.
+func TestAccessTokenScopes(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{name: "GET group", method: http.MethodGet, path: groupPath(""), as: ownerPAT, want: http.StatusOK},
+		{name: "GET bundle cards", method: http.MethodGet, path: bundlePath("/cards"), as: ownerPAT, want: http.StatusOK},
+		{name: "POST group", method: http.MethodPost, path: static("/groups"), as: ownerPAT, body: text(`{"name":"Group"}`), want: http.StatusForbidden},
+		{name: "POST card", method: http.MethodPost, path: bundlePath("/cards"), as: ownerPAT, body: text(`{"question":"Q","answer":"A"}`), want: http.StatusForbidden},
+		{name: "PATCH group", method: http.MethodPatch, path: groupPath(""), as: ownerPAT, body: text(`{"name":"Renamed"}`), want: http.StatusForbidden},
+		{name: "PATCH member", method: http.MethodPatch, path: memberPath(viewer), as: ownerPAT, body: text(`{"role":"editor"}`), want: http.StatusForbidden,
+			check: hasRole(viewer, model.RoleViewer)},
+		{name: "DELETE group", method: http.MethodDelete, path: func(f *fixture) string {
+			return "/groups/" + f.group.ID + "?cascade=true&confirm=" + url.QueryEscape(f.group.Name)
+		}, as: ownerPAT, want: http.StatusForbidden},
+		{name: "DELETE card", method: http.MethodDelete, path: cardPath(""), as: ownerPAT, want: http.StatusForbidden},
+		{name: "DELETE member", method: http.MethodDelete, path: memberPath(editor), as: ownerPAT, want: http.StatusForbidden,
+			check: hasRole(editor, model.RoleEditor)},
+
+		{name: "GET user groups of viewer", method: http.MethodGet, path: userPath(viewer, "/groups"), as: viewerPAT, want: http.StatusOK},
+		{name: "GET card stats of viewer", method: http.MethodGet, path: cardPath("/stats"), as: viewerPAT, want: http.StatusOK},
+		{name: "POST review of viewer", method: http.MethodPost, path: cardPath("/reviews"), as: viewerPAT, body: text(`{"grade":4}`), want: http.StatusForbidden},
+		{name: "POST access token of viewer", method: http.MethodPost, path: userPath(viewer, "/tokens"), as: viewerPAT, body: text(`{"name":"ci","scopes":["read"]}`), want: http.StatusForbidden},
+		{name: "PATCH user of viewer", method: http.MethodPatch, path: userPath(viewer, ""), as: viewerPAT, body: text(`{"scheduler":"fsrs"}`), want: http.StatusForbidden},
+		{name: "DELETE access token of viewer", method: http.MethodDelete, path: func(f *fixture) string { return "/users/" + f.username(viewer) + "/tokens/" + f.accessTokenID },
+			as: viewerPAT, want: http.StatusForbidden},
+	})
+}
+
+// TestUnknownResources checks the routes of unknown groups, bundles, cards and items of the trash answer the
+// same as the routes of the resources of other groups, so that the response does not tell whether they exist.
+func TestUnknownResources(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *fixture) {
+		tests := []struct {
+			name   string
+			method string
+			path   string
+			known  string // the path of the resource of another group
+			body   string
+		}{
+			{"group", http.MethodGet, "/groups/nothing/bundles", "/groups/" + f.group.ID + "/bundles", ""},
+			{"bundle", http.MethodGet, "/bundles/nothing", "/bundles/" + f.bundle.ID, ""},
+			{"bundle cards", http.MethodPost, "/bundles/nothing/cards", "/bundles/" + f.bundle.ID + "/cards", `{"question":"Q","answer":"A"}`},
+			{"card", http.MethodPatch, "/cards/nothing", "/cards/" + f.card.ID, `{"answer":"A"}`},
+			{"card revisions", http.MethodGet, "/cards/nothing/revisions", "/cards/" + f.card.ID + "/revisions", ""},
+			{"trash item", http.MethodPost, "/trash/nothing/restore", "/trash/" + f.trashedCardID + "/restore", ""},
+		}
+		for _, tt := range tests {
+			unknown := f.do(tt.method, tt.path, outsider, "", tt.body)
+			known := f.do(tt.method, tt.known, outsider, "", tt.body)
+			if unknown.Code != http.StatusForbidden || known.Code != http.StatusForbidden {
+				t.Errorf("%s: status %d for unknown and %d for known, want %d", tt.name, unknown.Code, known.Code, http.StatusForbidden)
+			} else if unknown.Body.String() != known.Body.String() {
+				t.Errorf("%s: body %s for unknown and %s for known", tt.name, unknown.Body, known.Body)
+			}
+		}
+	})
+}
+
+func TestImportBundles(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *fixture) {
+		body, contentType := ankiPackage(t, "Vocabulary", [][2]string{{"Hello", "Merhaba"}, {"Thanks", "Teşekkürler"}, {"Hello", "Selam"},
+			{strings.Repeat("q", 1001), "Long"}, {"Long", strings.Repeat("a", 10001)}})
+		rw := f.do(http.MethodPost, "/groups/"+f.group.ID+"/bundles/import", editor, contentType, body)
+		if rw.Code != http.StatusOK {
+			t.Fatalf("status %d, want %d, body %s", rw.Code, http.StatusOK, rw.Body)
+		}
+		var imported []response.ImportedBundle
+		f.check(json.NewDecoder(rw.Body).Decode(&imported))
+		if len(imported) != 1 || imported[0].Title != "Vocabulary" || imported[0].CardCount != 2 || imported[0].SkippedCount != 1 {
+			t.Fatalf("imported %+v, want 2 cards and 1 skipped in Vocabulary", imported)
+		}
+		if errs := imported[0].Errors; len(errs) != 2 || errs[0].Row != 4 || errs[1].Row != 5 {
+			t.Fatalf("errors %+v, want the long question and answer of notes 4 and 5", errs)
+		}
+		cards, err := f.db.GetAllBundleCards(imported[0].ID)
+		f.check(err)
+		if len(cards) != 2 {
+			t.Errorf("bundle has %d cards, want 2", len(cards))
+		}
+	})
+}
+
+func TestImportCardLimits(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *fixture) {
+		body := "question,answer\nQuestion,Answer\n" + strings.Repeat("q", 1001) + ",Answer\nLong," + strings.Repeat("a", 10001) + "\nEmpty,\n"
+		rw := f.do(http.MethodPost, "/bundles/"+f.bundle.ID+"/cards:import", editor, "text/csv", body)
+		if rw.Code != http.StatusOK {
+			t.Fatalf("status %d, want %d, body %s", rw.Code, http.StatusOK, rw.Body)
+		}
+		var res response.CardImport
+		f.check(json.NewDecoder(rw.Body).Decode(&res))
+		if res.Inserted != 2 || len(res.Errors) != 2 || res.Errors[0].Row != 3 || res.Errors[1].Row != 4 {
+			t.Fatalf("import %+v, want 2 inserted and the errors of rows 3 and 4", res)
+		}
+		for _, e := range res.Errors {
+			if !strings.Contains(e.Message, "at most") {
+				t.Errorf("row %d: message %q, want a length error", e.Row, e.Message)
+			}
+		}
+	})
+}
+
+func TestImportCardConflicts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, f *fixture) {
+		body := fmt.Sprintf("question,answer\nNew,Answer\n%q,Answer\n%s,Answer\n", f.card.Question, strings.Repeat("q", 1001))
+		rw := f.do(http.MethodPost, "/bundles/"+f.bundle.ID+"/cards:import?onConflict=fail", editor, "text/csv", body)
+		if rw.Code != http.StatusConflict {
+			t.Fatalf("status %d, want %d, body %s", rw.Code, http.StatusConflict, rw.Body)
+		}
+		var res response.Error
+		f.check(json.NewDecoder(rw.Body).Decode(&res))
+		want := []response.FieldError{{Field: "rows[4]", Code: "request.invalid_field"}, {Field: "rows[3]", Code: "card.question_duplicate"}}
+		if res.Code != "import.conflict" || len(res.Details) != len(want) {
+			t.Fatalf("error %+v, want import.conflict with %d details", res, len(want))
+		}
+		for i, d := range res.Details {
+			if d.Field != want[i].Field || d.Code != want[i].Code {
+				t.Errorf("detail %+v, want %+v", d, want[i])
+			}
+		}
+		cards, err := f.db.GetAllBundleCards(f.bundle.ID)
+		f.check(err)
+		if len(cards) != 1 {
+			t.Errorf("bundle has %d cards after the failed import, want 1", len(cards))
+		}
+	})
+}